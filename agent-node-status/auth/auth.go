@@ -0,0 +1,74 @@
+// Package auth añade a las peticiones del agente las credenciales con las
+// que el operador verifica en qué nodo corre.
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+)
+
+// Modos de autenticación de la opción auth.mode del agente.
+const (
+	ModeNone  = "none"
+	ModeToken = "token"
+	ModeHMAC  = "hmac"
+)
+
+// BearerToken envía el token de ServiceAccount proyectado de Path. El
+// kubelet lo rota, así que se relee en cada petición.
+type BearerToken struct {
+	Path string
+}
+
+// Authorize añade la cabecera Authorization a req.
+func (b BearerToken) Authorize(req *http.Request, _ []byte) error {
+	token, err := b.Token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Token lee el token vigente.
+func (b BearerToken) Token() (string, error) {
+	token, err := os.ReadFile(b.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token: %w", err)
+	}
+	token = bytes.TrimSpace(token)
+	if len(token) == 0 {
+		return "", fmt.Errorf("token file %s is empty", b.Path)
+	}
+	return string(token), nil
+}
+
+// HMAC firma las peticiones con la clave del nodo en KeyPath.
+type HMAC struct {
+	NodeName string
+	KeyPath  string
+}
+
+// Authorize firma req y body con heartbeat.Sign.
+func (h HMAC) Authorize(req *http.Request, body []byte) error {
+	key, err := os.ReadFile(h.KeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read HMAC key: %w", err)
+	}
+	key = bytes.TrimSpace(key)
+	if len(key) == 0 {
+		return errors.New("HMAC key is empty")
+	}
+	now := time.Now()
+	req.Header.Set(heartbeat.HeaderNode, h.NodeName)
+	req.Header.Set(heartbeat.HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(heartbeat.HeaderSignature, heartbeat.Sign(key, req.Method, req.URL.Path, now, body))
+	return nil
+}
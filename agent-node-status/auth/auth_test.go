@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+)
+
+func TestAuthorize_TokenAndHMAC(t *testing.T) {
+	dir := t.TempDir()
+	tokenPath, keyPath := filepath.Join(dir, "token"), filepath.Join(dir, "key")
+	if err := os.WriteFile(tokenPath, []byte("abc\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"nodeName":"node-1"}`)
+
+	req := httptest.NewRequest(http.MethodPost, "http://operator/heartbeat", strings.NewReader(string(body)))
+	if err := (BearerToken{Path: tokenPath}).Authorize(req, body); err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer abc" {
+		t.Errorf("Authorization = %q", got)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "http://operator/heartbeat", strings.NewReader(string(body)))
+	if err := (HMAC{NodeName: "node-1", KeyPath: keyPath}).Authorize(req, body); err != nil {
+		t.Fatal(err)
+	}
+	// La firma debe verificar con el mismo código que usa el operador
+	if err := heartbeat.VerifySignature([]byte("secret"), http.MethodPost, "/heartbeat",
+		req.Header.Get(heartbeat.HeaderTimestamp), req.Header.Get(heartbeat.HeaderSignature), body, time.Now()); err != nil {
+		t.Errorf("firma inválida: %v", err)
+	}
+	if req.Header.Get(heartbeat.HeaderNode) != "node-1" {
+		t.Errorf("%s = %q", heartbeat.HeaderNode, req.Header.Get(heartbeat.HeaderNode))
+	}
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSConfig crea la configuración TLS del cliente. caFile, si no está
+// vacío, sustituye a las CAs del sistema. certFile y keyFile son el
+// certificado de cliente para mTLS; se releen cuando cambian, así que
+// rotarlos no requiere reiniciar el agente.
+func TLSConfig(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: serverName}
+
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	if certFile != "" {
+		cert := &keyPair{certFile: certFile, keyFile: keyFile}
+		if _, err := cert.get(); err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cert.get()
+		}
+	}
+	return cfg, nil
+}
+
+// ServerTLSConfig crea la configuración TLS del servidor del modo relay:
+// presenta certFile y keyFile, releídos cuando cambian, y exige a cada
+// cliente un certificado firmado por una CA de clientCAFile.
+func ServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert := &keyPair{certFile: certFile, keyFile: keyFile}
+	if _, err := cert.get(); err != nil {
+		return nil, err
+	}
+	pool, err := loadCertPool(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return cert.get()
+		},
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool,
+	}, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return pool, nil
+}
+
+// keyPair mantiene cargado un certificado y lo recarga cuando cambia la
+// fecha de modificación del certificado o de la clave. Si la recarga
+// falla, p. ej. a mitad de una rotación, sigue con el anterior.
+type keyPair struct {
+	certFile, keyFile string
+
+	mu              sync.Mutex
+	cert            *tls.Certificate
+	certMod, keyMod time.Time
+}
+
+func (c *keyPair) get() (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return c.keep(err)
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return c.keep(err)
+	}
+	if c.cert != nil && certInfo.ModTime().Equal(c.certMod) && keyInfo.ModTime().Equal(c.keyMod) {
+		return c.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return c.keep(err)
+	}
+	if c.cert != nil {
+		fmt.Printf("[AGENT] Reloaded certificate %s\n", c.certFile)
+	}
+	c.cert, c.certMod, c.keyMod = &cert, certInfo.ModTime(), keyInfo.ModTime()
+	return c.cert, nil
+}
+
+// keep devuelve el certificado anterior, si lo hay, en lugar de err.
+func (c *keyPair) keep(err error) (*tls.Certificate, error) {
+	if c.cert == nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "[AGENT WARN] Failed to reload certificate, keeping the previous one: %v\n", err)
+	return c.cert, nil
+}
@@ -0,0 +1,128 @@
+// Package buffer guarda los heartbeats que el agente no pudo entregar para
+// reenviarlos al operador cuando vuelva a ser alcanzable.
+package buffer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+)
+
+// DefaultSize cubre una hora de heartbeats cada 10 s.
+const DefaultSize = 360
+
+// Ring es un buffer circular acotado de heartbeats. Cuando está lleno
+// descarta el más antiguo. Si path no está vacío el contenido se persiste
+// en disco tras cada cambio y sobrevive a reinicios del agente.
+type Ring struct {
+	mu      sync.Mutex
+	size    int
+	path    string
+	samples []heartbeat.Payload
+}
+
+// New crea un Ring de size muestras. Si path no está vacío carga las
+// muestras persistidas en él; un fichero inexistente equivale a vacío.
+func New(size int, path string) (*Ring, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("buffer size must be positive, got %d", size)
+	}
+	r := &Ring{size: size, path: path}
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read buffer %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &r.samples); err != nil {
+		return nil, fmt.Errorf("failed to parse buffer %s: %w", path, err)
+	}
+	// El tamaño pudo reducirse entre ejecuciones
+	if over := len(r.samples) - size; over > 0 {
+		r.samples = r.samples[over:]
+	}
+	return r, nil
+}
+
+// Push añade p al final, descartando la muestra más antigua si no cabe.
+func (r *Ring) Push(p heartbeat.Payload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.samples) == r.size {
+		r.samples = r.samples[1:]
+	}
+	r.samples = append(r.samples, p)
+	return r.persist()
+}
+
+// Peek devuelve, sin retirarlas, hasta n muestras empezando por la más
+// antigua.
+func (r *Ring) Peek(n int) []heartbeat.Payload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if n > len(r.samples) {
+		n = len(r.samples)
+	}
+	return append([]heartbeat.Payload(nil), r.samples[:n]...)
+}
+
+// Discard retira las n muestras más antiguas, normalmente tras confirmar
+// su entrega con Peek.
+func (r *Ring) Discard(n int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if n > len(r.samples) {
+		n = len(r.samples)
+	}
+	r.samples = append([]heartbeat.Payload(nil), r.samples[n:]...)
+	return r.persist()
+}
+
+// Len devuelve el número de muestras pendientes.
+func (r *Ring) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.samples)
+}
+
+// persist escribe el buffer en un fichero temporal y lo renombra, para que
+// un corte de luz nunca deje un buffer a medio escribir. Se llama con mu
+// tomado.
+func (r *Ring) persist() error {
+	if r.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(r.samples)
+	if err != nil {
+		return fmt.Errorf("failed to marshal buffer: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to persist buffer: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to persist buffer: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to persist buffer: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("failed to persist buffer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package buffer
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+)
+
+func TestRing_DropsOldestAndSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "heartbeats.json")
+	r, err := New(3, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().UTC().Truncate(time.Second)
+	for i := 0; i < 5; i++ {
+		if err := r.Push(heartbeat.Payload{NodeName: "n1", Timestamp: start.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := r.Peek(10)
+	if len(got) != 3 || !got[0].Timestamp.Equal(start.Add(2*time.Second)) {
+		t.Fatalf("se esperaban las 3 muestras más recientes, got %+v", got)
+	}
+	if err := r.Discard(1); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := New(3, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = reloaded.Peek(10)
+	if len(got) != 2 || !got[0].Timestamp.Equal(start.Add(3*time.Second)) {
+		t.Errorf("el buffer recargado no coincide: %+v", got)
+	}
+}
@@ -0,0 +1,538 @@
+// Package config reúne la configuración del agente. Cada opción se puede
+// fijar en un fichero YAML, en una variable de entorno o con un flag; en
+// ese orden de menor a mayor prioridad, sobre los valores por defecto.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jaiderssjgod/agent-node-status/auth"
+	"github.com/jaiderssjgod/agent-node-status/buffer"
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+	"github.com/jaiderssjgod/agent-node-status/relay"
+	"github.com/jaiderssjgod/agent-node-status/sender"
+)
+
+// Config es la configuración efectiva del agente.
+type Config struct {
+	// NodeName es el nodo en el que corre el agente.
+	NodeName string `yaml:"nodeName"`
+	// OperatorURL es el endpoint de heartbeat del operador, p. ej.
+	// http://edge-operator-service.edge-system.svc.cluster.local:8080/heartbeat
+	// No hace falta con transport mqtt.
+	OperatorURL string `yaml:"operatorURL"`
+	// Transport es cómo se envían los heartbeats: http (un POST a
+	// OperatorURL por heartbeat), grpc (un stream con OperatorGRPCAddr) o
+	// mqtt (publicados en el broker de MQTT). Con grpc los replays siguen
+	// yendo a OperatorURL; con mqtt van al broker.
+	Transport string `yaml:"transport"`
+	// OperatorGRPCAddr es el endpoint gRPC del operador (host:puerto),
+	// usado con transport grpc.
+	OperatorGRPCAddr string `yaml:"operatorGRPCAddr"`
+	// CheckInterval es el periodo del ciclo de monitoreo local.
+	CheckInterval time.Duration `yaml:"checkInterval"`
+	// CriticalLabelKey marca los pods críticos con el valor "true".
+	CriticalLabelKey string `yaml:"criticalLabelKey"`
+	// NodeTypeLabel y NodeTypeValue identifican los nodos reducidos que el
+	// agente monitorea.
+	NodeTypeLabel string `yaml:"nodeTypeLabel"`
+	NodeTypeValue string `yaml:"nodeTypeValue"`
+
+	Heartbeat sender.Config `yaml:"heartbeat"`
+	Buffer    Buffer        `yaml:"buffer"`
+	Sensors   Sensors       `yaml:"sensors"`
+	Auth      Auth          `yaml:"auth"`
+	TLS       TLS           `yaml:"tls"`
+	Relay     Relay         `yaml:"relay"`
+	MQTT      MQTT          `yaml:"mqtt"`
+}
+
+// MQTT configura la publicación de heartbeats en un broker MQTT, usada con
+// transport mqtt. El operador no responde por MQTT, así que el agente no
+// recibe configuración remota.
+type MQTT struct {
+	// Broker es la URL del broker, p. ej. tcp://mosquitto:1883 o
+	// ssl://mosquitto:8883.
+	Broker string `yaml:"broker"`
+	// TopicPrefix es la raíz del árbol de topics: el agente publica en
+	// <topicPrefix>/<nodeName>.
+	TopicPrefix string `yaml:"topicPrefix"`
+	// ClientID identifica al agente ante el broker; vacío usa
+	// edge-agent-<nodeName>.
+	ClientID string `yaml:"clientID"`
+	// Username y PasswordFile, si no están vacíos, autentican al agente
+	// ante el broker.
+	Username     string `yaml:"username"`
+	PasswordFile string `yaml:"passwordFile"`
+	// CAFile verifica un broker con TLS; vacío usa las CAs del sistema.
+	// CertFile y KeyFile son el certificado de cliente del nodo.
+	CAFile   string `yaml:"caFile"`
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+// Relay configura el modo relay, en el que el agente recibe los heartbeats
+// de agentes vecinos y los envía al operador en lotes junto con el suyo.
+type Relay struct {
+	// ListenAddr es la dirección donde escucha a los vecinos, p. ej.
+	// ":9090"; vacío desactiva el modo relay.
+	ListenAddr string `yaml:"listenAddr"`
+	// MaxNodes es el máximo de vecinos aceptados.
+	MaxNodes int `yaml:"maxNodes"`
+	// ClientCAFile es el bundle de CAs que verifica los certificados de
+	// cliente de los vecinos. El relay sirve HTTPS con el certificado del
+	// nodo (tls.certFile y tls.keyFile) y cada vecino solo puede reportar el
+	// nodo de su certificado.
+	ClientCAFile string `yaml:"clientCAFile"`
+}
+
+// TLS configura la conexión HTTPS con el operador.
+type TLS struct {
+	// CAFile es el bundle de CAs que verifica el certificado del operador;
+	// vacío usa las CAs del sistema.
+	CAFile string `yaml:"caFile"`
+	// CertFile y KeyFile son el certificado de cliente del nodo, para mTLS.
+	// Se releen cuando cambian.
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+	// ServerName sustituye al host de operatorURL al verificar el
+	// certificado del operador.
+	ServerName string `yaml:"serverName"`
+}
+
+// Auth configura cómo se autentica el agente ante el operador.
+type Auth struct {
+	// Mode es none, token (token de ServiceAccount proyectado) o hmac
+	// (peticiones firmadas con la clave del nodo).
+	Mode string `yaml:"mode"`
+	// TokenPath es el token proyectado, usado con token.
+	TokenPath string `yaml:"tokenPath"`
+	// HMACKeyPath es la clave del nodo, usada con hmac.
+	HMACKeyPath string `yaml:"hmacKeyPath"`
+}
+
+// Buffer configura los heartbeats pendientes de reenviar.
+type Buffer struct {
+	// Size es el máximo de muestras guardadas.
+	Size int `yaml:"size"`
+	// Path es el fichero donde se persisten; vacío las guarda solo en memoria.
+	Path string `yaml:"path"`
+}
+
+// Sensors configura la telemetría que se reporta.
+type Sensors struct {
+	// DiskMounts son los puntos de montaje cuyo uso se reporta.
+	DiskMounts []string `yaml:"diskMounts"`
+	// ReportPerCPU y ReportCgroupCPU activan el uso por núcleo y por cgroup.
+	ReportPerCPU    bool `yaml:"reportPerCPU"`
+	ReportCgroupCPU bool `yaml:"reportCgroupCPU"`
+	// ReportPressure, ReportTemperatures y ReportPower activan la PSI, las
+	// zonas térmicas y la batería.
+	ReportPressure     bool `yaml:"reportPressure"`
+	ReportTemperatures bool `yaml:"reportTemperatures"`
+	ReportPower        bool `yaml:"reportPower"`
+	// SysfsRoot es la raíz de /sys, por si el del host se monta en otra ruta.
+	SysfsRoot string `yaml:"sysfsRoot"`
+}
+
+// Transportes de heartbeat de la opción transport.
+const (
+	TransportHTTP = "http"
+	TransportGRPC = "grpc"
+	TransportMQTT = "mqtt"
+)
+
+// Default devuelve la configuración por defecto. NodeName, OperatorURL y
+// MQTT.Broker no tienen valor por defecto.
+func Default() Config {
+	return Config{
+		Transport:        TransportHTTP,
+		CheckInterval:    20 * time.Second,
+		CriticalLabelKey: "iot/critical",
+		NodeTypeLabel:    "node-type",
+		NodeTypeValue:    "reducido",
+		Heartbeat:        sender.DefaultConfig(),
+		Buffer:           Buffer{Size: buffer.DefaultSize},
+		Sensors: Sensors{
+			DiskMounts:         []string{"/"},
+			ReportPressure:     true,
+			ReportTemperatures: true,
+			ReportPower:        true,
+			SysfsRoot:          "/sys",
+		},
+		Auth: Auth{
+			Mode:      auth.ModeNone,
+			TokenPath: "/var/run/secrets/edge-operator/token",
+		},
+		Relay: Relay{MaxNodes: relay.DefaultMaxNodes},
+		MQTT:  MQTT{TopicPrefix: heartbeat.DefaultMQTTTopicPrefix},
+	}
+}
+
+// configFileEnv es la variable con la ruta del fichero de configuración;
+// el flag -config tiene prioridad.
+const configFileEnv = "AGENT_CONFIG"
+
+// option es una opción configurable por flag y por variable de entorno.
+type option struct {
+	flag  string
+	env   string
+	usage string
+	bind  func(fs *flag.FlagSet, c *Config, name, usage string)
+}
+
+func stringOpt(field func(*Config) *string) func(*flag.FlagSet, *Config, string, string) {
+	return func(fs *flag.FlagSet, c *Config, name, usage string) {
+		p := field(c)
+		fs.StringVar(p, name, *p, usage)
+	}
+}
+
+func durationOpt(field func(*Config) *time.Duration) func(*flag.FlagSet, *Config, string, string) {
+	return func(fs *flag.FlagSet, c *Config, name, usage string) {
+		p := field(c)
+		fs.DurationVar(p, name, *p, usage)
+	}
+}
+
+func intOpt(field func(*Config) *int) func(*flag.FlagSet, *Config, string, string) {
+	return func(fs *flag.FlagSet, c *Config, name, usage string) {
+		p := field(c)
+		fs.IntVar(p, name, *p, usage)
+	}
+}
+
+func boolOpt(field func(*Config) *bool) func(*flag.FlagSet, *Config, string, string) {
+	return func(fs *flag.FlagSet, c *Config, name, usage string) {
+		p := field(c)
+		fs.BoolVar(p, name, *p, usage)
+	}
+}
+
+// listValue es una lista separada por comas.
+type listValue struct{ p *[]string }
+
+func (l listValue) String() string {
+	if l.p == nil {
+		return ""
+	}
+	return strings.Join(*l.p, ",")
+}
+
+func (l listValue) Set(s string) error {
+	*l.p = strings.Split(s, ",")
+	return nil
+}
+
+// options son todas las opciones. Las variables de entorno conservan los
+// nombres que el agente ya leía.
+var options = []option{
+	{"node-name", "NODE_NAME", "node the agent runs on",
+		stringOpt(func(c *Config) *string { return &c.NodeName })},
+	{"operator-url", "OPERATOR_HEARTBEAT_URL", "operator heartbeat endpoint",
+		stringOpt(func(c *Config) *string { return &c.OperatorURL })},
+	{"transport", "HEARTBEAT_TRANSPORT", "how heartbeats are sent: http, grpc or mqtt",
+		stringOpt(func(c *Config) *string { return &c.Transport })},
+	{"operator-grpc-addr", "OPERATOR_GRPC_ADDR", "operator gRPC heartbeat endpoint (host:port), used with grpc",
+		stringOpt(func(c *Config) *string { return &c.OperatorGRPCAddr })},
+	{"check-interval", "CHECK_INTERVAL", "local monitoring period",
+		durationOpt(func(c *Config) *time.Duration { return &c.CheckInterval })},
+	{"critical-label-key", "CRITICAL_LABEL_KEY", "label that marks critical pods",
+		stringOpt(func(c *Config) *string { return &c.CriticalLabelKey })},
+	{"node-type-label", "NODE_TYPE_LABEL", "node label that identifies reduced nodes",
+		stringOpt(func(c *Config) *string { return &c.NodeTypeLabel })},
+	{"node-type-value", "NODE_TYPE_VALUE", "value of the node type label for reduced nodes",
+		stringOpt(func(c *Config) *string { return &c.NodeTypeValue })},
+	{"heartbeat-interval", "HEARTBEAT_INTERVAL", "period between heartbeats",
+		durationOpt(func(c *Config) *time.Duration { return &c.Heartbeat.Interval })},
+	{"heartbeat-timeout", "HEARTBEAT_TIMEOUT", "timeout of each request to the operator",
+		durationOpt(func(c *Config) *time.Duration { return &c.Heartbeat.Timeout })},
+	{"heartbeat-startup-jitter", "HEARTBEAT_STARTUP_JITTER", "maximum random delay before the first heartbeat",
+		durationOpt(func(c *Config) *time.Duration { return &c.Heartbeat.StartupJitter })},
+	{"heartbeat-backoff-base", "HEARTBEAT_BACKOFF_BASE", "wait after the first failed request",
+		durationOpt(func(c *Config) *time.Duration { return &c.Heartbeat.BackoffBase })},
+	{"heartbeat-backoff-max", "HEARTBEAT_BACKOFF_MAX", "maximum wait between failed requests",
+		durationOpt(func(c *Config) *time.Duration { return &c.Heartbeat.BackoffMax })},
+	{"heartbeat-breaker-threshold", "HEARTBEAT_BREAKER_THRESHOLD", "consecutive failures that open the circuit breaker",
+		intOpt(func(c *Config) *int { return &c.Heartbeat.BreakerThreshold })},
+	{"heartbeat-breaker-cooldown", "HEARTBEAT_BREAKER_COOLDOWN", "time the circuit breaker stays open",
+		durationOpt(func(c *Config) *time.Duration { return &c.Heartbeat.BreakerCooldown })},
+	{"buffer-size", "BUFFER_SIZE", "maximum heartbeats kept while the operator is unreachable",
+		intOpt(func(c *Config) *int { return &c.Buffer.Size })},
+	{"buffer-path", "BUFFER_PATH", "file where pending heartbeats are persisted (empty: memory only)",
+		stringOpt(func(c *Config) *string { return &c.Buffer.Path })},
+	{"disk-mounts", "DISK_MOUNTS", "comma-separated mount points whose usage is reported",
+		func(fs *flag.FlagSet, c *Config, name, usage string) {
+			fs.Var(listValue{&c.Sensors.DiskMounts}, name, usage)
+		}},
+	{"report-per-cpu", "REPORT_PER_CPU", "report per-core CPU usage",
+		boolOpt(func(c *Config) *bool { return &c.Sensors.ReportPerCPU })},
+	{"report-cgroup-cpu", "REPORT_CGROUP_CPU", "report the agent cgroup CPU usage",
+		boolOpt(func(c *Config) *bool { return &c.Sensors.ReportCgroupCPU })},
+	{"report-pressure", "REPORT_PRESSURE", "report pressure stall information",
+		boolOpt(func(c *Config) *bool { return &c.Sensors.ReportPressure })},
+	{"report-temperatures", "REPORT_TEMPERATURES", "report thermal zones",
+		boolOpt(func(c *Config) *bool { return &c.Sensors.ReportTemperatures })},
+	{"report-power", "REPORT_POWER", "report battery and power source",
+		boolOpt(func(c *Config) *bool { return &c.Sensors.ReportPower })},
+	{"sysfs-root", "SYSFS_ROOT", "root of the host /sys",
+		stringOpt(func(c *Config) *string { return &c.Sensors.SysfsRoot })},
+	{"auth-mode", "AUTH_MODE", "how the agent authenticates to the operator: none, token or hmac",
+		stringOpt(func(c *Config) *string { return &c.Auth.Mode })},
+	{"auth-token-path", "AUTH_TOKEN_PATH", "projected ServiceAccount token, used with token",
+		stringOpt(func(c *Config) *string { return &c.Auth.TokenPath })},
+	{"auth-hmac-key-path", "AUTH_HMAC_KEY_PATH", "node HMAC key, used with hmac",
+		stringOpt(func(c *Config) *string { return &c.Auth.HMACKeyPath })},
+	{"tls-ca-file", "TLS_CA_FILE", "CA bundle that verifies the operator certificate (empty: system CAs)",
+		stringOpt(func(c *Config) *string { return &c.TLS.CAFile })},
+	{"tls-cert-file", "TLS_CERT_FILE", "client certificate presented to the operator (mTLS)",
+		stringOpt(func(c *Config) *string { return &c.TLS.CertFile })},
+	{"tls-key-file", "TLS_KEY_FILE", "private key of the client certificate",
+		stringOpt(func(c *Config) *string { return &c.TLS.KeyFile })},
+	{"tls-server-name", "TLS_SERVER_NAME", "name expected in the operator certificate (empty: the URL host)",
+		stringOpt(func(c *Config) *string { return &c.TLS.ServerName })},
+	{"relay-listen-addr", "RELAY_LISTEN_ADDR", "address where neighbor agents send their heartbeats (empty: relay mode off)",
+		stringOpt(func(c *Config) *string { return &c.Relay.ListenAddr })},
+	{"relay-max-nodes", "RELAY_MAX_NODES", "maximum neighbor nodes accepted in relay mode",
+		intOpt(func(c *Config) *int { return &c.Relay.MaxNodes })},
+	{"relay-client-ca-file", "RELAY_CLIENT_CA_FILE", "CA bundle that verifies the client certificates of neighbor agents in relay mode",
+		stringOpt(func(c *Config) *string { return &c.Relay.ClientCAFile })},
+	{"mqtt-broker", "MQTT_BROKER", "MQTT broker URL, e.g. tcp://mosquitto:1883, used with mqtt; its ACLs must restrict the agent to its own topics",
+		stringOpt(func(c *Config) *string { return &c.MQTT.Broker })},
+	{"mqtt-topic-prefix", "MQTT_TOPIC_PREFIX", "root of the MQTT topic tree; heartbeats go to <prefix>/<node>",
+		stringOpt(func(c *Config) *string { return &c.MQTT.TopicPrefix })},
+	{"mqtt-client-id", "MQTT_CLIENT_ID", "MQTT client ID (empty: edge-agent-<node>)",
+		stringOpt(func(c *Config) *string { return &c.MQTT.ClientID })},
+	{"mqtt-username", "MQTT_USERNAME", "username presented to the MQTT broker",
+		stringOpt(func(c *Config) *string { return &c.MQTT.Username })},
+	{"mqtt-password-file", "MQTT_PASSWORD_FILE", "file with the password presented to the MQTT broker",
+		stringOpt(func(c *Config) *string { return &c.MQTT.PasswordFile })},
+	{"mqtt-ca-file", "MQTT_CA_FILE", "CA bundle that verifies a TLS MQTT broker (empty: system CAs)",
+		stringOpt(func(c *Config) *string { return &c.MQTT.CAFile })},
+	{"mqtt-cert-file", "MQTT_CERT_FILE", "client certificate presented to the MQTT broker",
+		stringOpt(func(c *Config) *string { return &c.MQTT.CertFile })},
+	{"mqtt-key-file", "MQTT_KEY_FILE", "private key of the MQTT client certificate",
+		stringOpt(func(c *Config) *string { return &c.MQTT.KeyFile })},
+}
+
+// newFlagSet define los flags de todas las opciones sobre c.
+func newFlagSet(c *Config, configPath *string) *flag.FlagSet {
+	fs := flag.NewFlagSet("agent", flag.ContinueOnError)
+	fs.StringVar(configPath, "config", "", "YAML configuration file (env "+configFileEnv+")")
+	for _, o := range options {
+		o.bind(fs, c, o.flag, fmt.Sprintf("%s (env %s)", o.usage, o.env))
+	}
+	return fs
+}
+
+// Load construye la configuración a partir de los valores por defecto, el
+// fichero YAML indicado por -config o AGENT_CONFIG, las variables de
+// entorno leídas con getenv y los flags de args, y la valida.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	// Primera pasada solo para saber qué fichero cargar
+	var configPath string
+	scratch := Default()
+	pre := newFlagSet(&scratch, &configPath)
+	pre.SetOutput(io.Discard)
+	// -h se deja a la segunda pasada, que sí imprime la ayuda
+	if err := pre.Parse(args); err != nil && !errors.Is(err, flag.ErrHelp) {
+		return Config{}, err
+	}
+	if configPath == "" {
+		configPath = getenv(configFileEnv)
+	}
+
+	cfg := Default()
+	if configPath != "" {
+		if err := loadFile(configPath, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	fs := newFlagSet(&cfg, &configPath)
+	for _, o := range options {
+		v := getenv(o.env)
+		if v == "" {
+			continue
+		}
+		if err := fs.Set(o.flag, v); err != nil {
+			return Config{}, fmt.Errorf("invalid %s %q: %w", o.env, v, err)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// loadFile sobrescribe cfg con las claves presentes en path. Las claves
+// desconocidas son un error, para no ignorar erratas en silencio.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate comprueba la configuración y devuelve todos los errores juntos.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.NodeName != "", "nodeName is required")
+	if c.OperatorURL == "" {
+		check(c.Transport == TransportMQTT, "operatorURL is required")
+	} else if u, err := url.Parse(c.OperatorURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("operatorURL %q must be an absolute http(s) URL", c.OperatorURL))
+	}
+	switch c.Transport {
+	case TransportHTTP:
+	case TransportGRPC:
+		check(c.OperatorGRPCAddr != "", "operatorGRPCAddr is required with transport %s", TransportGRPC)
+		check(c.Auth.Mode != auth.ModeHMAC, "auth.mode %s signs HTTP requests and cannot be used with transport %s",
+			auth.ModeHMAC, TransportGRPC)
+		check(c.Relay.ListenAddr == "", "relay mode sends batches over HTTP and cannot be used with transport %s", TransportGRPC)
+	case TransportMQTT:
+		m := c.MQTT
+		if m.Broker == "" {
+			errs = append(errs, fmt.Errorf("mqtt.broker is required with transport %s", TransportMQTT))
+		} else if u, err := url.Parse(m.Broker); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("mqtt.broker %q must be a URL such as tcp://host:1883", m.Broker))
+		}
+		check(m.TopicPrefix != "" && !strings.ContainsAny(m.TopicPrefix, "+#"),
+			"mqtt.topicPrefix %q must be a topic without wildcards", m.TopicPrefix)
+		check((m.CertFile == "") == (m.KeyFile == ""), "mqtt.certFile and mqtt.keyFile must be set together")
+		// Con MQTT autentica el broker, no el operador
+		check(c.Auth.Mode == auth.ModeNone, "auth.mode %s authenticates to the operator and cannot be used with transport %s; use the mqtt credentials",
+			c.Auth.Mode, TransportMQTT)
+		check(c.Relay.ListenAddr == "", "relay mode sends batches over HTTP and cannot be used with transport %s", TransportMQTT)
+	default:
+		errs = append(errs, fmt.Errorf("transport %q must be %s, %s or %s", c.Transport, TransportHTTP, TransportGRPC, TransportMQTT))
+	}
+	check(c.CheckInterval > 0, "checkInterval must be positive")
+	check(c.CriticalLabelKey != "", "criticalLabelKey is required")
+	check(c.NodeTypeLabel != "", "nodeTypeLabel is required")
+
+	hb := c.Heartbeat
+	check(hb.Interval > 0, "heartbeat.interval must be positive")
+	check(hb.Timeout > 0, "heartbeat.timeout must be positive")
+	check(hb.StartupJitter >= 0, "heartbeat.startupJitter must not be negative")
+	check(hb.BackoffBase > 0, "heartbeat.backoffBase must be positive")
+	check(hb.BackoffMax >= hb.BackoffBase, "heartbeat.backoffMax (%s) must not be less than backoffBase (%s)",
+		hb.BackoffMax, hb.BackoffBase)
+	check(hb.BreakerThreshold > 0, "heartbeat.breakerThreshold must be positive")
+	check(hb.BreakerCooldown > 0, "heartbeat.breakerCooldown must be positive")
+
+	check(c.Buffer.Size > 0, "buffer.size must be positive")
+	check(len(c.Sensors.DiskMounts) > 0, "sensors.diskMounts must not be empty")
+	for _, m := range c.Sensors.DiskMounts {
+		check(strings.HasPrefix(m, "/"), "sensors.diskMounts entry %q must be an absolute path", m)
+	}
+	check(c.Sensors.SysfsRoot != "", "sensors.sysfsRoot is required")
+
+	switch c.Auth.Mode {
+	case auth.ModeNone:
+	case auth.ModeToken:
+		check(c.Auth.TokenPath != "", "auth.tokenPath is required with auth.mode %s", auth.ModeToken)
+	case auth.ModeHMAC:
+		check(c.Auth.HMACKeyPath != "", "auth.hmacKeyPath is required with auth.mode %s", auth.ModeHMAC)
+	default:
+		errs = append(errs, fmt.Errorf("auth.mode %q must be %s, %s or %s", c.Auth.Mode, auth.ModeNone, auth.ModeToken, auth.ModeHMAC))
+	}
+
+	t := c.TLS
+	check((t.CertFile == "") == (t.KeyFile == ""), "tls.certFile and tls.keyFile must be set together")
+	if t != (TLS{}) {
+		check(strings.HasPrefix(c.OperatorURL, "https://"), "tls settings require an https operatorURL")
+	}
+
+	if c.Relay.ListenAddr != "" {
+		check(c.Relay.MaxNodes > 0, "relay.maxNodes must be positive")
+		// Los vecinos se autentican con mTLS ante el relay
+		check(t.CertFile != "", "relay mode serves HTTPS with the node certificate and requires tls.certFile and tls.keyFile")
+		check(c.Relay.ClientCAFile != "", "relay mode requires relay.clientCAFile to verify neighbor agents")
+	}
+
+	return errors.Join(errs...)
+}
+
+// WithRemote devuelve una copia de c con la configuración remota que el
+// operador envió en la respuesta a un heartbeat. Los campos vacíos de
+// remote conservan el valor de c; un remote nil devuelve c sin cambios.
+// El resultado se valida: una configuración remota inválida es un error y
+// el llamador debe seguir con c.
+func (c Config) WithRemote(remote *heartbeat.AgentConfig) (Config, error) {
+	out := c
+	out.Sensors.DiskMounts = append([]string(nil), c.Sensors.DiskMounts...)
+	if remote == nil {
+		return out, nil
+	}
+
+	if remote.HeartbeatIntervalSeconds > 0 {
+		out.Heartbeat.Interval = time.Duration(remote.HeartbeatIntervalSeconds) * time.Second
+	}
+	if remote.CriticalLabelKey != "" {
+		out.CriticalLabelKey = remote.CriticalLabelKey
+	}
+	if remote.NodeTypeLabel != "" {
+		out.NodeTypeLabel = remote.NodeTypeLabel
+		out.NodeTypeValue = remote.NodeTypeValue
+	}
+	if sc := remote.Sensors; sc != nil {
+		for _, f := range []struct {
+			remote *bool
+			local  *bool
+		}{
+			{sc.PerCPU, &out.Sensors.ReportPerCPU},
+			{sc.CgroupCPU, &out.Sensors.ReportCgroupCPU},
+			{sc.Pressure, &out.Sensors.ReportPressure},
+			{sc.Temperatures, &out.Sensors.ReportTemperatures},
+			{sc.Power, &out.Sensors.ReportPower},
+		} {
+			if f.remote != nil {
+				*f.local = *f.remote
+			}
+		}
+		if len(sc.DiskMounts) > 0 {
+			out.Sensors.DiskMounts = append([]string(nil), sc.DiskMounts...)
+		}
+	}
+
+	if err := out.Validate(); err != nil {
+		return c, fmt.Errorf("invalid configuration from policy %s: %w", remote.Policy, err)
+	}
+	return out, nil
+}
+
+// String devuelve la configuración en YAML, para imprimirla al arrancar.
+func (c Config) String() string {
+	out, err := yaml.Marshal(c)
+	if err != nil {
+		return "failed to marshal config: " + err.Error()
+	}
+	return string(out)
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+)
+
+func TestLoad_FileThenEnvThenFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.yaml")
+	file := `
+nodeName: from-file
+operatorURL: http://operator:9090/heartbeat
+checkInterval: 1m
+heartbeat:
+  interval: 30s
+  breakerThreshold: 3
+sensors:
+  diskMounts: [/, /data]
+`
+	if err := os.WriteFile(path, []byte(file), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"AGENT_CONFIG":       path,
+		"NODE_NAME":          "from-env",
+		"HEARTBEAT_INTERVAL": "15s",
+		"REPORT_PER_CPU":     "true",
+	}
+
+	cfg, err := Load([]string{"-heartbeat-interval=5s"}, func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.NodeName != "from-env" {
+		t.Errorf("el entorno debe tener prioridad sobre el fichero: %q", cfg.NodeName)
+	}
+	if cfg.Heartbeat.Interval != 5*time.Second {
+		t.Errorf("el flag debe tener prioridad sobre el entorno: %s", cfg.Heartbeat.Interval)
+	}
+	if cfg.CheckInterval != time.Minute || cfg.Heartbeat.BreakerThreshold != 3 ||
+		strings.Join(cfg.Sensors.DiskMounts, ",") != "/,/data" || !cfg.Sensors.ReportPerCPU {
+		t.Errorf("configuración inesperada:\n%s", cfg)
+	}
+	// Lo que no se fija en ningún sitio conserva el valor por defecto
+	if cfg.Heartbeat.Timeout != Default().Heartbeat.Timeout || cfg.NodeTypeValue != "reducido" {
+		t.Errorf("se perdieron valores por defecto:\n%s", cfg)
+	}
+}
+
+func TestLoad_RejectsInvalidConfig(t *testing.T) {
+	cases := map[string]struct {
+		args []string
+		env  map[string]string
+		want string
+	}{
+		"sin nodo": {
+			env:  map[string]string{"OPERATOR_HEARTBEAT_URL": "http://operator/heartbeat"},
+			want: "nodeName is required",
+		},
+		"url relativa": {
+			args: []string{"-node-name=n1", "-operator-url=operator/heartbeat"},
+			want: "absolute http(s) URL",
+		},
+		"backoff invertido": {
+			args: []string{"-node-name=n1", "-operator-url=http://operator/heartbeat",
+				"-heartbeat-backoff-base=1m", "-heartbeat-backoff-max=10s"},
+			want: "backoffMax",
+		},
+		"entorno ilegible": {
+			args: []string{"-node-name=n1", "-operator-url=http://operator/heartbeat"},
+			env:  map[string]string{"BUFFER_SIZE": "many"},
+			want: "invalid BUFFER_SIZE",
+		},
+		"certificado sin clave": {
+			args: []string{"-node-name=n1", "-operator-url=https://operator/heartbeat", "-tls-cert-file=/etc/agent/tls.crt"},
+			want: "tls.certFile and tls.keyFile",
+		},
+		"tls sobre http": {
+			args: []string{"-node-name=n1", "-operator-url=http://operator/heartbeat"},
+			env:  map[string]string{"TLS_CA_FILE": "/etc/agent/ca.crt"},
+			want: "https operatorURL",
+		},
+		"relay sin certificado": {
+			args: []string{"-node-name=n1", "-operator-url=http://operator/heartbeat", "-relay-listen-addr=:9090"},
+			want: "requires tls.certFile and tls.keyFile",
+		},
+		"relay sin ca de vecinos": {
+			args: []string{"-node-name=n1", "-operator-url=https://operator/heartbeat", "-relay-listen-addr=:9090",
+				"-tls-cert-file=/etc/agent/tls.crt", "-tls-key-file=/etc/agent/tls.key"},
+			want: "relay.clientCAFile",
+		},
+		"mqtt sin broker": {
+			args: []string{"-node-name=n1", "-transport=mqtt"},
+			want: "mqtt.broker is required",
+		},
+		"mqtt con hmac": {
+			args: []string{"-node-name=n1", "-transport=mqtt", "-mqtt-broker=tcp://mosquitto:1883",
+				"-auth-mode=hmac", "-auth-hmac-key-path=/etc/agent/key"},
+			want: "use the mqtt credentials",
+		},
+	}
+	for name, tc := range cases {
+		_, err := Load(tc.args, func(k string) string { return tc.env[k] })
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: se esperaba un error con %q, got %v", name, tc.want, err)
+		}
+	}
+}
+
+func TestLoad_RejectsUnknownFileKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.yaml")
+	if err := os.WriteFile(path, []byte("heartbeat:\n  intervall: 5s\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load([]string{"-config", path}, func(string) string { return "" }); err == nil {
+		t.Error("una clave desconocida en el fichero debe ser un error")
+	}
+}
+
+func TestWithRemote_OverridesOnlyWhatThePolicySets(t *testing.T) {
+	local := Default()
+	local.NodeName, local.OperatorURL = "n1", "http://operator/heartbeat"
+	off := false
+
+	got, err := local.WithRemote(&heartbeat.AgentConfig{
+		Policy:                   "policy-a",
+		HeartbeatIntervalSeconds: 30,
+		CriticalLabelKey:         "edge/critical",
+		Sensors:                  &heartbeat.SensorConfig{Temperatures: &off, DiskMounts: []string{"/data"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Heartbeat.Interval != 30*time.Second || got.CriticalLabelKey != "edge/critical" ||
+		got.Sensors.ReportTemperatures || strings.Join(got.Sensors.DiskMounts, ",") != "/data" {
+		t.Errorf("no se aplicó la configuración remota:\n%s", got)
+	}
+	if got.NodeTypeLabel != local.NodeTypeLabel || !got.Sensors.ReportPower || got.Heartbeat.Timeout != local.Heartbeat.Timeout {
+		t.Errorf("se perdió configuración local que la policy no define:\n%s", got)
+	}
+	if strings.Join(local.Sensors.DiskMounts, ",") != "/" {
+		t.Errorf("WithRemote no debe modificar la configuración local: %v", local.Sensors.DiskMounts)
+	}
+
+	if _, err := local.WithRemote(&heartbeat.AgentConfig{
+		Policy:  "policy-b",
+		Sensors: &heartbeat.SensorConfig{DiskMounts: []string{"data"}},
+	}); err == nil {
+		t.Error("una configuración remota inválida debe rechazarse")
+	}
+}
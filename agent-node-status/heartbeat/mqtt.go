@@ -0,0 +1,38 @@
+package heartbeat
+
+import "strings"
+
+// DefaultMQTTTopicPrefix es la raíz del árbol de topics MQTT de heartbeats.
+// Cada nodo publica sus heartbeats (Payload) en <prefijo>/<nodo> y sus
+// replays (ReplayRequest) en <prefijo>/<nodo>/replay.
+const DefaultMQTTTopicPrefix = "edge/heartbeat"
+
+// mqttReplaySuffix es el último nivel de los topics de replay.
+const mqttReplaySuffix = "replay"
+
+// MQTTTopic devuelve el topic en el que nodeName publica sus heartbeats.
+func MQTTTopic(prefix, nodeName string) string {
+	return strings.TrimSuffix(prefix, "/") + "/" + nodeName
+}
+
+// MQTTReplayTopic devuelve el topic en el que nodeName publica sus replays.
+func MQTTReplayTopic(prefix, nodeName string) string {
+	return MQTTTopic(prefix, nodeName) + "/" + mqttReplaySuffix
+}
+
+// ParseMQTTTopic extrae el nodo de un topic bajo prefix e indica si es un
+// topic de replay. ok es false si el topic no pertenece al árbol.
+func ParseMQTTTopic(prefix, topic string) (nodeName string, replay bool, ok bool) {
+	rest, found := strings.CutPrefix(topic, strings.TrimSuffix(prefix, "/")+"/")
+	if !found {
+		return "", false, false
+	}
+	levels := strings.Split(rest, "/")
+	switch {
+	case len(levels) == 1 && levels[0] != "":
+		return levels[0], false, true
+	case len(levels) == 2 && levels[0] != "" && levels[1] == mqttReplaySuffix:
+		return levels[0], true, true
+	}
+	return "", false, false
+}
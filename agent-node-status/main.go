@@ -1,271 +1,517 @@
-// cmd/agent/main.go
-package main
-
-import (
-	"bufio"
-	"bytes"
-	"context"
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"os"
-	"strconv"
-	"strings"
-	"time"
-
-	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-
-	"github.com/jaiderssjgod/agent-node-status/heartbeat"
-)
-
-const (
-	criticalLabelKey  = "iot/critical"
-	nodeTypeLabel     = "node-type"
-	nodeTypeValue     = "reducido"
-	checkInterval     = 20 * time.Second
-	heartbeatInterval = 10 * time.Second
-)
-
-func main() {
-	fmt.Println("[AGENT] Starting agent...")
-
-	cfg, err := rest.InClusterConfig()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Failed to load cluster config: %v\n", err)
-		panic(err.Error())
-	}
-
-	clientset, err := kubernetes.NewForConfig(cfg)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Failed to create clientset: %v\n", err)
-		panic(err.Error())
-	}
-
-	nodeName := os.Getenv("NODE_NAME")
-	if nodeName == "" {
-		fmt.Fprintf(os.Stderr, "[AGENT ERROR] NODE_NAME env var required but missing\n")
-		panic("NODE_NAME env var required")
-	}
-
-	// OPERATOR_HEARTBEAT_URL es la URL del endpoint del operador, e.g.:
-	// http://edge-operator-service.edge-system.svc.cluster.local:8080/heartbeat
-	operatorURL := os.Getenv("OPERATOR_HEARTBEAT_URL")
-	if operatorURL == "" {
-		fmt.Fprintf(os.Stderr, "[AGENT ERROR] OPERATOR_HEARTBEAT_URL env var required but missing\n")
-		panic("OPERATOR_HEARTBEAT_URL env var required")
-	}
-
-	fmt.Printf("[AGENT] Running on node: %s\n", nodeName)
-
-	// Goroutine independiente para el heartbeat (cada 10 s)
-	go runHeartbeatLoop(nodeName, operatorURL)
-
-	// Loop principal de monitoreo (cada 20 s)
-	for {
-		fmt.Println("[AGENT] Monitoring node...")
-		monitorNode(clientset, nodeName)
-		time.Sleep(checkInterval)
-	}
-}
-
-// runHeartbeatLoop envía un heartbeat al operador cada heartbeatInterval.
-// Corre en su propia goroutine para ser independiente del ciclo de monitoreo.
-func runHeartbeatLoop(nodeName, operatorURL string) {
-	ticker := time.NewTicker(heartbeatInterval)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		sendHeartbeat(nodeName, operatorURL)
-	}
-}
-
-// sendHeartbeat construye y envía el payload de heartbeat al operador.
-func sendHeartbeat(nodeName, operatorURL string) {
-	payload := heartbeat.Payload{
-		NodeName:  nodeName,
-		Timestamp: time.Now().UTC(),
-		CPU:       readCPUUsage(),
-		Memory:    readMemUsage(),
-	}
-
-	body, err := json.Marshal(payload)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Failed to marshal heartbeat: %v\n", err)
-		return
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, operatorURL, bytes.NewReader(body))
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Failed to create heartbeat request: %v\n", err)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Failed to send heartbeat: %v\n", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		fmt.Fprintf(os.Stderr, "[AGENT WARN] Heartbeat returned non-200 status: %d\n", resp.StatusCode)
-		return
-	}
-
-	fmt.Printf("[AGENT] Heartbeat sent for node %s at %s\n", nodeName, payload.Timestamp.Format(time.RFC3339))
-}
-
-func monitorNode(clientset *kubernetes.Clientset, nodeName string) {
-	ctx := context.Background()
-
-	node, err := clientset.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Error fetching node: %v\n", err)
-		return
-	}
-
-	if v, ok := node.Labels[nodeTypeLabel]; !ok || v != nodeTypeValue {
-		fmt.Printf(
-			"[AGENT] Node %s is not labeled as '%s=%s' (label is '%s'), skipping\n",
-			node.Name, nodeTypeLabel, nodeTypeValue, v,
-		)
-		return
-	}
-
-	pods, err := clientset.CoreV1().Pods("").List(
-		ctx,
-		metav1.ListOptions{
-			FieldSelector: fmt.Sprintf("spec.nodeName=%s", nodeName),
-		},
-	)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Error listing pods: %v\n", err)
-		return
-	}
-
-	cpuUsage := readCPUUsage()
-	memUsage := readMemUsage()
-
-	status := struct {
-		Time         time.Time `json:"time"`
-		Node         string    `json:"node"`
-		NodeType     string    `json:"node_type"`
-		CPU          string    `json:"cpu"`
-		Mem          string    `json:"memory"`
-		CriticalPods int       `json:"critical_pods"`
-	}{
-		Time:         time.Now(),
-		Node:         node.Name,
-		NodeType:     node.Labels[nodeTypeLabel],
-		CPU:          cpuUsage,
-		Mem:          memUsage,
-		CriticalPods: 0,
-	}
-
-	for _, pod := range pods.Items {
-		if pod.Labels[criticalLabelKey] == "true" && pod.Status.Phase == corev1.PodRunning {
-			status.CriticalPods++
-		}
-	}
-
-	out, err := json.MarshalIndent(status, "", "  ")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Failed to marshal status: %v\n", err)
-		return
-	}
-
-	fmt.Println(string(out))
-}
-
-func readCPUUsage() string {
-	file, err := os.Open("/proc/stat")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Cannot open /proc/stat: %v\n", err)
-		return "unavailable"
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "cpu ") {
-			fields := strings.Fields(line)
-			if len(fields) < 5 {
-				fmt.Fprintf(os.Stderr, "[AGENT ERROR] /proc/stat malformed: not enough fields\n")
-				return "invalid"
-			}
-
-			user, err1 := strconv.ParseFloat(fields[1], 64)
-			nice, err2 := strconv.ParseFloat(fields[2], 64)
-			system, err3 := strconv.ParseFloat(fields[3], 64)
-			idle, err4 := strconv.ParseFloat(fields[4], 64)
-			if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
-				fmt.Fprintf(os.Stderr, "[AGENT ERROR] Error parsing CPU fields\n")
-				return "invalid"
-			}
-
-			total := user + nice + system + idle
-			if total == 0 {
-				return "0.00%"
-			}
-			usage := ((user + nice + system) / total) * 100.0
-			return fmt.Sprintf("%.2f%%", usage)
-		}
-	}
-
-	fmt.Fprintf(os.Stderr, "[AGENT ERROR] No 'cpu ' line found in /proc/stat\n")
-	return "not found"
-}
-
-func readMemUsage() string {
-	file, err := os.Open("/proc/meminfo")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Cannot open /proc/meminfo: %v\n", err)
-		return "unavailable"
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	memTotal := 0.0
-	memAvailable := 0.0
-	foundTotal := false
-	foundAvail := false
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "MemTotal:") {
-			fields := strings.Fields(line)
-			if len(fields) >= 2 {
-				if v, err := strconv.ParseFloat(fields[1], 64); err == nil {
-					memTotal = v
-					foundTotal = true
-				}
-			}
-		}
-		if strings.HasPrefix(line, "MemAvailable:") {
-			fields := strings.Fields(line)
-			if len(fields) >= 2 {
-				if v, err := strconv.ParseFloat(fields[1], 64); err == nil {
-					memAvailable = v
-					foundAvail = true
-				}
-			}
-		}
-	}
-
-	if !foundTotal || !foundAvail || memTotal == 0 {
-		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Missing or invalid MemTotal/MemAvailable\n")
-		return "invalid"
-	}
-
-	used := memTotal - memAvailable
-	usage := (used / memTotal) * 100.0
-	return fmt.Sprintf("%.2f%%", usage)
-}
+// cmd/agent/main.go
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/jaiderssjgod/agent-node-status/auth"
+	"github.com/jaiderssjgod/agent-node-status/buffer"
+	"github.com/jaiderssjgod/agent-node-status/config"
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+	"github.com/jaiderssjgod/agent-node-status/heartbeat/stream"
+	"github.com/jaiderssjgod/agent-node-status/mqttclient"
+	"github.com/jaiderssjgod/agent-node-status/relay"
+	"github.com/jaiderssjgod/agent-node-status/sender"
+	"github.com/jaiderssjgod/agent-node-status/sensors"
+	"github.com/jaiderssjgod/agent-node-status/streamclient"
+)
+
+// replayBatchSize es el máximo de muestras por petición de replay.
+const replayBatchSize = 100
+
+// version se fija en el build con -ldflags "-X main.version=...".
+var version = "dev"
+
+// localCfg es la configuración local: valores por defecto, fichero
+// -config/AGENT_CONFIG, variables de entorno y flags.
+var localCfg config.Config
+
+// effectiveCfg es localCfg con la configuración remota de la última
+// respuesta del operador. La escribe la goroutine de heartbeat y la leen
+// ambas goroutines.
+var effectiveCfg atomic.Pointer[config.Config]
+
+// currentConfig devuelve la configuración efectiva.
+func currentConfig() config.Config {
+	return *effectiveCfg.Load()
+}
+
+// cpuSampler mide la CPU entre heartbeats.
+var cpuSampler = &sensors.CPUSampler{}
+
+// pending guarda los heartbeats que no se pudieron entregar hasta
+// reenviarlos al operador.
+var pending *buffer.Ring
+
+// snd entrega heartbeats y replays al operador con backoff y circuit
+// breaker.
+var snd *sender.Sender
+
+// strm envía los heartbeats por el stream gRPC del operador con transport
+// grpc; nil con http.
+var strm *streamclient.Client
+
+// pub publica los heartbeats en el broker MQTT con transport mqtt; nil con
+// http y grpc.
+var pub *mqttclient.Publisher
+
+// rly recibe los heartbeats de los agentes vecinos en modo relay; nil si
+// el modo está desactivado.
+var rly *relay.Relay
+
+func main() {
+	fmt.Println("[AGENT] Starting agent...")
+
+	var err error
+	localCfg, err = config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	fmt.Printf("[AGENT] Effective configuration:\n%s", localCfg)
+	effectiveCfg.Store(&localCfg)
+
+	cfg, err := rest.InClusterConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Failed to load cluster config: %v\n", err)
+		panic(err.Error())
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Failed to create clientset: %v\n", err)
+		panic(err.Error())
+	}
+
+	nodeName := localCfg.NodeName
+	fmt.Printf("[AGENT] Running on node: %s\n", nodeName)
+
+	sensors.SysRoot = localCfg.Sensors.SysfsRoot
+	cpuSampler.PerCore = localCfg.Sensors.ReportPerCPU
+	cpuSampler.Cgroup = localCfg.Sensors.ReportCgroupCPU
+
+	if pending, err = buffer.New(localCfg.Buffer.Size, localCfg.Buffer.Path); err != nil {
+		fmt.Fprintf(os.Stderr, "[AGENT ERROR] %v\n", err)
+		panic(err.Error())
+	}
+	if n := pending.Len(); n > 0 {
+		fmt.Printf("[AGENT] %d buffered heartbeats pending replay\n", n)
+	}
+
+	var tlsConfig *tls.Config
+	if t := localCfg.TLS; t != (config.TLS{}) {
+		if tlsConfig, err = auth.TLSConfig(t.CAFile, t.CertFile, t.KeyFile, t.ServerName); err != nil {
+			fmt.Fprintf(os.Stderr, "[AGENT ERROR] Invalid TLS configuration: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	snd = sender.New(localCfg.Heartbeat, tlsConfig)
+	switch localCfg.Auth.Mode {
+	case auth.ModeToken:
+		snd.Authorizer = auth.BearerToken{Path: localCfg.Auth.TokenPath}
+	case auth.ModeHMAC:
+		snd.Authorizer = auth.HMAC{NodeName: nodeName, KeyPath: localCfg.Auth.HMACKeyPath}
+	}
+
+	if localCfg.Transport == config.TransportGRPC {
+		var token streamclient.TokenSource
+		if localCfg.Auth.Mode == auth.ModeToken {
+			token = auth.BearerToken{Path: localCfg.Auth.TokenPath}.Token
+		}
+		if strm, err = streamclient.New(localCfg.OperatorGRPCAddr, tlsConfig, token, localCfg.Heartbeat.Timeout); err != nil {
+			fmt.Fprintf(os.Stderr, "[AGENT ERROR] %v\n", err)
+			os.Exit(2)
+		}
+		// Cerrar el stream de forma ordenada al parar: si no, el operador
+		// tomaría cada reinicio del agente por una caída del nodo
+		go func() {
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+			<-sig
+			fmt.Println("[AGENT] Closing heartbeat stream")
+			strm.Close()
+			os.Exit(0)
+		}()
+	}
+
+	if localCfg.Transport == config.TransportMQTT {
+		if pub, err = newPublisher(nodeName, localCfg.MQTT); err != nil {
+			fmt.Fprintf(os.Stderr, "[AGENT ERROR] Invalid MQTT configuration: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if addr := localCfg.Relay.ListenAddr; addr != "" {
+		replayURL := strings.TrimSuffix(localCfg.OperatorURL, "/") + "/replay"
+		rly = relay.New(localCfg.Relay.MaxNodes)
+		rly.Replay = func(ctx context.Context, req heartbeat.ReplayRequest) error {
+			return snd.PostJSON(ctx, replayURL, req, nil)
+		}
+		// Los vecinos presentan su certificado de nodo y solo reportan ese nodo
+		relayTLS, err := auth.ServerTLSConfig(localCfg.TLS.CertFile, localCfg.TLS.KeyFile, localCfg.Relay.ClientCAFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[AGENT ERROR] Invalid relay TLS configuration: %v\n", err)
+			os.Exit(2)
+		}
+		relayServer := &http.Server{Addr: addr, Handler: rly.Handler(), TLSConfig: relayTLS}
+		go func() {
+			fmt.Printf("[AGENT] Relaying heartbeats of neighbor agents on %s (HTTPS, client certificates required)\n", addr)
+			if err := relayServer.ListenAndServeTLS("", ""); err != nil {
+				fmt.Fprintf(os.Stderr, "[AGENT ERROR] Relay server stopped: %v\n", err)
+			}
+		}()
+	}
+
+	// Goroutine independiente para el heartbeat (cada heartbeat.interval)
+	go runHeartbeatLoop(nodeName, localCfg.OperatorURL)
+
+	// Loop principal de monitoreo (cada checkInterval)
+	for {
+		fmt.Println("[AGENT] Monitoring node...")
+		monitorNode(clientset, nodeName)
+		time.Sleep(localCfg.CheckInterval)
+	}
+}
+
+// newPublisher crea el Publisher de transport mqtt a partir de su
+// configuración.
+func newPublisher(nodeName string, m config.MQTT) (*mqttclient.Publisher, error) {
+	opts := mqttclient.Options{
+		Broker:      m.Broker,
+		TopicPrefix: m.TopicPrefix,
+		ClientID:    m.ClientID,
+		Username:    m.Username,
+		Timeout:     localCfg.Heartbeat.Timeout,
+	}
+	if opts.ClientID == "" {
+		opts.ClientID = "edge-agent-" + nodeName
+	}
+	if m.PasswordFile != "" {
+		password, err := os.ReadFile(m.PasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.Password = strings.TrimSpace(string(password))
+	}
+	if m.CAFile != "" || m.CertFile != "" {
+		var err error
+		if opts.TLSConfig, err = auth.TLSConfig(m.CAFile, m.CertFile, m.KeyFile, ""); err != nil {
+			return nil, err
+		}
+	}
+	fmt.Printf("[AGENT] Publishing heartbeats to MQTT broker %s\n", m.Broker)
+	return mqttclient.New(nodeName, opts)
+}
+
+// runHeartbeatLoop envía un heartbeat al operador cada heartbeat.interval
+// de la configuración efectiva, tras un retardo inicial aleatorio. Corre en
+// su propia goroutine para ser independiente del ciclo de monitoreo.
+func runHeartbeatLoop(nodeName, operatorURL string) {
+	// Tras un reinicio masivo los agentes no deben llegar al operador a la vez
+	if delay := snd.StartupDelay(); delay > 0 {
+		fmt.Printf("[AGENT] Delaying first heartbeat by %s\n", delay.Round(time.Millisecond))
+		time.Sleep(delay)
+	}
+
+	// La primera medida de CPU cubre el intervalo hasta el primer heartbeat
+	if err := cpuSampler.Prime(); err != nil {
+		fmt.Fprintf(os.Stderr, "[AGENT ERROR] %v\n", err)
+	}
+
+	interval := currentConfig().Heartbeat.Interval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Sin stream commands es nil y nunca está listo
+	var commands <-chan stream.Command
+	if strm != nil {
+		commands = strm.Commands()
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			sendHeartbeat(nodeName, operatorURL)
+		case cmd := <-commands:
+			// Se aplican en esta goroutine, la única que escribe effectiveCfg
+			if cmd.Type == stream.CommandApplyConfig {
+				applyRemoteConfig(cmd.Config)
+			}
+		}
+		// La policy puede haber cambiado el intervalo
+		if next := currentConfig().Heartbeat.Interval; next != interval {
+			interval = next
+			ticker.Reset(interval)
+		}
+	}
+}
+
+// sendHeartbeat construye y envía el payload de heartbeat al operador. Si
+// el envío falla, o el sender está esperando tras un fallo, lo guarda en
+// pending; si tiene éxito aplica la configuración remota de la respuesta y
+// reenvía lo pendiente.
+func sendHeartbeat(nodeName, operatorURL string) {
+	sc := currentConfig().Sensors
+	resources, errs := sensors.Collect(cpuSampler, sensors.Options{
+		DiskMounts:   sc.DiskMounts,
+		Pressure:     sc.ReportPressure,
+		Temperatures: sc.ReportTemperatures,
+		Power:        sc.ReportPower,
+	})
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "[AGENT ERROR] %v\n", err)
+	}
+	// Aunque los sensores fallen el heartbeat se envía: sin Resources el
+	// operador solo lo usa como señal de vida
+	payload := heartbeat.Payload{
+		Version:      heartbeat.CurrentVersion,
+		NodeName:     nodeName,
+		Timestamp:    time.Now().UTC(),
+		Resources:    resources,
+		AgentVersion: version,
+	}
+
+	var remote *heartbeat.AgentConfig
+	var err error
+	switch {
+	case rly != nil:
+		remote, err = sendBatch(payload, operatorURL)
+	case pub != nil:
+		// Sin respuesta: remote queda nil y rige la configuración local
+		err = pub.Publish(payload)
+	case strm != nil:
+		var resp *heartbeat.Response
+		if resp, err = strm.Send(payload); resp != nil {
+			remote = resp.Config
+		}
+	default:
+		var resp heartbeat.Response
+		err = snd.PostJSON(context.Background(), operatorURL, payload, &resp)
+		remote = resp.Config
+	}
+	if errors.Is(err, sender.ErrInvalidResponse) {
+		// El heartbeat llegó: no se guarda para reenviarlo
+		fmt.Fprintf(os.Stderr, "[AGENT WARN] Heartbeat sent but %v\n", err)
+		err = nil
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, sender.ErrRejected):
+			fmt.Fprintf(os.Stderr, "[AGENT ERROR] Heartbeat rejected: %v\n", err)
+			return
+		case errors.Is(err, sender.ErrBackoff), errors.Is(err, sender.ErrCircuitOpen):
+			fmt.Printf("[AGENT] Heartbeat buffered: %v\n", err)
+		default:
+			fmt.Fprintf(os.Stderr, "[AGENT ERROR] Failed to send heartbeat: %v\n", err)
+		}
+		if err := pending.Push(payload); err != nil {
+			fmt.Fprintf(os.Stderr, "[AGENT ERROR] %v\n", err)
+		}
+		return
+	}
+
+	fmt.Printf("[AGENT] Heartbeat sent for node %s at %s\n", nodeName, payload.Timestamp.Format(time.RFC3339))
+	applyRemoteConfig(remote)
+
+	replayURL := strings.TrimSuffix(operatorURL, "/") + "/replay"
+	replay := func(req heartbeat.ReplayRequest) error {
+		return snd.PostJSON(context.Background(), replayURL, req, nil)
+	}
+	if pub != nil {
+		replay = pub.PublishReplay
+	}
+	replayPending(nodeName, replay)
+}
+
+// sendBatch envía payload junto con los heartbeats pendientes de los
+// vecinos en un lote comprimido a operatorURL/batch y devuelve la
+// configuración remota del propio nodo. Si el lote no llega, los de los
+// vecinos vuelven a pendientes para el siguiente.
+func sendBatch(payload heartbeat.Payload, operatorURL string) (*heartbeat.AgentConfig, error) {
+	neighbors := rly.Drain()
+	req := heartbeat.BatchRequest{Heartbeats: append([]heartbeat.Payload{payload}, neighbors...)}
+	batchURL := strings.TrimSuffix(operatorURL, "/") + "/batch"
+
+	var resp heartbeat.BatchResponse
+	err := snd.PostJSONGzip(context.Background(), batchURL, req, &resp)
+	if err == nil && len(resp.Results) != len(req.Heartbeats) {
+		err = fmt.Errorf("%w: %d results for %d heartbeats", sender.ErrInvalidResponse, len(resp.Results), len(req.Heartbeats))
+	}
+	if err != nil {
+		// Entregado sin resultados legibles o rechazado entero: reintentarlo
+		// no ayudaría
+		if !errors.Is(err, sender.ErrInvalidResponse) && !errors.Is(err, sender.ErrRejected) {
+			rly.Requeue(neighbors)
+		}
+		return nil, err
+	}
+
+	if len(neighbors) > 0 {
+		fmt.Printf("[AGENT] Relayed heartbeats of %d neighbor nodes\n", len(neighbors))
+	}
+	rly.Results(resp.Results[1:])
+	if own := resp.Results[0]; own.Status != heartbeat.StatusOK {
+		return nil, fmt.Errorf("%w: %s: %s", sender.ErrRejected, own.Status, own.Error)
+	}
+	return resp.Results[0].Config, nil
+}
+
+// replayPending reenvía con replay en lotes las muestras de pending, de la
+// más antigua a la más reciente. Cada lote se retira solo cuando replay lo
+// confirma, así un fallo a mitad conserva el resto.
+func replayPending(nodeName string, replay func(heartbeat.ReplayRequest) error) {
+	for pending.Len() > 0 {
+		samples := pending.Peek(replayBatchSize)
+		req := heartbeat.ReplayRequest{NodeName: nodeName, Samples: samples}
+		sendErr := replay(req)
+		// Un lote rechazado se descarta: bloquearía el buffer para siempre
+		if sendErr != nil && !errors.Is(sendErr, sender.ErrRejected) {
+			fmt.Fprintf(os.Stderr, "[AGENT WARN] Failed to replay %d buffered heartbeats: %v\n", len(samples), sendErr)
+			return
+		}
+		if err := pending.Discard(len(samples)); err != nil {
+			fmt.Fprintf(os.Stderr, "[AGENT ERROR] %v\n", err)
+			return
+		}
+		if sendErr != nil {
+			fmt.Fprintf(os.Stderr, "[AGENT WARN] Dropped %d buffered heartbeats: %v\n", len(samples), sendErr)
+			continue
+		}
+		fmt.Printf("[AGENT] Replayed %d buffered heartbeats (%d pending)\n", len(samples), pending.Len())
+	}
+}
+
+// applyRemoteConfig recalcula la configuración efectiva a partir de la
+// local y la que el operador envió, y la aplica si cambió. Sin
+// configuración remota vuelve a la local.
+func applyRemoteConfig(remote *heartbeat.AgentConfig) {
+	next, err := localCfg.WithRemote(remote)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[AGENT WARN] Ignoring remote configuration: %v\n", err)
+		return
+	}
+	if reflect.DeepEqual(next, currentConfig()) {
+		return
+	}
+
+	effectiveCfg.Store(&next)
+	cpuSampler.PerCore = next.Sensors.ReportPerCPU
+	cpuSampler.Cgroup = next.Sensors.ReportCgroupCPU
+	if remote != nil {
+		fmt.Printf("[AGENT] Applied configuration from policy %s:\n%s", remote.Policy, next)
+	} else {
+		fmt.Printf("[AGENT] No policy configures this node, reverted to local configuration:\n%s", next)
+	}
+}
+
+func monitorNode(clientset *kubernetes.Clientset, nodeName string) {
+	ctx := context.Background()
+	cfg := currentConfig()
+
+	node, err := clientset.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Error fetching node: %v\n", err)
+		return
+	}
+
+	if v, ok := node.Labels[cfg.NodeTypeLabel]; !ok || v != cfg.NodeTypeValue {
+		fmt.Printf(
+			"[AGENT] Node %s is not labeled as '%s=%s' (label is '%s'), skipping\n",
+			node.Name, cfg.NodeTypeLabel, cfg.NodeTypeValue, v,
+		)
+		return
+	}
+
+	pods, err := clientset.CoreV1().Pods("").List(
+		ctx,
+		metav1.ListOptions{
+			FieldSelector: fmt.Sprintf("spec.nodeName=%s", nodeName),
+		},
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Error listing pods: %v\n", err)
+		return
+	}
+
+	cpuUsage := readCPUUsage()
+	memUsage := readMemUsage()
+
+	status := struct {
+		Time         time.Time `json:"time"`
+		Node         string    `json:"node"`
+		NodeType     string    `json:"node_type"`
+		CPU          string    `json:"cpu"`
+		Mem          string    `json:"memory"`
+		BatteryLevel int       `json:"battery_level,omitempty"`
+		OnBattery    bool      `json:"on_battery,omitempty"`
+		CriticalPods int       `json:"critical_pods"`
+	}{
+		Time:         time.Now(),
+		Node:         node.Name,
+		NodeType:     node.Labels[cfg.NodeTypeLabel],
+		CPU:          cpuUsage,
+		Mem:          memUsage,
+		CriticalPods: 0,
+	}
+
+	// Solo se lee la batería si la política pide reportarla
+	if cfg.Sensors.ReportPower {
+		if power, err := sensors.ReadPower(); err != nil {
+			fmt.Fprintf(os.Stderr, "[AGENT ERROR] %v\n", err)
+		} else if power != nil {
+			status.BatteryLevel = int(power.BatteryPercent)
+			status.OnBattery = power.OnBattery
+		}
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Labels[cfg.CriticalLabelKey] == "true" && pod.Status.Phase == corev1.PodRunning {
+			status.CriticalPods++
+		}
+	}
+
+	out, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[AGENT ERROR] Failed to marshal status: %v\n", err)
+		return
+	}
+
+	fmt.Println(string(out))
+}
+
+// readCPUUsage devuelve, formateado para el estado local, el uso de CPU
+// medido en el último heartbeat.
+func readCPUUsage() string {
+	return heartbeat.FormatPercent(cpuSampler.Last().Percent)
+}
+
+// readMemUsage devuelve el uso de memoria formateado para el estado local.
+func readMemUsage() string {
+	used, total, err := sensors.ReadMemory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[AGENT ERROR] %v\n", err)
+		return "unavailable"
+	}
+	return heartbeat.FormatPercent(float64(used) / float64(total) * 100.0)
+}
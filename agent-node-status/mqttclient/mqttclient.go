@@ -0,0 +1,151 @@
+// Package mqttclient publica los heartbeats del agente en un broker MQTT en
+// lugar de enviarlos al operador, para nodos IoT que solo alcanzan el
+// broker. El operador se suscribe al mismo árbol de topics (paquete
+// heartbeat): heartbeats en <prefijo>/<nodo> y replays en
+// <prefijo>/<nodo>/replay.
+package mqttclient
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+)
+
+// qos es la QoS de las publicaciones: Publish vuelve cuando el broker
+// confirma el mensaje, no cuando lo procesa el operador.
+const qos = 1
+
+// ErrNotConnected indica que el cliente aún no ha conectado con el broker,
+// o está reconectando.
+var ErrNotConnected = errors.New("not connected to the MQTT broker")
+
+// Options configura la conexión con el broker.
+type Options struct {
+	// Broker es la URL del broker, p. ej. tcp://mosquitto:1883.
+	Broker string
+	// TopicPrefix es la raíz del árbol de topics; vacío usa
+	// heartbeat.DefaultMQTTTopicPrefix.
+	TopicPrefix string
+	// ClientID identifica al agente ante el broker.
+	ClientID string
+	// Username y Password, si no están vacíos, autentican ante el broker.
+	Username string
+	Password string
+	// TLSConfig se usa con brokers ssl://; nil verifica el broker con las
+	// CAs del sistema. Si no fija ServerName se toma de Broker.
+	TLSConfig *tls.Config
+	// Timeout acota la espera de la confirmación de cada mensaje.
+	Timeout time.Duration
+}
+
+// Publisher publica los heartbeats de un nodo. Conecta en segundo plano y
+// reconecta solo; mientras no hay conexión Publish falla en el acto, para
+// que el agente guarde el heartbeat y lo reenvíe después.
+type Publisher struct {
+	client   mqtt.Client
+	prefix   string
+	nodeName string
+	timeout  time.Duration
+}
+
+// New crea un Publisher para nodeName y empieza a conectar con el broker.
+func New(nodeName string, opts Options) (*Publisher, error) {
+	tlsConfig, err := brokerTLSConfig(opts.Broker, opts.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	p := &Publisher{
+		prefix:   opts.TopicPrefix,
+		nodeName: nodeName,
+		timeout:  opts.Timeout,
+	}
+	if p.prefix == "" {
+		p.prefix = heartbeat.DefaultMQTTTopicPrefix
+	}
+
+	clientOpts := mqtt.NewClientOptions().
+		AddBroker(opts.Broker).
+		SetClientID(opts.ClientID).
+		SetUsername(opts.Username).
+		SetPassword(opts.Password).
+		SetTLSConfig(tlsConfig).
+		// Lo no confirmado ya está en el buffer del agente: la sesión no
+		// necesita conservarlo
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetOnConnectHandler(func(mqtt.Client) {
+			fmt.Printf("[AGENT] Connected to MQTT broker %s\n", opts.Broker)
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			fmt.Fprintf(os.Stderr, "[AGENT WARN] Lost connection to MQTT broker, reconnecting: %v\n", err)
+		})
+	p.client = mqtt.NewClient(clientOpts)
+	// Con ConnectRetry el token no se completa hasta conectar
+	p.client.Connect()
+	return p, nil
+}
+
+// brokerTLSConfig devuelve la configuración TLS de broker, o nil si no usa
+// TLS. El cliente MQTT no deduce el nombre del servidor de la URL.
+func brokerTLSConfig(broker string, cfg *tls.Config) (*tls.Config, error) {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT broker URL: %w", err)
+	}
+	switch u.Scheme {
+	case "ssl", "tls", "mqtts", "tcps", "wss":
+	default:
+		return nil, nil
+	}
+	if cfg == nil {
+		cfg = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if cfg.ServerName == "" {
+		cfg = cfg.Clone()
+		cfg.ServerName = u.Hostname()
+	}
+	return cfg, nil
+}
+
+// Publish publica payload en el topic del nodo.
+func (p *Publisher) Publish(payload heartbeat.Payload) error {
+	return p.publish(heartbeat.MQTTTopic(p.prefix, p.nodeName), payload)
+}
+
+// PublishReplay publica req en el topic de replay del nodo.
+func (p *Publisher) PublishReplay(req heartbeat.ReplayRequest) error {
+	return p.publish(heartbeat.MQTTReplayTopic(p.prefix, p.nodeName), req)
+}
+
+func (p *Publisher) publish(topic string, v any) error {
+	if !p.client.IsConnectionOpen() {
+		return ErrNotConnected
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	token := p.client.Publish(topic, qos, false, body)
+	if !token.WaitTimeout(p.timeout) {
+		return fmt.Errorf("timed out waiting for the MQTT broker to acknowledge %s", topic)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Close se desconecta del broker tras entregar lo que esté en vuelo.
+func (p *Publisher) Close() {
+	p.client.Disconnect(uint(p.timeout / time.Millisecond))
+}
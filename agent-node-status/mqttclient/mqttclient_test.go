@@ -0,0 +1,95 @@
+package mqttclient
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	mqttbroker "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+)
+
+type message struct {
+	topic string
+	body  []byte
+}
+
+func TestPublisher_PublishesToTheNodeTopics(t *testing.T) {
+	broker := mqttbroker.New(&mqttbroker.Options{InlineClient: true})
+	if err := broker.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	if err := broker.AddListener(tcp); err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+
+	received := make(chan message, 4)
+	err := broker.Subscribe(heartbeat.DefaultMQTTTopicPrefix+"/#", 1, func(_ *mqttbroker.Client, _ packets.Subscription, pk packets.Packet) {
+		received <- message{pk.TopicName, pk.Payload}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := New("n1", Options{Broker: "tcp://" + tcp.Address(), ClientID: "edge-agent-n1", Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for !p.client.IsConnectionOpen() {
+		if time.Now().After(deadline) {
+			t.Fatal("el publisher no conectó con el broker")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := p.Publish(heartbeat.Payload{NodeName: "n1", Timestamp: time.Now().UTC()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.PublishReplay(heartbeat.ReplayRequest{NodeName: "n1", Samples: []heartbeat.Payload{{NodeName: "n1"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"edge/heartbeat/n1", "edge/heartbeat/n1/replay"} {
+		select {
+		case msg := <-received:
+			var v struct{ NodeName string }
+			if msg.topic != want || json.Unmarshal(msg.body, &v) != nil || v.NodeName != "n1" {
+				t.Errorf("mensaje en %s = %s, se esperaba uno de n1 en %s", msg.topic, msg.body, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no llegó el mensaje de %s", want)
+		}
+	}
+}
+
+func TestPublisher_FailsAtOnceWithoutBroker(t *testing.T) {
+	// Un puerto sin nadie escuchando
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	p, err := New("n1", Options{Broker: "tcp://" + addr, ClientID: "edge-agent-n1", Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := p.Publish(heartbeat.Payload{NodeName: "n1"}); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Publish sin broker: err = %v, se esperaba ErrNotConnected", err)
+	}
+}
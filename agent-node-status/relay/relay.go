@@ -0,0 +1,235 @@
+// Package relay implementa el modo relay del agente: en un sitio remoto una
+// pasarela recibe los heartbeats de los agentes vecinos y los envía al
+// operador junto con el suyo, en un solo lote comprimido por intervalo.
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+	"github.com/jaiderssjgod/agent-node-status/sender"
+)
+
+// DefaultMaxNodes es el número de vecinos que acepta un relay por defecto.
+const DefaultMaxNodes = 100
+
+// maxHeartbeatBytes y maxReplayBytes limitan los cuerpos, como el operador.
+const (
+	maxHeartbeatBytes = 1 << 20
+	maxReplayBytes    = 8 << 20
+)
+
+// Relay recibe heartbeats de los vecinos con el mismo protocolo que el
+// operador, así que un vecino solo tiene que apuntar su operatorURL al
+// relay. De cada vecino guarda el último heartbeat hasta el siguiente lote:
+// el operador solo usa el más reciente.
+//
+// Cada vecino se autentica ante el relay con su certificado de cliente
+// (mTLS): el Common Name, "<nodo>" o "system:node:<nodo>", debe ser el nodo
+// que reporta. El relay se autentica ante el operador, que debe permitirle
+// reportar a esos vecinos. Es seguro para uso concurrente.
+type Relay struct {
+	maxNodes int
+	// Replay reenvía al operador el replay de un vecino. Si es nil los
+	// replays se rechazan con 503 y el vecino los conserva.
+	Replay func(ctx context.Context, req heartbeat.ReplayRequest) error
+
+	mu      sync.Mutex
+	pending map[string]heartbeat.Payload
+	// configs es la configuración remota de cada vecino, recibida en el
+	// último lote, que se le devuelve en la respuesta a su heartbeat.
+	configs map[string]*heartbeat.AgentConfig
+}
+
+// New crea un Relay que acepta heartbeats de hasta maxNodes vecinos.
+func New(maxNodes int) *Relay {
+	if maxNodes <= 0 {
+		maxNodes = DefaultMaxNodes
+	}
+	return &Relay{
+		maxNodes: maxNodes,
+		pending:  make(map[string]heartbeat.Payload),
+		configs:  make(map[string]*heartbeat.AgentConfig),
+	}
+}
+
+// nodeCertPrefix es el prefijo del CN de los certificados de kubelet, que
+// el operador también acepta.
+const nodeCertPrefix = "system:node:"
+
+// Handler sirve POST /heartbeat y POST /heartbeat/replay. Se debe servir
+// con TLS que verifique los certificados de cliente, p. ej. con
+// auth.ServerTLSConfig; sin certificado verificado responde 401.
+func (r *Relay) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/heartbeat", r.handleHeartbeat)
+	mux.HandleFunc("/heartbeat/replay", r.handleReplay)
+	return mux
+}
+
+func (r *Relay) handleHeartbeat(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var payload heartbeat.Payload
+	if err := decode(w, req, maxHeartbeatBytes, &payload); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if !authorize(w, req, payload.NodeName) {
+		return
+	}
+	// Se valida aquí para que el vecino sepa que su heartbeat no llegará
+	if err := payload.Normalize(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	config, err := r.add(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(heartbeat.Response{Status: heartbeat.StatusOK, Config: config})
+}
+
+// handleReplay reenvía el replay al operador en el momento: el vecino solo
+// retira sus muestras cuando el operador las confirma.
+func (r *Relay) handleReplay(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var replay heartbeat.ReplayRequest
+	if err := decode(w, req, maxReplayBytes, &replay); err != nil || replay.NodeName == "" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	// El operador comprueba que las muestras son de replay.NodeName
+	if !authorize(w, req, replay.NodeName) {
+		return
+	}
+	if r.Replay == nil {
+		http.Error(w, "relay does not forward replays", http.StatusServiceUnavailable)
+		return
+	}
+
+	err := r.Replay(req.Context(), replay)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	case errors.Is(err, sender.ErrRejected):
+		// El vecino descarta el lote, igual que si lo rechazara el operador
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+}
+
+// authorize comprueba que el certificado verificado del vecino es el de
+// nodeName y, si no, responde 401 o 403.
+func authorize(w http.ResponseWriter, req *http.Request, nodeName string) bool {
+	// VerifiedChains solo se rellena si la CA de clientes aceptó el
+	// certificado; PeerCertificates sin verificar no valen
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		http.Error(w, "missing verified client certificate", http.StatusUnauthorized)
+		return false
+	}
+	cn := req.TLS.VerifiedChains[0][0].Subject.CommonName
+	if node := strings.TrimPrefix(cn, nodeCertPrefix); node != nodeName {
+		fmt.Fprintf(os.Stderr, "[AGENT WARN] Rejected heartbeat of node %s from neighbor certificate %q\n", nodeName, cn)
+		http.Error(w, fmt.Sprintf("certificate %q does not belong to node %q", cn, nodeName), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func decode(w http.ResponseWriter, req *http.Request, limit int64, v any) error {
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, limit))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// add guarda payload si es más reciente que el pendiente del mismo nodo y
+// devuelve la configuración remota del nodo.
+func (r *Relay) add(payload heartbeat.Payload) (*heartbeat.AgentConfig, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, ok := r.pending[payload.NodeName]
+	if !ok && len(r.pending) >= r.maxNodes {
+		return nil, fmt.Errorf("relay already has heartbeats of %d nodes", r.maxNodes)
+	}
+	if !ok || !payload.Timestamp.Before(prev.Timestamp) {
+		r.pending[payload.NodeName] = payload
+	}
+	return r.configs[payload.NodeName], nil
+}
+
+// Drain retira y devuelve los heartbeats pendientes, ordenados por nodo.
+func (r *Relay) Drain() []heartbeat.Payload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make([]heartbeat.Payload, 0, len(r.pending))
+	for _, p := range r.pending {
+		out = append(out, p)
+	}
+	clear(r.pending)
+	sort.Slice(out, func(i, j int) bool { return out[i].NodeName < out[j].NodeName })
+	return out
+}
+
+// Requeue devuelve a pendientes los heartbeats de un lote que no llegó al
+// operador, salvo los de nodos que ya enviaron uno más reciente.
+func (r *Relay) Requeue(payloads []heartbeat.Payload) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range payloads {
+		prev, ok := r.pending[p.NodeName]
+		if ok && !p.Timestamp.After(prev.Timestamp) {
+			continue
+		}
+		if !ok && len(r.pending) >= r.maxNodes {
+			continue
+		}
+		r.pending[p.NodeName] = p
+	}
+}
+
+// Results procesa los resultados del operador para los heartbeats de los
+// vecinos: guarda la configuración remota de los aceptados y avisa de los
+// rechazados.
+func (r *Relay) Results(results []heartbeat.BatchResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, res := range results {
+		if res.Status != heartbeat.StatusOK {
+			fmt.Fprintf(os.Stderr, "[AGENT WARN] Operator %s relayed heartbeat of node %s: %s\n",
+				res.Status, res.NodeName, res.Error)
+			continue
+		}
+		if res.Config != nil {
+			r.configs[res.NodeName] = res.Config
+		} else {
+			delete(r.configs, res.NodeName)
+		}
+	}
+}
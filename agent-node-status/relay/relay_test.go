@@ -0,0 +1,132 @@
+package relay
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+	"github.com/jaiderssjgod/agent-node-status/sender"
+)
+
+// post envía body a path como el vecino cuyo certificado verificado tiene
+// el CN cn; un cn vacío envía la petición sin certificado.
+func post(t *testing.T, h http.Handler, cn, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if cn != "" {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func beat(node string, ts time.Time) string {
+	return fmt.Sprintf(`{"version":2,"nodeName":%q,"timestamp":%q}`, node, ts.Format(time.RFC3339))
+}
+
+func TestRelay_KeepsLatestPerNodeAndReturnsItsConfig(t *testing.T) {
+	r := New(2)
+	h := r.Handler()
+	t0 := time.Now().UTC().Truncate(time.Second)
+
+	for _, b := range []struct {
+		node string
+		ts   time.Time
+	}{{"n2", t0}, {"n1", t0.Add(time.Second)}, {"n1", t0}} {
+		if rec := post(t, h, b.node, "/heartbeat", beat(b.node, b.ts)); rec.Code != http.StatusOK {
+			t.Fatalf("status %d (%s)", rec.Code, rec.Body.String())
+		}
+	}
+	if rec := post(t, h, "n3", "/heartbeat", beat("n3", t0)); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("un vecino por encima de maxNodes: status %d, se esperaba 503", rec.Code)
+	}
+	if rec := post(t, h, "n1", "/heartbeat", `{"version":2,"nodeName":"n1","resources":{"cpuPercent":140}}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("un heartbeat inválido: status %d, se esperaba 400", rec.Code)
+	}
+
+	drained := r.Drain()
+	if len(drained) != 2 || drained[0].NodeName != "n1" || !drained[0].Timestamp.Equal(t0.Add(time.Second)) {
+		t.Fatalf("Drain = %+v, se esperaba el último de n1 y el de n2", drained)
+	}
+	if len(r.Drain()) != 0 {
+		t.Error("Drain debe vaciar los pendientes")
+	}
+
+	// Un lote fallido no pisa un heartbeat más reciente llegado después
+	post(t, h, "n2", "/heartbeat", beat("n2", t0.Add(time.Minute)))
+	r.Requeue(drained)
+	requeued := r.Drain()
+	if len(requeued) != 2 || !requeued[1].Timestamp.Equal(t0.Add(time.Minute)) {
+		t.Errorf("tras Requeue = %+v, se esperaba conservar el heartbeat nuevo de n2", requeued)
+	}
+
+	r.Results([]heartbeat.BatchResult{
+		{NodeName: "n1", Status: heartbeat.StatusOK, Config: &heartbeat.AgentConfig{Policy: "p", HeartbeatIntervalSeconds: 30}},
+		{NodeName: "n2", Status: heartbeat.StatusForbidden, Error: "not a relay"},
+	})
+	var resp heartbeat.Response
+	json.Unmarshal(post(t, h, "system:node:n1", "/heartbeat", beat("n1", t0.Add(time.Hour))).Body.Bytes(), &resp)
+	if resp.Config == nil || resp.Config.HeartbeatIntervalSeconds != 30 {
+		t.Errorf("respuesta a n1 = %+v, se esperaba la configuración de su policy", resp)
+	}
+}
+
+func TestRelay_ForwardsReplays(t *testing.T) {
+	r := New(0)
+	h := r.Handler()
+	body := `{"nodeName":"n1","samples":[]}`
+
+	if rec := post(t, h, "n1", "/heartbeat/replay", body); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("sin Replay: status %d, se esperaba 503", rec.Code)
+	}
+
+	var got heartbeat.ReplayRequest
+	var err error
+	r.Replay = func(_ context.Context, req heartbeat.ReplayRequest) error {
+		got = req
+		return err
+	}
+	if rec := post(t, h, "n1", "/heartbeat/replay", body); rec.Code != http.StatusOK || got.NodeName != "n1" {
+		t.Errorf("status %d, reenviado %+v; se esperaba 200 y el replay de n1", rec.Code, got)
+	}
+
+	err = fmt.Errorf("%w: status 400", sender.ErrRejected)
+	if rec := post(t, h, "n1", "/heartbeat/replay", body); rec.Code != http.StatusBadRequest {
+		t.Errorf("replay rechazado por el operador: status %d, se esperaba 400", rec.Code)
+	}
+	err = fmt.Errorf("connection refused")
+	if rec := post(t, h, "n1", "/heartbeat/replay", body); rec.Code != http.StatusBadGateway {
+		t.Errorf("operador inalcanzable: status %d, se esperaba 502", rec.Code)
+	}
+}
+
+func TestRelay_NeighborsOnlyReportTheirOwnNode(t *testing.T) {
+	r := New(0)
+	r.Replay = func(context.Context, heartbeat.ReplayRequest) error { return nil }
+	h := r.Handler()
+	now := time.Now().UTC()
+
+	if rec := post(t, h, "", "/heartbeat", beat("n1", now)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("sin certificado: status %d, se esperaba 401", rec.Code)
+	}
+	if rec := post(t, h, "n2", "/heartbeat", beat("n1", now)); rec.Code != http.StatusForbidden {
+		t.Errorf("heartbeat de n1 con el certificado de n2: status %d, se esperaba 403", rec.Code)
+	}
+	if rec := post(t, h, "n2", "/heartbeat/replay", `{"nodeName":"n1","samples":[]}`); rec.Code != http.StatusForbidden {
+		t.Errorf("replay de n1 con el certificado de n2: status %d, se esperaba 403", rec.Code)
+	}
+	if got := r.Drain(); len(got) != 0 {
+		t.Errorf("Drain = %+v, no se debía aceptar ningún heartbeat", got)
+	}
+}
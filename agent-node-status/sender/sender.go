@@ -0,0 +1,301 @@
+// Package sender entrega los mensajes del agente al operador con un cliente
+// HTTP dedicado, backoff exponencial con jitter y un circuit breaker, para
+// que cientos de agentes no saturen a un operador lento o recién reiniciado.
+package sender
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrRejected indica que el operador rechazó la petición (4xx):
+	// reenviarla no serviría de nada.
+	ErrRejected = errors.New("rejected by operator")
+	// ErrBackoff indica que la petición no se intentó porque aún no ha
+	// pasado el backoff del último fallo.
+	ErrBackoff = errors.New("backing off after failure")
+	// ErrUnauthorized indica que el operador no aceptó las credenciales del
+	// agente (401 o 403). Cuenta como fallo: suele deberse a un token
+	// caducado o a una clave aún no provisionada, y reintentar tiene sentido.
+	ErrUnauthorized = errors.New("unauthorized by operator")
+	// ErrInvalidResponse indica que el operador aceptó la petición pero su
+	// respuesta no se pudo decodificar.
+	ErrInvalidResponse = errors.New("invalid response from operator")
+	// ErrCircuitOpen indica que la petición no se intentó porque el
+	// circuit breaker está abierto.
+	ErrCircuitOpen = errors.New("circuit breaker open")
+)
+
+// Config son los intervalos del envío. Los campos a cero toman el valor de
+// DefaultConfig.
+type Config struct {
+	// Interval es el periodo entre heartbeats.
+	Interval time.Duration `yaml:"interval"`
+	// Timeout limita cada petición HTTP.
+	Timeout time.Duration `yaml:"timeout"`
+	// StartupJitter es el retardo aleatorio máximo antes del primer
+	// heartbeat, para que los agentes de un despliegue no arranquen a la vez.
+	StartupJitter time.Duration `yaml:"startupJitter"`
+	// BackoffBase es la espera tras el primer fallo; se duplica con cada
+	// fallo consecutivo hasta BackoffMax.
+	BackoffBase time.Duration `yaml:"backoffBase"`
+	BackoffMax  time.Duration `yaml:"backoffMax"`
+	// BreakerThreshold es el número de fallos consecutivos que abre el
+	// circuito durante BreakerCooldown. Pasado ese tiempo se permite una
+	// petición de prueba: si falla el circuito se vuelve a abrir.
+	BreakerThreshold int           `yaml:"breakerThreshold"`
+	BreakerCooldown  time.Duration `yaml:"breakerCooldown"`
+}
+
+// DefaultConfig es la configuración por defecto.
+func DefaultConfig() Config {
+	return Config{
+		Interval:         10 * time.Second,
+		Timeout:          5 * time.Second,
+		StartupJitter:    10 * time.Second,
+		BackoffBase:      2 * time.Second,
+		BackoffMax:       2 * time.Minute,
+		BreakerThreshold: 5,
+		BreakerCooldown:  time.Minute,
+	}
+}
+
+// withDefaults rellena los campos a cero de c.
+func (c Config) withDefaults() Config {
+	d := DefaultConfig()
+	if c.Interval <= 0 {
+		c.Interval = d.Interval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = d.Timeout
+	}
+	if c.StartupJitter < 0 {
+		c.StartupJitter = 0
+	}
+	if c.BackoffBase <= 0 {
+		c.BackoffBase = d.BackoffBase
+	}
+	if c.BackoffMax < c.BackoffBase {
+		c.BackoffMax = max(d.BackoffMax, c.BackoffBase)
+	}
+	if c.BreakerThreshold <= 0 {
+		c.BreakerThreshold = d.BreakerThreshold
+	}
+	if c.BreakerCooldown <= 0 {
+		c.BreakerCooldown = d.BreakerCooldown
+	}
+	return c
+}
+
+// Authorizer añade credenciales a una petición ya construida. body es su
+// cuerpo, para las credenciales que lo firman.
+type Authorizer interface {
+	Authorize(req *http.Request, body []byte) error
+}
+
+// Sender envía peticiones JSON al operador. Es seguro para uso concurrente.
+type Sender struct {
+	cfg    Config
+	client *http.Client
+
+	// Authorizer, si no es nil, autentica cada petición.
+	Authorizer Authorizer
+
+	// now y jitter se sustituyen en los tests.
+	now    func() time.Time
+	jitter func(d time.Duration) time.Duration
+
+	mu sync.Mutex
+	// failures cuenta los fallos consecutivos.
+	failures int
+	// retryAt es el instante antes del cual no se intenta ninguna petición,
+	// por backoff o por circuito abierto.
+	retryAt time.Time
+}
+
+// New crea un Sender con un cliente HTTP propio que reutiliza conexiones
+// entre heartbeats. tlsConfig, si no es nil, configura las conexiones
+// HTTPS: CAs del operador y certificado de cliente.
+func New(cfg Config, tlsConfig *tls.Config) *Sender {
+	cfg = cfg.withDefaults()
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   cfg.Timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:     tlsConfig,
+		MaxIdleConns:        4,
+		MaxIdleConnsPerHost: 4,
+		// Mayor que el intervalo para que la conexión sobreviva entre heartbeats
+		IdleConnTimeout:       cfg.Interval + 30*time.Second,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+	}
+	return &Sender{
+		cfg:    cfg,
+		client: &http.Client{Transport: transport, Timeout: cfg.Timeout},
+		now:    time.Now,
+		jitter: func(d time.Duration) time.Duration { return rand.N(d + 1) },
+	}
+}
+
+// Config devuelve la configuración efectiva.
+func (s *Sender) Config() Config {
+	return s.cfg
+}
+
+// StartupDelay devuelve un retardo aleatorio en [0, StartupJitter].
+func (s *Sender) StartupDelay() time.Duration {
+	if s.cfg.StartupJitter == 0 {
+		return 0
+	}
+	return s.jitter(s.cfg.StartupJitter)
+}
+
+// RetryAt devuelve el instante a partir del cual se volverán a intentar
+// peticiones, o el instante cero si no hay espera pendiente.
+func (s *Sender) RetryAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.retryAt
+}
+
+// PostJSON envía in como JSON a url y falla si el operador no responde 200.
+// Si out no es nil y la respuesta es JSON la decodifica en out; una
+// respuesta de otro tipo, como el "ok" de operadores antiguos, lo deja
+// intacto. Devuelve ErrBackoff o ErrCircuitOpen sin tocar la red si el
+// último fallo es demasiado reciente, envuelve 401 y 403 en
+// ErrUnauthorized, el resto de 4xx en ErrRejected y
+// los errores al decodificar la respuesta en ErrInvalidResponse.
+func (s *Sender) PostJSON(ctx context.Context, url string, in, out any) error {
+	return s.post(ctx, url, in, out, false)
+}
+
+// PostJSONGzip es como PostJSON pero comprime el cuerpo con gzip, para
+// lotes grandes sobre enlaces lentos. Las credenciales firman el cuerpo
+// comprimido, tal como viaja.
+func (s *Sender) PostJSONGzip(ctx context.Context, url string, in, out any) error {
+	return s.post(ctx, url, in, out, true)
+}
+
+func (s *Sender) post(ctx context.Context, url string, in, out any, compress bool) error {
+	if err := s.allow(); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	if compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(body); err != nil {
+			return fmt.Errorf("failed to compress request: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("failed to compress request: %w", err)
+		}
+		body = buf.Bytes()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if s.Authorizer != nil {
+		if err := s.Authorizer.Authorize(req, body); err != nil {
+			return fmt.Errorf("failed to authorize request: %w", err)
+		}
+	}
+
+	err = s.do(req, out)
+	// Un rechazo o una respuesta ilegible demuestran que el operador
+	// responde: no cuentan como fallo
+	s.record(err == nil || errors.Is(err, ErrRejected) || errors.Is(err, ErrInvalidResponse))
+	return err
+}
+
+func (s *Sender) do(req *http.Request, out any) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		if out == nil || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+			return nil
+		}
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+		}
+		return nil
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: status %d", ErrUnauthorized, resp.StatusCode)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return fmt.Errorf("%w: status %d", ErrRejected, resp.StatusCode)
+	default:
+		return fmt.Errorf("non-200 status: %d", resp.StatusCode)
+	}
+}
+
+// allow decide si se puede intentar una petición ahora.
+func (s *Sender) allow() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.now().Before(s.retryAt) {
+		return nil
+	}
+	if s.failures >= s.cfg.BreakerThreshold {
+		return fmt.Errorf("%w until %s", ErrCircuitOpen, s.retryAt.Format(time.RFC3339))
+	}
+	return fmt.Errorf("%w until %s", ErrBackoff, s.retryAt.Format(time.RFC3339))
+}
+
+// record actualiza el backoff y el circuito con el resultado de una petición.
+func (s *Sender) record(ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ok {
+		s.failures = 0
+		s.retryAt = time.Time{}
+		return
+	}
+	s.failures++
+	s.retryAt = s.now().Add(s.backoff())
+}
+
+// backoff devuelve la espera tras s.failures fallos consecutivos: la mitad
+// fija y la otra mitad aleatoria, para que los agentes que fallaron a la
+// vez no reintenten a la vez. Con el circuito abierto es BreakerCooldown.
+func (s *Sender) backoff() time.Duration {
+	d := s.cfg.BreakerCooldown
+	if s.failures < s.cfg.BreakerThreshold {
+		d = s.cfg.BackoffBase
+		for i := 1; i < s.failures && d < s.cfg.BackoffMax; i++ {
+			d *= 2
+		}
+		d = min(d, s.cfg.BackoffMax)
+	}
+	return d/2 + s.jitter(d/2)
+}
@@ -0,0 +1,68 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSender_BackoffAndCircuitBreaker(t *testing.T) {
+	status, calls := http.StatusServiceUnavailable, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	now := time.Unix(1_700_000_000, 0)
+	s := New(Config{BackoffBase: 2 * time.Second, BackoffMax: 8 * time.Second,
+		BreakerThreshold: 4, BreakerCooldown: time.Minute}, nil)
+	s.now = func() time.Time { return now }
+	// Sin aleatoriedad la espera es exactamente la mitad fija
+	s.jitter = func(time.Duration) time.Duration { return 0 }
+	post := func() error { return s.PostJSON(context.Background(), srv.URL, struct{}{}, nil) }
+
+	// Backoff exponencial (mitad fija de 2s, 4s, 8s) antes de abrir el circuito
+	for i, wait := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if err := post(); err == nil || errors.Is(err, ErrBackoff) {
+			t.Fatalf("fallo %d: se esperaba un error de red, got %v", i+1, err)
+		}
+		if err := post(); !errors.Is(err, ErrBackoff) {
+			t.Fatalf("fallo %d: se esperaba ErrBackoff, got %v", i+1, err)
+		}
+		if got := s.RetryAt().Sub(now); got != wait {
+			t.Fatalf("fallo %d: espera %s, se esperaba %s", i+1, got, wait)
+		}
+		now = s.RetryAt()
+	}
+
+	// El cuarto fallo abre el circuito durante BreakerCooldown
+	if err := post(); err == nil {
+		t.Fatal("se esperaba un fallo")
+	}
+	callsBefore := calls
+	if err := post(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("se esperaba ErrCircuitOpen, got %v", err)
+	}
+	if calls != callsBefore {
+		t.Error("con el circuito abierto no se debe tocar la red")
+	}
+
+	// Pasado el cooldown una petición de prueba cierra el circuito; un
+	// rechazo cuenta como operador alcanzable
+	now = s.RetryAt()
+	status = http.StatusBadRequest
+	if err := post(); !errors.Is(err, ErrRejected) {
+		t.Fatalf("se esperaba ErrRejected, got %v", err)
+	}
+	status = http.StatusOK
+	if err := post(); err != nil {
+		t.Fatalf("el circuito debería estar cerrado: %v", err)
+	}
+	if !s.RetryAt().IsZero() {
+		t.Errorf("tras un éxito no debe quedar espera pendiente: %s", s.RetryAt())
+	}
+}
@@ -0,0 +1,106 @@
+package sensors
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CgroupRoot es donde está montado el sistema de ficheros de cgroups.
+var CgroupRoot = "/sys/fs/cgroup"
+
+// cgroupSample es el tiempo de CPU consumido por el cgroup en un instante.
+type cgroupSample struct {
+	usage time.Duration
+	at    time.Time
+	// cpus es el número de CPUs que el cgroup puede usar según su cuota.
+	cpus float64
+}
+
+// readCgroupSample lee el consumo del cgroup, probando primero cgroup v2
+// (cpu.stat, cpu.max) y luego cgroup v1 (cpuacct.usage, cpu.cfs_*).
+func readCgroupSample() (cgroupSample, error) {
+	now := time.Now()
+	if usage, err := readCgroupV2Usage(); err == nil {
+		return cgroupSample{usage: usage, at: now, cpus: cgroupV2CPUs()}, nil
+	}
+	data, err := os.ReadFile(CgroupRoot + "/cpuacct/cpuacct.usage")
+	if err != nil {
+		return cgroupSample{}, fmt.Errorf("no cgroup CPU accounting found: %w", err)
+	}
+	ns, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return cgroupSample{}, fmt.Errorf("parsing cpuacct.usage: %w", err)
+	}
+	return cgroupSample{usage: time.Duration(ns), at: now, cpus: cgroupV1CPUs()}, nil
+}
+
+func readCgroupV2Usage() (time.Duration, error) {
+	file, err := os.Open(CgroupRoot + "/cpu.stat")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "usage_usec" {
+			usec, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("parsing cpu.stat: %w", err)
+			}
+			return time.Duration(usec) * time.Microsecond, nil
+		}
+	}
+	return 0, fmt.Errorf("usage_usec not found in cpu.stat")
+}
+
+// cgroupV2CPUs interpreta cpu.max ("max 100000" o "<quota> <period>").
+func cgroupV2CPUs() float64 {
+	data, err := os.ReadFile(CgroupRoot + "/cpu.max")
+	if err != nil {
+		return float64(runtime.NumCPU())
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 || fields[0] == "max" {
+		return float64(runtime.NumCPU())
+	}
+	return quotaCPUs(fields[0], fields[1])
+}
+
+func cgroupV1CPUs() float64 {
+	quota, err1 := os.ReadFile(CgroupRoot + "/cpu/cpu.cfs_quota_us")
+	period, err2 := os.ReadFile(CgroupRoot + "/cpu/cpu.cfs_period_us")
+	if err1 != nil || err2 != nil {
+		return float64(runtime.NumCPU())
+	}
+	return quotaCPUs(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func quotaCPUs(quota, period string) float64 {
+	q, err1 := strconv.ParseFloat(quota, 64)
+	p, err2 := strconv.ParseFloat(period, 64)
+	if err1 != nil || err2 != nil || q <= 0 || p <= 0 {
+		return float64(runtime.NumCPU())
+	}
+	return q / p
+}
+
+// cgroupPercent es el uso del cgroup entre dos muestras respecto a las CPUs
+// que su cuota le permite usar.
+func cgroupPercent(prev, cur cgroupSample) (float64, bool) {
+	wall := cur.at.Sub(prev.at)
+	if prev.at.IsZero() || wall <= 0 || cur.usage < prev.usage || cur.cpus <= 0 {
+		return 0, false
+	}
+	pct := float64(cur.usage-prev.usage) / (float64(wall) * cur.cpus) * 100
+	if pct > 100 {
+		pct = 100
+	}
+	return pct, true
+}
@@ -0,0 +1,154 @@
+package sensors
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func withCgroupRoot(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	old := CgroupRoot
+	CgroupRoot = root
+	t.Cleanup(func() { CgroupRoot = old })
+	return root
+}
+
+// writeCgroupFile escribe un fichero de control de cgroup bajo root.
+func writeCgroupFile(t *testing.T, root, name, content string) {
+	t.Helper()
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuotaCPUs(t *testing.T) {
+	numCPU := float64(runtime.NumCPU())
+	tests := []struct {
+		quota, period string
+		want          float64
+	}{
+		{"50000", "100000", 0.5},
+		{"200000", "100000", 2},
+		{"-1", "100000", numCPU},
+		{"100000", "0", numCPU},
+		{"max", "100000", numCPU},
+	}
+	for _, tt := range tests {
+		if got := quotaCPUs(tt.quota, tt.period); got != tt.want {
+			t.Errorf("quotaCPUs(%q, %q) = %v, se esperaba %v", tt.quota, tt.period, got, tt.want)
+		}
+	}
+}
+
+func TestReadCgroupSample_V2(t *testing.T) {
+	root := withCgroupRoot(t)
+	writeCgroupFile(t, root, "cpu.stat", "usage_usec 1500000\nuser_usec 1000000\nsystem_usec 500000")
+
+	writeCgroupFile(t, root, "cpu.max", "max 100000")
+	sample, err := readCgroupSample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.usage != 1500*time.Millisecond {
+		t.Errorf("usage = %v, se esperaba 1.5s", sample.usage)
+	}
+	if sample.cpus != float64(runtime.NumCPU()) {
+		t.Errorf("con cpu.max \"max\" se esperaban todas las CPUs, got %v", sample.cpus)
+	}
+
+	writeCgroupFile(t, root, "cpu.max", "150000 100000")
+	if sample, err = readCgroupSample(); err != nil || sample.cpus != 1.5 {
+		t.Errorf("con cuota 150000/100000 se esperaban 1.5 CPUs; got %v, %v", sample.cpus, err)
+	}
+}
+
+func TestReadCgroupSample_V1(t *testing.T) {
+	root := withCgroupRoot(t)
+	writeCgroupFile(t, root, "cpuacct/cpuacct.usage", "2000000000")
+	writeCgroupFile(t, root, "cpu/cpu.cfs_period_us", "100000")
+
+	writeCgroupFile(t, root, "cpu/cpu.cfs_quota_us", "-1")
+	sample, err := readCgroupSample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.usage != 2*time.Second {
+		t.Errorf("usage = %v, se esperaban 2s", sample.usage)
+	}
+	if sample.cpus != float64(runtime.NumCPU()) {
+		t.Errorf("con cuota -1 se esperaban todas las CPUs, got %v", sample.cpus)
+	}
+
+	writeCgroupFile(t, root, "cpu/cpu.cfs_quota_us", "50000")
+	if sample, err = readCgroupSample(); err != nil || sample.cpus != 0.5 {
+		t.Errorf("con cuota 50000/100000 se esperaba media CPU; got %v, %v", sample.cpus, err)
+	}
+}
+
+func TestReadCgroupSample_NoAccounting(t *testing.T) {
+	withCgroupRoot(t)
+	if _, err := readCgroupSample(); err == nil {
+		t.Error("sin cpu.stat ni cpuacct.usage se esperaba un error")
+	}
+}
+
+func TestCgroupPercent(t *testing.T) {
+	at := time.Unix(1000, 0)
+	prev := cgroupSample{usage: 10 * time.Second, at: at, cpus: 2}
+	tests := []struct {
+		name   string
+		prev   cgroupSample
+		cur    cgroupSample
+		want   float64
+		wantOK bool
+	}{
+		{"delta", prev, cgroupSample{usage: 10500 * time.Millisecond, at: at.Add(time.Second), cpus: 2}, 25, true},
+		{"limitado a 100", prev, cgroupSample{usage: 20 * time.Second, at: at.Add(time.Second), cpus: 2}, 100, true},
+		{"sin muestra previa", cgroupSample{}, cgroupSample{usage: time.Second, at: at, cpus: 2}, 0, false},
+		{"sin tiempo transcurrido", prev, cgroupSample{usage: 11 * time.Second, at: at, cpus: 2}, 0, false},
+		{"contador reiniciado", prev, cgroupSample{usage: time.Second, at: at.Add(time.Second), cpus: 2}, 0, false},
+		{"sin CPUs", prev, cgroupSample{usage: 11 * time.Second, at: at.Add(time.Second)}, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := cgroupPercent(tt.prev, tt.cur)
+		if ok != tt.wantOK || !almostEqual(got, tt.want) {
+			t.Errorf("%s: cgroupPercent = %v, %v; se esperaba %v, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestCPUSampler_Cgroup(t *testing.T) {
+	procRoot := withProcRoot(t)
+	cgRoot := withCgroupRoot(t)
+	writeProcStat(t, procRoot, "cpu  100 0 100 800 0 0 0 0 0 0")
+	writeCgroupFile(t, cgRoot, "cpu.stat", "usage_usec 1000000")
+	writeCgroupFile(t, cgRoot, "cpu.max", "100000 100000")
+
+	sampler := &CPUSampler{Cgroup: true}
+	if err := sampler.Prime(); err != nil {
+		t.Fatal(err)
+	}
+	// Una hora de CPU en un cgroup limitado a una CPU satura la cuota.
+	writeCgroupFile(t, cgRoot, "cpu.stat", "usage_usec 3601000000")
+	usage, err := sampler.Sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Cgroup == nil || *usage.Cgroup != 100 {
+		t.Fatalf("se esperaba el cgroup al 100%%, got %v", usage.Cgroup)
+	}
+
+	// Un contador de cgroup que retrocede no produce medida.
+	writeCgroupFile(t, cgRoot, "cpu.stat", "usage_usec 5")
+	if usage, err = sampler.Sample(); err != nil || usage.Cgroup != nil {
+		t.Fatalf("tras reiniciar el contador del cgroup se esperaba nil; got %v, %v", usage.Cgroup, err)
+	}
+}
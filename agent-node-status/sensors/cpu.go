@@ -0,0 +1,186 @@
+package sensors
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cpuTimes son los contadores de una línea "cpu" de /proc/stat, en jiffies.
+type cpuTimes struct {
+	idle  uint64
+	total uint64
+}
+
+// readProcStat devuelve los contadores agregados ("cpu ") y por núcleo
+// ("cpuN") de /proc/stat.
+func readProcStat() (cpuTimes, []cpuTimes, error) {
+	file, err := os.Open(ProcRoot + "/stat")
+	if err != nil {
+		return cpuTimes{}, nil, fmt.Errorf("cannot open /proc/stat: %w", err)
+	}
+	defer file.Close()
+
+	var (
+		agg     cpuTimes
+		found   bool
+		perCore []cpuTimes
+	)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		t, err := parseCPULine(fields)
+		if err != nil {
+			return cpuTimes{}, nil, err
+		}
+		if fields[0] == "cpu" {
+			agg, found = t, true
+		} else {
+			perCore = append(perCore, t)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return cpuTimes{}, nil, fmt.Errorf("reading /proc/stat: %w", err)
+	}
+	if !found {
+		return cpuTimes{}, nil, fmt.Errorf("no 'cpu ' line found in /proc/stat")
+	}
+	return agg, perCore, nil
+}
+
+// parseCPULine suma todas las columnas de tiempo: user nice system idle
+// iowait irq softirq steal. guest y guest_nice ya están incluidos en user y
+// nice, así que no se suman de nuevo. iowait cuenta como tiempo ocioso.
+func parseCPULine(fields []string) (cpuTimes, error) {
+	if len(fields) < 5 {
+		return cpuTimes{}, fmt.Errorf("/proc/stat malformed: not enough fields in %q", fields[0])
+	}
+	var t cpuTimes
+	for i, f := range fields[1:] {
+		if i >= 8 {
+			break
+		}
+		v, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return cpuTimes{}, fmt.Errorf("error parsing CPU fields: %w", err)
+		}
+		t.total += v
+		if i == 3 || i == 4 { // idle, iowait
+			t.idle += v
+		}
+	}
+	return t, nil
+}
+
+// busyPercent es el porcentaje de tiempo no ocioso entre dos lecturas.
+func busyPercent(prev, cur cpuTimes) float64 {
+	if cur.total <= prev.total {
+		return 0
+	}
+	total := float64(cur.total - prev.total)
+	idle := float64(cur.idle - prev.idle)
+	if idle > total {
+		return 0
+	}
+	return (total - idle) / total * 100
+}
+
+// CPUUsage es el uso de CPU medido entre dos muestras.
+type CPUUsage struct {
+	// Percent es el uso agregado del nodo.
+	Percent float64
+	// PerCore es el uso de cada núcleo, solo si CPUSampler.PerCore.
+	PerCore []float64
+	// Cgroup es el uso del cgroup del agente respecto a su límite, solo si
+	// CPUSampler.Cgroup y el cgroup es legible.
+	Cgroup *float64
+}
+
+// CPUSampler mide el uso de CPU como diferencia entre lecturas sucesivas de
+// /proc/stat, en lugar de la media desde el arranque. Es seguro para uso
+// concurrente.
+type CPUSampler struct {
+	// PerCore activa el uso por núcleo.
+	PerCore bool
+	// Cgroup activa el uso a nivel de cgroup (consciente de contenedores).
+	Cgroup bool
+
+	mu          sync.Mutex
+	prev        cpuTimes
+	prevPerCore []cpuTimes
+	prevCgroup  cgroupSample
+	last        CPUUsage
+	primed      bool
+}
+
+// Prime toma la lectura de referencia. Si no se llama, el primer Sample la
+// toma y espera brevemente para tener un intervalo que medir.
+func (s *CPUSampler) Prime() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prime()
+}
+
+func (s *CPUSampler) prime() error {
+	agg, perCore, err := readProcStat()
+	if err != nil {
+		return err
+	}
+	s.prev, s.prevPerCore = agg, perCore
+	if s.Cgroup {
+		s.prevCgroup, _ = readCgroupSample()
+	}
+	s.primed = true
+	return nil
+}
+
+// Sample devuelve el uso de CPU desde la muestra anterior.
+func (s *CPUSampler) Sample() (CPUUsage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.primed {
+		if err := s.prime(); err != nil {
+			return CPUUsage{}, err
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+
+	agg, perCore, err := readProcStat()
+	if err != nil {
+		return CPUUsage{}, err
+	}
+	usage := CPUUsage{Percent: busyPercent(s.prev, agg)}
+	if s.PerCore && len(perCore) == len(s.prevPerCore) {
+		usage.PerCore = make([]float64, len(perCore))
+		for i := range perCore {
+			usage.PerCore[i] = busyPercent(s.prevPerCore[i], perCore[i])
+		}
+	}
+	if s.Cgroup {
+		if cur, err := readCgroupSample(); err == nil {
+			if pct, ok := cgroupPercent(s.prevCgroup, cur); ok {
+				usage.Cgroup = &pct
+			}
+			s.prevCgroup = cur
+		}
+	}
+
+	s.prev, s.prevPerCore = agg, perCore
+	s.last = usage
+	return usage, nil
+}
+
+// Last devuelve la última medida de Sample sin tomar una nueva.
+func (s *CPUSampler) Last() CPUUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
@@ -0,0 +1,166 @@
+package sensors
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func withProcRoot(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	old := ProcRoot
+	ProcRoot = root
+	t.Cleanup(func() { ProcRoot = old })
+	return root
+}
+
+// writeProcStat escribe un /proc/stat falso con las líneas dadas.
+func writeProcStat(t *testing.T, root string, lines ...string) {
+	t.Helper()
+	data := strings.Join(lines, "\n") + "\nintr 12345 0 0\nctxt 6789\n"
+	if err := os.WriteFile(filepath.Join(root, "stat"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func almostEqual(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestParseCPULine(t *testing.T) {
+	// user nice system idle iowait irq softirq steal guest guest_nice
+	got, err := parseCPULine(strings.Fields("cpu 10 20 30 400 50 6 7 8 900 1000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// guest y guest_nice ya van dentro de user y nice: no se suman.
+	if got.total != 531 {
+		t.Errorf("total = %d, se esperaba 531 sin guest ni guest_nice", got.total)
+	}
+	// iowait es tiempo ocioso; irq, softirq y steal no.
+	if got.idle != 450 {
+		t.Errorf("idle = %d, se esperaba idle+iowait = 450", got.idle)
+	}
+
+	if _, err := parseCPULine(strings.Fields("cpu 1 2 3")); err == nil {
+		t.Error("una línea con menos de cuatro columnas debería fallar")
+	}
+	if _, err := parseCPULine(strings.Fields("cpu 1 2 x 4")); err == nil {
+		t.Error("una columna no numérica debería fallar")
+	}
+}
+
+func TestBusyPercent(t *testing.T) {
+	prev := cpuTimes{idle: 8000, total: 10000}
+	tests := []struct {
+		name string
+		cur  cpuTimes
+		want float64
+	}{
+		{"delta", cpuTimes{idle: 8200, total: 10500}, 60},
+		{"todo ocioso", cpuTimes{idle: 8500, total: 10500}, 0},
+		{"sin cambios", prev, 0},
+		{"contador reiniciado", cpuTimes{idle: 100, total: 200}, 0},
+		{"idle mayor que total", cpuTimes{idle: 9000, total: 10500}, 0},
+	}
+	for _, tt := range tests {
+		if got := busyPercent(prev, tt.cur); !almostEqual(got, tt.want) {
+			t.Errorf("%s: busyPercent = %v, se esperaba %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCPUSampler_UsesDeltaBetweenSnapshots(t *testing.T) {
+	root := withProcRoot(t)
+	// Desde el arranque el nodo ha estado ocioso un 80% del tiempo.
+	writeProcStat(t, root,
+		"cpu  1000 0 1000 8000 0 0 0 0 0 0",
+		"cpu0 500 0 500 4000 0 0 0 0 0 0",
+		"cpu1 500 0 500 4000 0 0 0 0 0 0",
+	)
+	sampler := &CPUSampler{PerCore: true}
+	if err := sampler.Prime(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Entre muestras: user 100, system 100, idle 100, iowait 100, irq 50,
+	// softirq 25, steal 25 y 1000 de guest, que no debe contar.
+	writeProcStat(t, root,
+		"cpu  1100 0 1100 8100 100 50 25 25 1000 0",
+		"cpu0 600 0 600 4000 0 50 25 25 1000 0",
+		"cpu1 500 0 500 4100 100 0 0 0 0 0",
+	)
+	usage, err := sampler.Sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !almostEqual(usage.Percent, 60) {
+		t.Errorf("Percent = %v, se esperaba 60 (delta), no la media desde el arranque", usage.Percent)
+	}
+	if len(usage.PerCore) != 2 || !almostEqual(usage.PerCore[0], 100) || !almostEqual(usage.PerCore[1], 0) {
+		t.Errorf("PerCore = %v, se esperaba [100 0]", usage.PerCore)
+	}
+	if got := sampler.Last(); !almostEqual(got.Percent, usage.Percent) {
+		t.Errorf("Last = %+v, se esperaba la última muestra %+v", got, usage)
+	}
+}
+
+func TestCPUSampler_ZeroDeltaAndCounterReset(t *testing.T) {
+	root := withProcRoot(t)
+	writeProcStat(t, root, "cpu  1000 0 1000 8000 0 0 0 0 0 0")
+	sampler := &CPUSampler{}
+	if err := sampler.Prime(); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := sampler.Sample()
+	if err != nil || usage.Percent != 0 {
+		t.Fatalf("sin delta se esperaba 0%%; got %v, %v", usage.Percent, err)
+	}
+
+	writeProcStat(t, root, "cpu  10 0 10 80 0 0 0 0 0 0")
+	usage, err = sampler.Sample()
+	if err != nil || usage.Percent != 0 {
+		t.Fatalf("tras reiniciar los contadores se esperaba 0%%; got %v, %v", usage.Percent, err)
+	}
+
+	// La muestra reiniciada es la nueva referencia.
+	writeProcStat(t, root, "cpu  60 0 60 80 0 0 0 0 0 0")
+	usage, err = sampler.Sample()
+	if err != nil || !almostEqual(usage.Percent, 100) {
+		t.Fatalf("se esperaba 100%% respecto a la muestra reiniciada; got %v, %v", usage.Percent, err)
+	}
+}
+
+func TestCPUSampler_PerCoreSkippedWhenCoresChange(t *testing.T) {
+	root := withProcRoot(t)
+	writeProcStat(t, root, "cpu  100 0 100 800 0 0 0 0 0 0", "cpu0 100 0 100 800 0 0 0 0 0 0")
+	sampler := &CPUSampler{PerCore: true}
+	if err := sampler.Prime(); err != nil {
+		t.Fatal(err)
+	}
+	writeProcStat(t, root,
+		"cpu  200 0 200 800 0 0 0 0 0 0",
+		"cpu0 150 0 150 800 0 0 0 0 0 0",
+		"cpu1 50 0 50 0 0 0 0 0 0 0",
+	)
+	usage, err := sampler.Sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.PerCore != nil {
+		t.Errorf("con otro número de núcleos no se puede calcular PerCore: %v", usage.PerCore)
+	}
+}
+
+func TestReadProcStat_Errors(t *testing.T) {
+	root := withProcRoot(t)
+	if _, _, err := readProcStat(); err == nil {
+		t.Error("sin /proc/stat se esperaba un error")
+	}
+	writeProcStat(t, root, "cpu0 1 2 3 4")
+	if _, _, err := readProcStat(); err == nil {
+		t.Error("sin la línea agregada 'cpu ' se esperaba un error")
+	}
+}
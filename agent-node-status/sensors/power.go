@@ -0,0 +1,77 @@
+package sensors
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+)
+
+// ReadPower lee las fuentes de alimentación de SysRoot/class/power_supply.
+// Devuelve nil, sin error, si el nodo no tiene batería.
+func ReadPower() (*heartbeat.Power, error) {
+	supplies, err := filepath.Glob(SysRoot + "/class/power_supply/*")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		capacity    float64
+		batteries   int
+		status      string
+		hasAC       bool
+		acOnline    bool
+		discharging bool
+	)
+	for _, dir := range supplies {
+		switch readSysfs(dir, "type") {
+		case "Battery":
+			// Algunos drivers exponen baterías de periféricos (ratón, teclado)
+			if readSysfs(dir, "scope") == "Device" {
+				continue
+			}
+			c, err := strconv.ParseFloat(readSysfs(dir, "capacity"), 64)
+			if err != nil {
+				continue
+			}
+			capacity += c
+			batteries++
+			st := readSysfs(dir, "status")
+			if status == "" {
+				status = st
+			}
+			if st == "Discharging" {
+				discharging = true
+			}
+		case "Mains", "USB", "USB_C", "USB_PD":
+			hasAC = true
+			if readSysfs(dir, "online") == "1" {
+				acOnline = true
+			}
+		}
+	}
+
+	if batteries == 0 {
+		if len(supplies) > 0 && !hasAC {
+			return nil, fmt.Errorf("no readable battery in %s/class/power_supply", SysRoot)
+		}
+		return nil, nil
+	}
+	return &heartbeat.Power{
+		BatteryPercent: capacity / float64(batteries),
+		BatteryStatus:  status,
+		// Si el kernel no expone la fuente de red, el estado de la batería decide
+		OnBattery: (hasAC && !acOnline) || (!hasAC && discharging),
+	}, nil
+}
+
+func readSysfs(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
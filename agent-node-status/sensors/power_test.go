@@ -0,0 +1,83 @@
+package sensors
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSupply crea una fuente de alimentación falsa bajo root.
+func writeSupply(t *testing.T, root, name string, attrs map[string]string) {
+	t.Helper()
+	dir := filepath.Join(root, "class", "power_supply", name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range attrs {
+		if err := os.WriteFile(filepath.Join(dir, k), []byte(v+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func withSysRoot(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	old := SysRoot
+	SysRoot = root
+	t.Cleanup(func() { SysRoot = old })
+	return root
+}
+
+func TestReadPower_NoBattery(t *testing.T) {
+	root := withSysRoot(t)
+	writeSupply(t, root, "AC", map[string]string{"type": "Mains", "online": "1"})
+
+	power, err := ReadPower()
+	if err != nil || power != nil {
+		t.Fatalf("sin batería se esperaba nil, nil; got %+v, %v", power, err)
+	}
+}
+
+func TestReadPower_OnBattery(t *testing.T) {
+	root := withSysRoot(t)
+	writeSupply(t, root, "AC", map[string]string{"type": "Mains", "online": "0"})
+	writeSupply(t, root, "BAT0", map[string]string{"type": "Battery", "capacity": "40", "status": "Discharging"})
+	writeSupply(t, root, "BAT1", map[string]string{"type": "Battery", "capacity": "60", "status": "Discharging"})
+	writeSupply(t, root, "hid-mouse", map[string]string{"type": "Battery", "scope": "Device", "capacity": "5"})
+
+	power, err := ReadPower()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if power == nil || power.BatteryPercent != 50 || !power.OnBattery || power.BatteryStatus != "Discharging" {
+		t.Errorf("estado inesperado: %+v", power)
+	}
+}
+
+func TestReadPower_ACOnline(t *testing.T) {
+	root := withSysRoot(t)
+	writeSupply(t, root, "ACAD", map[string]string{"type": "Mains", "online": "1"})
+	writeSupply(t, root, "BAT0", map[string]string{"type": "Battery", "capacity": "97", "status": "Charging"})
+
+	power, err := ReadPower()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if power == nil || power.OnBattery || power.BatteryPercent != 97 {
+		t.Errorf("estado inesperado: %+v", power)
+	}
+}
+
+func TestReadPower_WithoutMainsUsesBatteryStatus(t *testing.T) {
+	root := withSysRoot(t)
+	writeSupply(t, root, "battery", map[string]string{"type": "Battery", "capacity": "12", "status": "Discharging"})
+
+	power, err := ReadPower()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if power == nil || !power.OnBattery {
+		t.Errorf("sin fuente de red, una batería descargando implica OnBattery: %+v", power)
+	}
+}
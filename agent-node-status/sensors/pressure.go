@@ -0,0 +1,85 @@
+package sensors
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+)
+
+// ReadPressure lee la Pressure Stall Information de /proc/pressure/{cpu,memory,io}.
+// Requiere un kernel >= 4.20 con CONFIG_PSI.
+func ReadPressure() (*heartbeat.Pressure, error) {
+	var p heartbeat.Pressure
+	for name, dst := range map[string]*heartbeat.PressureStat{
+		"cpu": &p.CPU, "memory": &p.Memory, "io": &p.IO,
+	} {
+		stat, err := readPressureFile(ProcRoot + "/pressure/" + name)
+		if err != nil {
+			return nil, err
+		}
+		*dst = stat
+	}
+	return &p, nil
+}
+
+// readPressureFile interpreta líneas como
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func readPressureFile(path string) (heartbeat.PressureStat, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return heartbeat.PressureStat{}, fmt.Errorf("cannot open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var stat heartbeat.PressureStat
+	foundSome := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		var avg10, avg60, avg300 *float64
+		switch fields[0] {
+		case "some":
+			avg10, avg60, avg300 = &stat.SomeAvg10, &stat.SomeAvg60, &stat.SomeAvg300
+			foundSome = true
+		case "full":
+			avg10, avg60, avg300 = &stat.FullAvg10, &stat.FullAvg60, &stat.FullAvg300
+		default:
+			continue
+		}
+		for _, kv := range fields[1:] {
+			key, value, ok := strings.Cut(kv, "=")
+			if !ok {
+				continue
+			}
+			var dst *float64
+			switch key {
+			case "avg10":
+				dst = avg10
+			case "avg60":
+				dst = avg60
+			case "avg300":
+				dst = avg300
+			default:
+				continue
+			}
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return heartbeat.PressureStat{}, fmt.Errorf("parsing %s: %w", path, err)
+			}
+			*dst = v
+		}
+	}
+	if !foundSome {
+		return heartbeat.PressureStat{}, fmt.Errorf("%s malformed: no 'some' line", path)
+	}
+	return stat, nil
+}
@@ -0,0 +1,85 @@
+package sensors
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writePressure crea un fichero PSI falso en root/pressure.
+func writePressure(t *testing.T, root, name, content string) {
+	t.Helper()
+	dir := filepath.Join(root, "pressure")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadPressure(t *testing.T) {
+	root := withProcRoot(t)
+	writePressure(t, root, "cpu",
+		"some avg10=1.50 avg60=2.25 avg300=3.00 total=123456\n"+
+			"full avg10=0.00 avg60=0.00 avg300=0.00 total=0\n")
+	writePressure(t, root, "memory",
+		"some avg10=10.00 avg60=5.00 avg300=1.00 total=99\n"+
+			"full avg10=4.00 avg60=2.00 avg300=0.50 total=42\n")
+	writePressure(t, root, "io",
+		"some avg10=0.10 avg60=0.20 avg300=0.30 total=7\n"+
+			"full avg10=0.01 avg60=0.02 avg300=0.03 total=3\n")
+
+	p, err := ReadPressure()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.CPU.SomeAvg10 != 1.5 || p.CPU.SomeAvg60 != 2.25 || p.CPU.SomeAvg300 != 3 {
+		t.Errorf("CPU some inesperado: %+v", p.CPU)
+	}
+	if p.Memory.SomeAvg10 != 10 || p.Memory.FullAvg10 != 4 || p.Memory.FullAvg60 != 2 || p.Memory.FullAvg300 != 0.5 {
+		t.Errorf("memoria inesperada: %+v", p.Memory)
+	}
+	if p.IO.SomeAvg300 != 0.3 || p.IO.FullAvg300 != 0.03 {
+		t.Errorf("IO inesperado: %+v", p.IO)
+	}
+}
+
+func TestReadPressureFile_WithoutFullLine(t *testing.T) {
+	root := withProcRoot(t)
+	// Los kernels anteriores a 5.13 no tienen línea "full" para la CPU.
+	writePressure(t, root, "cpu", "some avg10=7.00 avg60=6.00 avg300=5.00 total=1\n")
+
+	stat, err := readPressureFile(filepath.Join(root, "pressure", "cpu"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.SomeAvg10 != 7 || stat.SomeAvg300 != 5 {
+		t.Errorf("some inesperado: %+v", stat)
+	}
+	if stat.FullAvg10 != 0 || stat.FullAvg60 != 0 || stat.FullAvg300 != 0 {
+		t.Errorf("sin línea full los valores full deberían ser 0: %+v", stat)
+	}
+}
+
+func TestReadPressureFile_Malformed(t *testing.T) {
+	root := withProcRoot(t)
+	path := filepath.Join(root, "pressure", "io")
+
+	writePressure(t, root, "io", "full avg10=1.00 avg60=1.00 avg300=1.00 total=1\n")
+	if _, err := readPressureFile(path); err == nil {
+		t.Error("sin línea some se esperaba un error")
+	}
+
+	writePressure(t, root, "io", "some avg10=abc avg60=1.00 avg300=1.00 total=1\n")
+	if _, err := readPressureFile(path); err == nil {
+		t.Error("con un valor no numérico se esperaba un error")
+	}
+}
+
+func TestReadPressure_KernelWithoutPSI(t *testing.T) {
+	withProcRoot(t)
+	if p, err := ReadPressure(); err == nil || p != nil {
+		t.Errorf("sin /proc/pressure se esperaba un error; got %+v, %v", p, err)
+	}
+}
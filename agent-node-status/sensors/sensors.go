@@ -0,0 +1,168 @@
+// Package sensors lee la telemetría del nodo desde /proc y los sistemas de
+// ficheros montados.
+package sensors
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+)
+
+// ProcRoot es la raíz de procfs. Se puede cambiar para leer el /proc del
+// host montado en otra ruta.
+var ProcRoot = "/proc"
+
+// Options selecciona la telemetría opcional de Collect.
+type Options struct {
+	// DiskMounts son los puntos de montaje cuyo uso se reporta.
+	DiskMounts []string
+	// Pressure, Temperatures y Power activan la PSI, las zonas térmicas y
+	// la batería.
+	Pressure     bool
+	Temperatures bool
+	Power        bool
+}
+
+// Collect reúne la telemetría tipada del nodo, midiendo la CPU con cpu
+// desde la muestra anterior. Un sensor que falla se omite y se devuelve su
+// error, salvo CPU y memoria, que son obligatorios.
+func Collect(cpu *CPUSampler, opts Options) (*heartbeat.Resources, []error) {
+	var errs []error
+	res := &heartbeat.Resources{}
+
+	usage, err := cpu.Sample()
+	if err != nil {
+		return nil, []error{err}
+	}
+	res.CPUPercent = usage.Percent
+	res.PerCPUPercent = usage.PerCore
+	res.CgroupCPUPercent = usage.Cgroup
+
+	used, total, err := ReadMemory()
+	if err != nil {
+		return nil, []error{err}
+	}
+	res.MemoryUsedBytes, res.MemoryTotalBytes = used, total
+
+	if res.Load1, res.Load5, res.Load15, err = ReadLoadAvg(); err != nil {
+		errs = append(errs, err)
+	}
+	if res.UptimeSeconds, err = ReadUptime(); err != nil {
+		errs = append(errs, err)
+	}
+	// PSI, zonas térmicas y batería no existen en todos los kernels y placas
+	if opts.Pressure {
+		if res.Pressure, err = ReadPressure(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if opts.Temperatures {
+		if res.Temperatures, err = ReadTemperatures(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if opts.Power {
+		if res.Power, err = ReadPower(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, mount := range opts.DiskMounts {
+		disk, err := ReadDisk(mount)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		res.Disks = append(res.Disks, disk)
+	}
+	return res, errs
+}
+
+// ReadMemory devuelve la memoria usada y total en bytes a partir de
+// MemTotal y MemAvailable de /proc/meminfo.
+func ReadMemory() (used, total uint64, err error) {
+	file, err := os.Open(ProcRoot + "/meminfo")
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot open /proc/meminfo: %w", err)
+	}
+	defer file.Close()
+
+	var memTotal, memAvailable uint64
+	foundTotal, foundAvail := false, false
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			memTotal, foundTotal = v*1024, true
+		case "MemAvailable:":
+			memAvailable, foundAvail = v*1024, true
+		}
+	}
+
+	if !foundTotal || !foundAvail || memTotal == 0 || memAvailable > memTotal {
+		return 0, 0, fmt.Errorf("missing or invalid MemTotal/MemAvailable")
+	}
+	return memTotal - memAvailable, memTotal, nil
+}
+
+// ReadLoadAvg devuelve las cargas medias de 1, 5 y 15 minutos.
+func ReadLoadAvg() (load1, load5, load15 float64, err error) {
+	data, err := os.ReadFile(ProcRoot + "/loadavg")
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("cannot read /proc/loadavg: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return 0, 0, 0, fmt.Errorf("/proc/loadavg malformed")
+	}
+	var loads [3]float64
+	for i := range loads {
+		if loads[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return 0, 0, 0, fmt.Errorf("error parsing /proc/loadavg: %w", err)
+		}
+	}
+	return loads[0], loads[1], loads[2], nil
+}
+
+// ReadUptime devuelve los segundos desde el arranque del nodo.
+func ReadUptime() (float64, error) {
+	data, err := os.ReadFile(ProcRoot + "/uptime")
+	if err != nil {
+		return 0, fmt.Errorf("cannot read /proc/uptime: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 1 {
+		return 0, fmt.Errorf("/proc/uptime malformed")
+	}
+	uptime, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing /proc/uptime: %w", err)
+	}
+	return uptime, nil
+}
+
+// ReadDisk devuelve el uso del sistema de ficheros montado en mount.
+func ReadDisk(mount string) (heartbeat.DiskUsage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(mount, &st); err != nil {
+		return heartbeat.DiskUsage{}, fmt.Errorf("statfs %s: %w", mount, err)
+	}
+	bsize := uint64(st.Bsize)
+	total := st.Blocks * bsize
+	// Igual que df: el espacio usado es el total menos los bloques libres
+	used := total - st.Bfree*bsize
+	return heartbeat.DiskUsage{Mount: mount, UsedBytes: used, TotalBytes: total}, nil
+}
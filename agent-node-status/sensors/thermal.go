@@ -0,0 +1,48 @@
+package sensors
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+)
+
+// SysRoot es la raíz de sysfs. Se puede cambiar para leer el /sys del host
+// montado en otra ruta.
+var SysRoot = "/sys"
+
+// ReadTemperatures lee todas las zonas de /sys/class/thermal. Las zonas que
+// no se pueden leer (p. ej. sensores deshabilitados) se omiten.
+func ReadTemperatures() ([]heartbeat.Temperature, error) {
+	zones, err := filepath.Glob(SysRoot + "/class/thermal/thermal_zone*")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(zones)
+
+	var temps []heartbeat.Temperature
+	for _, dir := range zones {
+		data, err := os.ReadFile(dir + "/temp")
+		if err != nil {
+			continue
+		}
+		// El kernel reporta miligrados Celsius
+		milli, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			continue
+		}
+		t := heartbeat.Temperature{Zone: filepath.Base(dir), Celsius: float64(milli) / 1000}
+		if typ, err := os.ReadFile(dir + "/type"); err == nil {
+			t.Type = strings.TrimSpace(string(typ))
+		}
+		temps = append(temps, t)
+	}
+	if len(zones) > 0 && len(temps) == 0 {
+		return nil, fmt.Errorf("no readable thermal zone in %s/class/thermal", SysRoot)
+	}
+	return temps, nil
+}
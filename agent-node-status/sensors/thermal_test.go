@@ -0,0 +1,72 @@
+package sensors
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeZone crea una zona térmica falsa bajo root. Un atributo vacío no se
+// escribe.
+func writeZone(t *testing.T, root, name, typ, temp string) {
+	t.Helper()
+	dir := filepath.Join(root, "class", "thermal", name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for file, v := range map[string]string{"type": typ, "temp": temp} {
+		if v == "" {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(v+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadTemperatures(t *testing.T) {
+	root := withSysRoot(t)
+	writeZone(t, root, "thermal_zone1", "x86_pkg_temp", "61500")
+	writeZone(t, root, "thermal_zone0", "acpitz", "27800")
+	writeZone(t, root, "thermal_zone2", "", "-5000")
+	// Un sensor deshabilitado no tiene temp legible y se omite.
+	writeZone(t, root, "thermal_zone3", "iwlwifi_1", "")
+	writeZone(t, root, "thermal_zone4", "broken", "N/A")
+
+	temps, err := ReadTemperatures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(temps) != 3 {
+		t.Fatalf("se esperaban 3 zonas legibles, got %+v", temps)
+	}
+	want := []struct {
+		zone, typ string
+		celsius   float64
+	}{
+		{"thermal_zone0", "acpitz", 27.8},
+		{"thermal_zone1", "x86_pkg_temp", 61.5},
+		{"thermal_zone2", "", -5},
+	}
+	for i, w := range want {
+		if temps[i].Zone != w.zone || temps[i].Type != w.typ || temps[i].Celsius != w.celsius {
+			t.Errorf("zona %d = %+v, se esperaba %+v", i, temps[i], w)
+		}
+	}
+}
+
+func TestReadTemperatures_NoZones(t *testing.T) {
+	withSysRoot(t)
+	temps, err := ReadTemperatures()
+	if err != nil || len(temps) != 0 {
+		t.Errorf("sin zonas térmicas se esperaba una lista vacía sin error; got %+v, %v", temps, err)
+	}
+}
+
+func TestReadTemperatures_NoReadableZone(t *testing.T) {
+	root := withSysRoot(t)
+	writeZone(t, root, "thermal_zone0", "iwlwifi_1", "")
+	if _, err := ReadTemperatures(); err == nil {
+		t.Error("si ninguna zona es legible se esperaba un error")
+	}
+}
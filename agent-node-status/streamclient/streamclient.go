@@ -0,0 +1,191 @@
+// Package streamclient envía los heartbeats del agente por el stream gRPC
+// del operador (paquete heartbeat/stream) en lugar de con un POST por
+// heartbeat. Mientras el stream vive el operador sabe que el agente está
+// vivo y puede enviarle comandos; si se corta sin Close, el operador marca
+// el nodo offline en el acto.
+package streamclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+	"github.com/jaiderssjgod/agent-node-status/heartbeat/stream"
+	"github.com/jaiderssjgod/agent-node-status/sender"
+)
+
+// keepaliveTime y keepaliveTimeout coinciden con los del operador.
+const (
+	keepaliveTime    = 10 * time.Second
+	keepaliveTimeout = 5 * time.Second
+)
+
+// TokenSource devuelve el token que se presenta al abrir cada stream.
+type TokenSource func() (string, error)
+
+// Client mantiene un stream con el operador y lo reabre en el siguiente
+// Send si se rompe. Send no es seguro para uso concurrente: lo llama la
+// goroutine de heartbeat.
+type Client struct {
+	conn    *grpc.ClientConn
+	timeout time.Duration
+	token   TokenSource
+
+	commands chan stream.Command
+
+	mu        sync.Mutex
+	st        stream.ClientStream
+	cancel    context.CancelFunc
+	responses chan *stream.OperatorMessage
+	// broken recibe el error con el que terminó el stream actual.
+	broken chan error
+}
+
+// New crea un Client para el operador en addr (host:puerto). tlsConfig nil
+// conecta sin TLS. token, si no es nil, autentica cada stream. timeout
+// acota la espera de la respuesta a cada heartbeat.
+func New(addr string, tlsConfig *tls.Config, token TokenSource, timeout time.Duration) (*Client, error) {
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.Dial(addr,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time: keepaliveTime, Timeout: keepaliveTimeout, PermitWithoutStream: true,
+		}))
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		conn:     conn,
+		timeout:  timeout,
+		token:    token,
+		commands: make(chan stream.Command, 8),
+	}, nil
+}
+
+// Commands entrega los comandos que el operador envía por el stream.
+func (c *Client) Commands() <-chan stream.Command {
+	return c.commands
+}
+
+// Send envía payload por el stream, abriéndolo si hace falta, y espera la
+// respuesta del operador. Un heartbeat rechazado devuelve
+// sender.ErrRejected; cualquier otro error cierra el stream.
+func (c *Client) Send(payload heartbeat.Payload) (*heartbeat.Response, error) {
+	st, responses, broken, err := c.open()
+	if err != nil {
+		return nil, err
+	}
+	if err := st.Send(&stream.AgentMessage{Heartbeat: &payload}); err != nil {
+		c.reset()
+		return nil, fmt.Errorf("failed to send heartbeat: %w", err)
+	}
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case msg := <-responses:
+		if msg.Response.Status != heartbeat.StatusOK {
+			return nil, fmt.Errorf("%w: %s", sender.ErrRejected, msg.Error)
+		}
+		return msg.Response, nil
+	case err := <-broken:
+		c.reset()
+		return nil, fmt.Errorf("heartbeat stream broken: %w", err)
+	case <-timer.C:
+		c.reset()
+		return nil, errors.New("timed out waiting for the operator response")
+	}
+}
+
+// open devuelve el stream vigente o abre uno nuevo.
+func (c *Client) open() (stream.ClientStream, <-chan *stream.OperatorMessage, <-chan error, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.st != nil {
+		return c.st, c.responses, c.broken, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if c.token != nil {
+		token, err := c.token()
+		if err != nil {
+			cancel()
+			return nil, nil, nil, err
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+	st, err := stream.Open(ctx, c.conn)
+	if err != nil {
+		cancel()
+		return nil, nil, nil, fmt.Errorf("failed to open heartbeat stream: %w", err)
+	}
+
+	responses := make(chan *stream.OperatorMessage, 1)
+	broken := make(chan error, 1)
+	go c.receive(st, responses, broken)
+	c.st, c.cancel, c.responses, c.broken = st, cancel, responses, broken
+	fmt.Println("[AGENT] Heartbeat stream opened")
+	return st, responses, broken, nil
+}
+
+// receive reparte los mensajes del operador hasta que el stream termina.
+func (c *Client) receive(st stream.ClientStream, responses chan<- *stream.OperatorMessage, broken chan<- error) {
+	for {
+		msg, err := st.Recv()
+		if err != nil {
+			broken <- err
+			return
+		}
+		switch {
+		case msg.Response != nil:
+			responses <- msg
+		case msg.Command != nil:
+			select {
+			case c.commands <- *msg.Command:
+			default:
+				fmt.Fprintf(os.Stderr, "[AGENT WARN] Dropped operator command %s: too many pending\n", msg.Command.Type)
+			}
+		}
+	}
+}
+
+// reset cancela el stream vigente; el siguiente Send abre otro.
+func (c *Client) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.st, c.cancel = nil, nil
+}
+
+// Close cierra el stream de forma ordenada, para que el operador no tome
+// la parada del agente por una caída del nodo, y después la conexión.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	st, broken := c.st, c.broken
+	c.mu.Unlock()
+	if st != nil && st.CloseSend() == nil {
+		// El operador termina el stream al ver el cierre
+		select {
+		case <-broken:
+		case <-time.After(c.timeout):
+		}
+	}
+	c.reset()
+	return c.conn.Close()
+}
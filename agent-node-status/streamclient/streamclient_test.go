@@ -0,0 +1,99 @@
+package streamclient
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/jaiderssjgod/agent-node-status/heartbeat"
+	"github.com/jaiderssjgod/agent-node-status/heartbeat/stream"
+	"github.com/jaiderssjgod/agent-node-status/sender"
+)
+
+// fakeOperator responde a cada heartbeat, rechaza los de "bad" y, tras el
+// primero, envía un comando.
+type fakeOperator struct {
+	tokens chan string
+	closed chan error
+}
+
+func (f *fakeOperator) Stream(st stream.ServerStream) error {
+	md, _ := metadata.FromIncomingContext(st.Context())
+	f.tokens <- md.Get("authorization")[0]
+	for first := true; ; first = false {
+		msg, err := st.Recv()
+		if err != nil {
+			f.closed <- err
+			return nil
+		}
+		resp := &stream.OperatorMessage{Response: &heartbeat.Response{Status: heartbeat.StatusOK,
+			Config: &heartbeat.AgentConfig{Policy: "p", HeartbeatIntervalSeconds: 30}}}
+		if msg.Heartbeat.NodeName == "bad" {
+			resp = &stream.OperatorMessage{Response: &heartbeat.Response{Status: heartbeat.StatusRejected}, Error: "invalid cpu"}
+		}
+		if err := st.Send(resp); err != nil {
+			return err
+		}
+		if first {
+			st.Send(&stream.OperatorMessage{Command: &stream.Command{Type: stream.CommandApplyConfig}})
+		}
+	}
+}
+
+func TestClient_SendsOverOneStreamAndClosesOrderly(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := &fakeOperator{tokens: make(chan string, 4), closed: make(chan error, 4)}
+	srv := grpc.NewServer()
+	stream.RegisterServer(srv, op)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	c, err := New(lis.Addr().String(), nil, func() (string, error) { return "t0k3n", nil }, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := c.Send(heartbeat.Payload{NodeName: "n1", Timestamp: time.Now()})
+	if err != nil || resp.Config == nil || resp.Config.HeartbeatIntervalSeconds != 30 {
+		t.Fatalf("Send = %+v, %v; se esperaba la configuración de la policy", resp, err)
+	}
+	if tok := <-op.tokens; tok != "Bearer t0k3n" {
+		t.Errorf("authorization = %q, se esperaba el token", tok)
+	}
+	select {
+	case cmd := <-c.Commands():
+		if cmd.Type != stream.CommandApplyConfig {
+			t.Errorf("comando = %+v", cmd)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no llegó el comando del operador")
+	}
+
+	if _, err := c.Send(heartbeat.Payload{NodeName: "bad", Timestamp: time.Now()}); !errors.Is(err, sender.ErrRejected) {
+		t.Errorf("heartbeat rechazado: err = %v, se esperaba ErrRejected", err)
+	}
+	if _, err := c.Send(heartbeat.Payload{NodeName: "n1", Timestamp: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if len(op.tokens) != 0 {
+		t.Error("un rechazo no debe reabrir el stream")
+	}
+
+	c.Close()
+	select {
+	case err := <-op.closed:
+		if !errors.Is(err, io.EOF) {
+			t.Errorf("el operador vio %v, se esperaba un cierre ordenado (EOF)", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("el operador no vio el cierre del stream")
+	}
+}
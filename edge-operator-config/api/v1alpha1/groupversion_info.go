@@ -16,7 +16,7 @@ limitations under the License.
 
 // Package v1alpha1 contains API Schema definitions for the iot v1alpha1 API group
 // +kubebuilder:object:generate=true
-// +groupName=iot.iot.local
+// +groupName=iot.mydomain.com
 package v1alpha1
 
 import (
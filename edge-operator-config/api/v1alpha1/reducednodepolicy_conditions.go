@@ -0,0 +1,33 @@
+package v1alpha1
+
+// Tipos de condición publicados en ReducedNodePolicyStatus.Conditions.
+const (
+	// ConditionReady es True cuando el último reconcile aplicó la policy sin errores.
+	ConditionReady = "Ready"
+	// ConditionDegrading es True mientras haya cargas desalojadas o escaladas
+	// por la policy en algún nodo.
+	ConditionDegrading = "Degrading"
+	// ConditionNodesOffline es True si algún nodo observado está offline.
+	ConditionNodesOffline = "NodesOffline"
+	// ConditionThresholdExceeded es True si algún nodo online supera los
+	// umbrales de recursos de la policy.
+	ConditionThresholdExceeded = "ThresholdExceeded"
+	// ConditionReconcileError es True si el último reconcile falló.
+	ConditionReconcileError = "ReconcileError"
+)
+
+// Razones usadas en las condiciones de ReducedNodePolicy.
+const (
+	ReasonReconciled        = "Reconciled"
+	ReasonReconcileFailed   = "ReconcileFailed"
+	ReasonInvalidSpec       = "InvalidSpec"
+	ReasonNodeListFailed    = "NodeListFailed"
+	ReasonNodeLabelFailed   = "NodeLabelFailed"
+	ReasonNoError           = "NoError"
+	ReasonWorkloadsDegraded = "WorkloadsDegraded"
+	ReasonNoDegradation     = "NoDegradation"
+	ReasonNodesOffline      = "NodesOffline"
+	ReasonAllNodesOnline    = "AllNodesOnline"
+	ReasonThresholdExceeded = "ResourceThresholdExceeded"
+	ReasonWithinThresholds  = "WithinThresholds"
+)
@@ -0,0 +1,133 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// DefaultPriorityLabelKey es la etiqueta de prioridad usada si la policy no define otra.
+	DefaultPriorityLabelKey = "edge.priority"
+	// DefaultPriorityTier es el único nivel degradable cuando la policy no declara PriorityTiers.
+	DefaultPriorityTier = "non-critical"
+	// DefaultWorkloadLabelKey es la etiqueta de pod que nombra a su Deployment por defecto.
+	DefaultWorkloadLabelKey = "app"
+	// DefaultNodeLabelKey y DefaultNodeLabelValue forman la etiqueta aplicada a los nodos por defecto.
+	DefaultNodeLabelKey   = "node-type"
+	DefaultNodeLabelValue = "reducido"
+	// DefaultHeartbeatIntervalSeconds coincide con el intervalo por defecto del agente.
+	DefaultHeartbeatIntervalSeconds = 10
+)
+
+// Default rellena los campos opcionales vacíos con sus valores por defecto.
+// Replica los +kubebuilder:default del CRD para objetos creados sin pasar
+// por el API server (tests, versiones antiguas del CRD).
+func (s *ReducedNodePolicySpec) Default() {
+	if s.PriorityLabelKey == "" {
+		s.PriorityLabelKey = DefaultPriorityLabelKey
+	}
+	if s.WorkloadLabelKey == "" {
+		s.WorkloadLabelKey = DefaultWorkloadLabelKey
+	}
+	if s.HeartbeatIntervalSeconds == 0 {
+		s.HeartbeatIntervalSeconds = DefaultHeartbeatIntervalSeconds
+	}
+	if s.NodeLabelKey == "" {
+		s.NodeLabelKey = DefaultNodeLabelKey
+		if s.NodeLabelValue == "" {
+			s.NodeLabelValue = DefaultNodeLabelValue
+		}
+	}
+}
+
+// Validate comprueba que las claves y valores de etiqueta de la policy sean
+// válidos para Kubernetes, que los umbrales de CPU y memoria sean porcentajes
+// y que los niveles de prioridad no se repitan ni tengan umbrales menores que
+// los de un nivel anterior, que se degrada antes.
+func (s *ReducedNodePolicySpec) Validate() error {
+	for _, key := range []struct{ field, value string }{
+		{"priorityLabelKey", s.PriorityLabelKey},
+		{"workloadLabelKey", s.WorkloadLabelKey},
+		{"nodeLabelKey", s.NodeLabelKey},
+	} {
+		if errs := validation.IsQualifiedName(key.value); len(errs) > 0 {
+			return fmt.Errorf("spec.%s %q inválido: %v", key.field, key.value, errs)
+		}
+	}
+	if errs := validation.IsValidLabelValue(s.NodeLabelValue); len(errs) > 0 {
+		return fmt.Errorf("spec.nodeLabelValue %q inválido: %v", s.NodeLabelValue, errs)
+	}
+
+	if s.HeartbeatTimeoutSeconds < 0 {
+		return fmt.Errorf("spec.heartbeatTimeoutSeconds no puede ser negativo")
+	}
+	if s.HeartbeatIntervalSeconds < 1 {
+		return fmt.Errorf("spec.heartbeatIntervalSeconds debe ser al menos 1")
+	}
+	if s.MissedHeartbeatsThreshold < 0 {
+		return fmt.Errorf("spec.missedHeartbeatsThreshold no puede ser negativo")
+	}
+	for _, t := range percentThresholds("spec", s.MaxCPUThreshold, s.MaxMemoryThreshold) {
+		if t.value < 0 || t.value > 100 {
+			return fmt.Errorf("%s %d fuera de rango: debe estar entre 0 y 100", t.field, t.value)
+		}
+	}
+
+	if s.Agent != nil {
+		for i, mount := range s.Agent.DiskMounts {
+			if !strings.HasPrefix(mount, "/") {
+				return fmt.Errorf("spec.agent.diskMounts[%d] %q debe ser una ruta absoluta", i, mount)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	// previous guarda, por umbral, el mayor valor activo de los niveles ya
+	// recorridos y el índice del nivel que lo define.
+	previous := map[string]struct{ value, index int }{}
+	for i, tier := range s.PriorityTiers {
+		if tier.Name == "" {
+			return fmt.Errorf("spec.priorityTiers[%d].name es obligatorio", i)
+		}
+		if errs := validation.IsValidLabelValue(tier.Name); len(errs) > 0 {
+			return fmt.Errorf("spec.priorityTiers[%d].name %q inválido: %v", i, tier.Name, errs)
+		}
+		if seen[tier.Name] {
+			return fmt.Errorf("spec.priorityTiers[%d].name %q repetido", i, tier.Name)
+		}
+		seen[tier.Name] = true
+
+		prefix := fmt.Sprintf("spec.priorityTiers[%d]", i)
+		for _, t := range percentThresholds(prefix, tier.MaxCPUThreshold, tier.MaxMemoryThreshold) {
+			if t.value < 0 || t.value > 100 {
+				return fmt.Errorf("%s %d fuera de rango: debe estar entre 0 y 100", t.field, t.value)
+			}
+			if t.value == 0 {
+				continue
+			}
+			if prev, ok := previous[t.name]; ok && t.value < prev.value {
+				return fmt.Errorf("%s %d es menor que el de spec.priorityTiers[%d] (%d): los niveles deben ordenarse de menor a mayor umbral",
+					t.field, t.value, prev.index, prev.value)
+			}
+			previous[t.name] = struct{ value, index int }{t.value, i}
+		}
+	}
+	return nil
+}
+
+// threshold es un umbral porcentual de la spec con la ruta de su campo.
+// +kubebuilder:object:generate=false
+type threshold struct {
+	name, field string
+	value       int
+}
+
+// percentThresholds devuelve los umbrales de CPU y memoria bajo prefix.
+func percentThresholds(prefix string, cpu, memory int) []threshold {
+	return []threshold{
+		{"maxCPUThreshold", prefix + ".maxCPUThreshold", cpu},
+		{"maxMemoryThreshold", prefix + ".maxMemoryThreshold", memory},
+	}
+}
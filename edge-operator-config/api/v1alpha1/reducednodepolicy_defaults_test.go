@@ -0,0 +1,91 @@
+package v1alpha1
+
+import (
+	"strings"
+	"testing"
+)
+
+func validSpec() ReducedNodePolicySpec {
+	spec := ReducedNodePolicySpec{CriticalLabelKey: "critical"}
+	spec.Default()
+	return spec
+}
+
+func TestValidate_Thresholds(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*ReducedNodePolicySpec)
+		wantErr string
+	}{
+		{
+			name:   "umbrales globales en rango",
+			mutate: func(s *ReducedNodePolicySpec) { s.MaxCPUThreshold, s.MaxMemoryThreshold = 100, 80 },
+		},
+		{
+			name:    "CPU global mayor que 100",
+			mutate:  func(s *ReducedNodePolicySpec) { s.MaxCPUThreshold = 101 },
+			wantErr: "spec.maxCPUThreshold 101 fuera de rango",
+		},
+		{
+			name:    "memoria global negativa",
+			mutate:  func(s *ReducedNodePolicySpec) { s.MaxMemoryThreshold = -5 },
+			wantErr: "spec.maxMemoryThreshold -5 fuera de rango",
+		},
+		{
+			name: "niveles ordenados",
+			mutate: func(s *ReducedNodePolicySpec) {
+				s.PriorityTiers = []PriorityTier{
+					{Name: "best-effort", MaxCPUThreshold: 70, MaxMemoryThreshold: 75},
+					{Name: "batch", MaxCPUThreshold: 70},
+					{Name: "non-critical", MaxCPUThreshold: 90, MaxMemoryThreshold: 85},
+				}
+			},
+		},
+		{
+			name: "CPU de un nivel mayor que 100",
+			mutate: func(s *ReducedNodePolicySpec) {
+				s.PriorityTiers = []PriorityTier{{Name: "best-effort", MaxCPUThreshold: 150}}
+			},
+			wantErr: "spec.priorityTiers[0].maxCPUThreshold 150 fuera de rango",
+		},
+		{
+			name: "memoria de un nivel negativa",
+			mutate: func(s *ReducedNodePolicySpec) {
+				s.PriorityTiers = []PriorityTier{{Name: "best-effort"}, {Name: "non-critical", MaxMemoryThreshold: -1}}
+			},
+			wantErr: "spec.priorityTiers[1].maxMemoryThreshold -1 fuera de rango",
+		},
+		{
+			name: "CPU desordenada",
+			mutate: func(s *ReducedNodePolicySpec) {
+				s.PriorityTiers = []PriorityTier{
+					{Name: "best-effort", MaxCPUThreshold: 90},
+					{Name: "non-critical", MaxCPUThreshold: 70},
+				}
+			},
+			wantErr: "spec.priorityTiers[1].maxCPUThreshold 70 es menor que el de spec.priorityTiers[0] (90)",
+		},
+		{
+			name: "memoria desordenada tras un nivel sin umbral",
+			mutate: func(s *ReducedNodePolicySpec) {
+				s.PriorityTiers = []PriorityTier{
+					{Name: "best-effort", MaxMemoryThreshold: 80},
+					{Name: "batch"},
+					{Name: "non-critical", MaxMemoryThreshold: 60},
+				}
+			},
+			wantErr: "spec.priorityTiers[2].maxMemoryThreshold 60 es menor que el de spec.priorityTiers[0] (80)",
+		},
+	}
+	for _, tt := range tests {
+		spec := validSpec()
+		tt.mutate(&spec)
+		err := spec.Validate()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: error inesperado: %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: se esperaba un error con %q, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
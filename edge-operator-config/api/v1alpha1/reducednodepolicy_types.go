@@ -11,13 +11,182 @@ import (
 type ReducedNodePolicySpec struct {
 	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
 	// GracePeriodSeconds es el tiempo de espera antes de migrar cargas.
-	GracePeriodSeconds int `json:"gracePeriodSeconds"`
+	// 0 usa GRACE_PERIOD_SECONDS del operador (o 60 s si no está definido).
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	GracePeriodSeconds int `json:"gracePeriodSeconds,omitempty"`
+	// HeartbeatTimeoutSeconds es el tiempo sin heartbeats tras el cual un nodo
+	// se considera offline. 0 usa --heartbeat-timeout-seconds del operador.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	HeartbeatTimeoutSeconds int `json:"heartbeatTimeoutSeconds,omitempty"`
+	// HeartbeatIntervalSeconds es el intervalo esperado entre heartbeats del agente.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=10
+	// +optional
+	HeartbeatIntervalSeconds int `json:"heartbeatIntervalSeconds,omitempty"`
+	// MissedHeartbeatsThreshold, si es mayor que 0, marca el nodo offline tras
+	// perder ese número de heartbeats consecutivos (N × HeartbeatIntervalSeconds)
+	// en lugar de aplicar HeartbeatTimeoutSeconds.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MissedHeartbeatsThreshold int `json:"missedHeartbeatsThreshold,omitempty"`
 	// CriticalLabelKey es la clave que identifica pods críticos.
 	CriticalLabelKey string `json:"criticalLabelKey"`
 	// MaxCPUThreshold es el límite opcional de CPU para activar degradación (porcentaje).
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
 	MaxCPUThreshold int `json:"maxCPUThreshold,omitempty"`
 	// MaxMemoryThreshold es el límite opcional de memoria para activar degradación (porcentaje).
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
 	MaxMemoryThreshold int `json:"maxMemoryThreshold,omitempty"`
+	// MaxDiskThreshold es el límite opcional de uso del punto de montaje más
+	// lleno de los que reporta el agente (porcentaje).
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxDiskThreshold int `json:"maxDiskThreshold,omitempty"`
+	// MaxCPUPressure, MaxMemoryPressure y MaxIOPressure son límites opcionales
+	// sobre el "some avg10" de /proc/pressure (porcentaje de tiempo con tareas
+	// bloqueadas en los últimos 10 s).
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxCPUPressure int `json:"maxCPUPressure,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxMemoryPressure int `json:"maxMemoryPressure,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxIOPressure int `json:"maxIOPressure,omitempty"`
+	// MaxTemperatureCelsius es el límite opcional de la zona térmica más
+	// caliente del nodo.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxTemperatureCelsius int `json:"maxTemperatureCelsius,omitempty"`
+	// MinBatteryPercent activa la degradación cuando la batería del nodo
+	// baja de este porcentaje. 0 lo desactiva.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MinBatteryPercent int `json:"minBatteryPercent,omitempty"`
+	// DegradeOnBattery activa la degradación en cuanto el nodo pasa a
+	// alimentarse de la batería.
+	// +optional
+	DegradeOnBattery bool `json:"degradeOnBattery,omitempty"`
+	// PriorityLabelKey es la etiqueta de pod cuyo valor indica su nivel de prioridad.
+	// +kubebuilder:default=edge.priority
+	// +optional
+	PriorityLabelKey string `json:"priorityLabelKey,omitempty"`
+	// WorkloadLabelKey es la etiqueta de pod cuyo valor es el nombre del
+	// Deployment al que pertenece. Solo se usa para pods sin controlador en
+	// sus ownerReferences.
+	// +kubebuilder:default=app
+	// +optional
+	WorkloadLabelKey string `json:"workloadLabelKey,omitempty"`
+	// NodeLabelKey y NodeLabelValue forman la etiqueta que el operador aplica
+	// a los nodos gestionados por la policy.
+	// +kubebuilder:default=node-type
+	// +optional
+	NodeLabelKey string `json:"nodeLabelKey,omitempty"`
+	// +kubebuilder:default=reducido
+	// +optional
+	NodeLabelValue string `json:"nodeLabelValue,omitempty"`
+	// PriorityTiers define los niveles de prioridad degradables, ordenados de
+	// menor a mayor prioridad: el primero es el primero en ser desalojado.
+	// Por eso los umbrales de CPU y memoria de un nivel no pueden ser menores
+	// que los de un nivel anterior.
+	// Si está vacío se usa un único nivel "non-critical" con los umbrales
+	// globales (CPU, memoria, disco, presión, temperatura y batería) y el grace
+	// period de la policy.
+	// +optional
+	PriorityTiers []PriorityTier `json:"priorityTiers,omitempty"`
+	// Agent es la configuración que el operador envía, en la respuesta a cada
+	// heartbeat, a los agentes de los nodos seleccionados. Además de estos
+	// campos se envían HeartbeatIntervalSeconds, CriticalLabelKey,
+	// NodeLabelKey y NodeLabelValue.
+	// +optional
+	Agent *AgentSettings `json:"agent,omitempty"`
+}
+
+// AgentSettings selecciona la telemetría que reportan los agentes. Los
+// campos sin definir conservan la configuración local de cada agente.
+type AgentSettings struct {
+	// ReportPerCPU y ReportCgroupCPU activan el uso de CPU por núcleo y del
+	// cgroup del agente.
+	// +optional
+	ReportPerCPU *bool `json:"reportPerCPU,omitempty"`
+	// +optional
+	ReportCgroupCPU *bool `json:"reportCgroupCPU,omitempty"`
+	// ReportPressure, ReportTemperatures y ReportPower activan la PSI, las
+	// zonas térmicas y el estado de la batería.
+	// +optional
+	ReportPressure *bool `json:"reportPressure,omitempty"`
+	// +optional
+	ReportTemperatures *bool `json:"reportTemperatures,omitempty"`
+	// +optional
+	ReportPower *bool `json:"reportPower,omitempty"`
+	// DiskMounts son los puntos de montaje cuyo uso se reporta, vistos desde
+	// el contenedor del agente.
+	// +optional
+	DiskMounts []string `json:"diskMounts,omitempty"`
+}
+
+// PriorityTier es un nivel de prioridad con sus propios umbrales de degradación.
+type PriorityTier struct {
+	// Name es el valor de la etiqueta PriorityLabelKey que identifica los pods del nivel.
+	Name string `json:"name"`
+	// MaxCPUThreshold es el porcentaje de CPU a partir del cual se degrada el nivel.
+	// 0 desactiva la degradación por CPU para este nivel.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxCPUThreshold int `json:"maxCPUThreshold,omitempty"`
+	// MaxMemoryThreshold es el porcentaje de memoria a partir del cual se degrada el nivel.
+	// 0 desactiva la degradación por memoria para este nivel.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxMemoryThreshold int `json:"maxMemoryThreshold,omitempty"`
+	// MaxDiskThreshold, MaxCPUPressure, MaxMemoryPressure, MaxIOPressure y
+	// MaxTemperatureCelsius tienen el mismo significado que en la spec de la
+	// policy, aplicados a este nivel. 0 desactiva cada señal.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxDiskThreshold int `json:"maxDiskThreshold,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxCPUPressure int `json:"maxCPUPressure,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxMemoryPressure int `json:"maxMemoryPressure,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MaxIOPressure int `json:"maxIOPressure,omitempty"`
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxTemperatureCelsius int `json:"maxTemperatureCelsius,omitempty"`
+	// MinBatteryPercent y DegradeOnBattery tienen el mismo significado que
+	// en la spec de la policy, aplicados a este nivel.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	MinBatteryPercent int `json:"minBatteryPercent,omitempty"`
+	// +optional
+	DegradeOnBattery bool `json:"degradeOnBattery,omitempty"`
+	// OfflineDelaySeconds es el tiempo que el nodo debe llevar offline antes de
+	// desalojar este nivel. 0 usa el grace period de la policy.
+	// +optional
+	OfflineDelaySeconds int `json:"offlineDelaySeconds,omitempty"`
 }
 
 // NodeHeartbeatStatus almacena la información de heartbeat de un nodo individual.
@@ -42,6 +211,24 @@ type NodeHeartbeatStatus struct {
 
 	OfflineEvents []string `json:"offlineEvents,omitempty"`
 	    ResourceDegradationExecuted bool `json:"resourceDegradationExecuted,omitempty"`
+    // DegradedTiers lista, en orden, los niveles ya desalojados durante el
+    // evento offline actual.
+    // +optional
+    DegradedTiers []string `json:"degradedTiers,omitempty"`
+    // ResourceDegradedTiers lista, en orden, los niveles escalados a 0 por
+    // superar sus umbrales de recursos: CPU, memoria, disco, presión (PSI),
+    // temperatura o batería.
+    // +optional
+    ResourceDegradedTiers []string `json:"resourceDegradedTiers,omitempty"`
+    // BlockedEvictions lista los pods ("namespace/pod: motivo") cuya evicción
+    // fue rechazada por un PodDisruptionBudget en el último intento de degradación.
+    // +optional
+    BlockedEvictions []string `json:"blockedEvictions,omitempty"`
+    // LastReplayAt es el momento de recepción de la última ventana de
+    // heartbeats reproducida por el agente ya registrada en OfflineEvents.
+    // Usa precisión de microsegundos para no registrar dos veces la misma ventana.
+    // +optional
+    LastReplayAt metav1.MicroTime `json:"lastReplayAt,omitempty"`
 
 }
 
@@ -58,12 +245,23 @@ type ReducedNodePolicyStatus struct {
 	// La clave del mapa es el nombre del nodo.
 	// +optional
 	Nodes map[string]NodeHeartbeatStatus `json:"nodes,omitempty"`
+	// ObservedGeneration es la generación de la spec procesada en el último reconcile.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions resume el estado de la policy: Ready, Degrading, NodesOffline,
+	// ThresholdExceeded y ReconcileError.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=rnp
 // +kubebuilder:printcolumn:name="Observed",type=integer,JSONPath=".status.observedNodes"
 // +kubebuilder:printcolumn:name="Offline",type=integer,JSONPath=".status.offlineNodes"
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
 // +kubebuilder:printcolumn:name="LastSync",type=date,JSONPath=".status.lastSync"
 type ReducedNodePolicy struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -21,16 +21,115 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgentSettings) DeepCopyInto(out *AgentSettings) {
+	*out = *in
+	if in.ReportPerCPU != nil {
+		in, out := &in.ReportPerCPU, &out.ReportPerCPU
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ReportCgroupCPU != nil {
+		in, out := &in.ReportCgroupCPU, &out.ReportCgroupCPU
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ReportPressure != nil {
+		in, out := &in.ReportPressure, &out.ReportPressure
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ReportTemperatures != nil {
+		in, out := &in.ReportTemperatures, &out.ReportTemperatures
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ReportPower != nil {
+		in, out := &in.ReportPower, &out.ReportPower
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DiskMounts != nil {
+		in, out := &in.DiskMounts, &out.DiskMounts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgentSettings.
+func (in *AgentSettings) DeepCopy() *AgentSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(AgentSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeHeartbeatStatus) DeepCopyInto(out *NodeHeartbeatStatus) {
+	*out = *in
+	in.LastHeartbeat.DeepCopyInto(&out.LastHeartbeat)
+	in.OfflineSince.DeepCopyInto(&out.OfflineSince)
+	in.LastReplayAt.DeepCopyInto(&out.LastReplayAt)
+	if in.OfflineEvents != nil {
+		in, out := &in.OfflineEvents, &out.OfflineEvents
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DegradedTiers != nil {
+		in, out := &in.DegradedTiers, &out.DegradedTiers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ResourceDegradedTiers != nil {
+		in, out := &in.ResourceDegradedTiers, &out.ResourceDegradedTiers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BlockedEvictions != nil {
+		in, out := &in.BlockedEvictions, &out.BlockedEvictions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeHeartbeatStatus.
+func (in *NodeHeartbeatStatus) DeepCopy() *NodeHeartbeatStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeHeartbeatStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PriorityTier) DeepCopyInto(out *PriorityTier) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PriorityTier.
+func (in *PriorityTier) DeepCopy() *PriorityTier {
+	if in == nil {
+		return nil
+	}
+	out := new(PriorityTier)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ReducedNodePolicy) DeepCopyInto(out *ReducedNodePolicy) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReducedNodePolicy.
@@ -86,6 +185,23 @@ func (in *ReducedNodePolicyList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ReducedNodePolicySpec) DeepCopyInto(out *ReducedNodePolicySpec) {
 	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PriorityTiers != nil {
+		in, out := &in.PriorityTiers, &out.PriorityTiers
+		*out = make([]PriorityTier, len(*in))
+		copy(*out, *in)
+	}
+	if in.Agent != nil {
+		in, out := &in.Agent, &out.Agent
+		*out = new(AgentSettings)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReducedNodePolicySpec.
@@ -101,6 +217,21 @@ func (in *ReducedNodePolicySpec) DeepCopy() *ReducedNodePolicySpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ReducedNodePolicyStatus) DeepCopyInto(out *ReducedNodePolicyStatus) {
 	*out = *in
+	in.LastSync.DeepCopyInto(&out.LastSync)
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make(map[string]NodeHeartbeatStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReducedNodePolicyStatus.
@@ -3,21 +3,28 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	iotv1alpha1 "github.com/jaiderssjgod/edge-operator/api/v1alpha1"
+	"github.com/jaiderssjgod/edge-operator/heartbeat"
 	controller "github.com/jaiderssjgod/edge-operator/internal/controller"
 	"github.com/jaiderssjgod/edge-operator/internal/degradation"
+	"github.com/jaiderssjgod/edge-operator/internal/heartbeatauth"
 	"github.com/jaiderssjgod/edge-operator/internal/heartbeatstore"
 	"github.com/jaiderssjgod/edge-operator/internal/heartbeatstore/heartbeatserver"
+	"github.com/jaiderssjgod/edge-operator/internal/metrics"
 )
 
 var scheme = runtime.NewScheme()
@@ -34,13 +41,67 @@ func main() {
 		heartbeatAddr        string
 		heartbeatTimeoutSecs int
 		enableLeaderElection bool
+		evictionMode         string
+		heartbeatAuth        string
+		tokenAudience        string
+		allowedSAs           string
+		hmacKeysDir          string
+		tlsCertFile          string
+		tlsKeyFile           string
+		clientCAFile         string
+		relayNodes           string
+		grpcAddr             string
+		mqttBroker           string
+		mqttTopicPrefix      string
+		mqttClientID         string
+		mqttUsername         string
+		mqttPasswordFile     string
+		mqttCAFile           string
+		mqttTrustBrokerACLs  bool
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "")
 	flag.StringVar(&heartbeatAddr, "heartbeat-bind-address", ":9090", "")
-	flag.IntVar(&heartbeatTimeoutSecs, "heartbeat-timeout-seconds", 30, "")
+	flag.IntVar(&heartbeatTimeoutSecs, "heartbeat-timeout-seconds", 30, "Default heartbeat timeout for policies that do not set spec.heartbeatTimeoutSeconds")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false, "")
+	flag.StringVar(&evictionMode, "eviction-mode", string(degradation.EvictionModeEvict),
+		"How non-critical pods are removed from degraded nodes: evict (Eviction API, honors PodDisruptionBudgets) or delete")
+	flag.StringVar(&heartbeatAuth, "heartbeat-auth", heartbeatauth.ModeNone,
+		"How heartbeat senders are authenticated: none, tokenreview (projected ServiceAccount token), hmac (per-node keys) or clientcert (mTLS, node from the certificate subject)")
+	flag.StringVar(&tokenAudience, "heartbeat-token-audience", heartbeat.DefaultTokenAudience,
+		"Audience the agents' projected ServiceAccount tokens must carry")
+	flag.StringVar(&allowedSAs, "heartbeat-allowed-service-accounts", "",
+		"Comma-separated namespace/name ServiceAccounts allowed to send heartbeats with tokenreview; empty allows any")
+	flag.StringVar(&hmacKeysDir, "heartbeat-hmac-keys-dir", "/etc/edge-operator/heartbeat-keys",
+		"Directory with one HMAC key file per node name, used with hmac")
+	flag.StringVar(&grpcAddr, "heartbeat-grpc-bind-address", "",
+		"Address of the gRPC heartbeat stream endpoint, e.g. :9091; empty disables it. A broken stream marks its node offline at once")
+	flag.StringVar(&mqttBroker, "heartbeat-mqtt-broker", "",
+		"MQTT broker to receive heartbeats from, e.g. tcp://mosquitto:1883 or ssl://mosquitto:8883; empty disables it. "+
+			"The operator does not apply --heartbeat-auth to MQTT: the broker must authenticate each agent and its ACLs must only let it publish "+
+			"to <prefix>/<its node> and <prefix>/<its node>/replay (e.g. mosquitto: pattern write edge/heartbeat/%u/#, with the node name as username)")
+	flag.StringVar(&mqttTopicPrefix, "heartbeat-mqtt-topic-prefix", heartbeat.DefaultMQTTTopicPrefix,
+		"Root of the MQTT topic tree; agents publish to <prefix>/<node> and <prefix>/<node>/replay")
+	flag.StringVar(&mqttClientID, "heartbeat-mqtt-client-id", "",
+		"MQTT client ID, unique per replica; empty uses edge-operator-<hostname>")
+	flag.StringVar(&mqttUsername, "heartbeat-mqtt-username", "",
+		"Username presented to the MQTT broker")
+	flag.StringVar(&mqttPasswordFile, "heartbeat-mqtt-password-file", "",
+		"File with the password presented to the MQTT broker")
+	flag.StringVar(&mqttCAFile, "heartbeat-mqtt-ca-file", "",
+		"CA bundle that verifies a TLS MQTT broker; empty uses the system CAs")
+	flag.BoolVar(&mqttTrustBrokerACLs, "heartbeat-mqtt-trust-broker-acls", false,
+		"Accept MQTT heartbeats with a --heartbeat-auth other than none, relying on the broker ACLs to authenticate each agent")
+	flag.StringVar(&relayNodes, "heartbeat-relay-nodes", "",
+		"Comma-separated nodes whose agents may relay heartbeats of other nodes, e.g. remote site gateways, each with the "+
+			"neighbor nodes it may report: gateway-1=sensor-a;sensor-b,gateway-2=sensor-c")
+	flag.StringVar(&tlsCertFile, "heartbeat-tls-cert-file", "",
+		"Certificate served by the heartbeat endpoint; empty serves plain HTTP. Reloaded when it changes")
+	flag.StringVar(&tlsKeyFile, "heartbeat-tls-key-file", "",
+		"Private key of --heartbeat-tls-cert-file")
+	flag.StringVar(&clientCAFile, "heartbeat-client-ca-file", "",
+		"CA bundle that verifies agent client certificates; required with --heartbeat-auth=clientcert")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
@@ -48,8 +109,10 @@ func main() {
 
 	hbStore := heartbeatstore.New(time.Duration(heartbeatTimeoutSecs) * time.Second)
 
-	hbServer := heartbeatserver.New(heartbeatAddr, hbStore, log.WithName("heartbeat-server"))
-	go hbServer.Start()
+	if err := metrics.RegisterStore(hbStore); err != nil {
+		log.Error(err, "Unable to register heartbeat store metrics")
+		os.Exit(1)
+	}
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
@@ -63,11 +126,115 @@ func main() {
 		os.Exit(1)
 	}
 
+	hbServer := heartbeatserver.New(heartbeatAddr, hbStore, log.WithName("heartbeat-server"))
+	switch heartbeatAuth {
+	case heartbeatauth.ModeNone:
+		log.Info("Heartbeat authentication disabled: any pod can report for any node")
+	case heartbeatauth.ModeTokenReview:
+		var allowed []string
+		if allowedSAs != "" {
+			allowed = strings.Split(allowedSAs, ",")
+		}
+		// Los pods se leen sin caché: el servidor arranca antes que el manager
+		hbServer.Authenticator = heartbeatauth.NewTokenReview(mgr.GetClient(), mgr.GetAPIReader(), tokenAudience, allowed)
+	case heartbeatauth.ModeHMAC:
+		hbServer.Authenticator = heartbeatauth.NewHMAC(hmacKeysDir)
+	case heartbeatauth.ModeClientCert:
+		hbServer.Authenticator = heartbeatauth.ClientCert{}
+	default:
+		log.Error(nil, "Invalid heartbeat authentication mode", "heartbeatAuth", heartbeatAuth)
+		os.Exit(1)
+	}
+	if relayNodes != "" {
+		relays, err := heartbeatserver.ParseRelays(relayNodes)
+		if err != nil {
+			log.Error(err, "Invalid --heartbeat-relay-nodes")
+			os.Exit(1)
+		}
+		hbServer.Relays = relays
+	}
+	if tlsCertFile != "" || tlsKeyFile != "" || clientCAFile != "" {
+		if err := hbServer.EnableTLS(heartbeatserver.TLSOptions{
+			CertFile:          tlsCertFile,
+			KeyFile:           tlsKeyFile,
+			ClientCAFile:      clientCAFile,
+			RequireClientCert: heartbeatAuth == heartbeatauth.ModeClientCert,
+		}); err != nil {
+			log.Error(err, "Unable to configure heartbeat TLS")
+			os.Exit(1)
+		}
+	} else if heartbeatAuth == heartbeatauth.ModeClientCert {
+		log.Error(nil, "--heartbeat-auth=clientcert requires --heartbeat-tls-cert-file, --heartbeat-tls-key-file and --heartbeat-client-ca-file")
+		os.Exit(1)
+	}
+	go hbServer.Start()
+
+	// Los nodos con el stream roto se reconcilian sin esperar al requeue
+	nodeEvents := make(chan event.GenericEvent, 64)
+	if grpcAddr != "" {
+		if heartbeatAuth == heartbeatauth.ModeHMAC {
+			log.Error(nil, "Heartbeat streams do not support --heartbeat-auth=hmac")
+			os.Exit(1)
+		}
+		streamServer := heartbeatserver.NewStream(grpcAddr, hbStore, log.WithName("heartbeat-stream"))
+		streamServer.Authenticator = hbServer.Authenticator
+		streamServer.TLSConfig = hbServer.TLSConfig()
+		streamServer.OnDisconnect = func(node string) {
+			select {
+			case nodeEvents <- event.GenericEvent{Object: &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: node}}}:
+			default:
+				// Cola llena: el requeue periódico lo recogerá
+			}
+		}
+		go streamServer.Start()
+	}
+
+	if mqttBroker != "" {
+		if heartbeatAuth != heartbeatauth.ModeNone && !mqttTrustBrokerACLs {
+			log.Error(nil, "MQTT heartbeats bypass --heartbeat-auth; restrict each agent to its topics with the broker ACLs and set --heartbeat-mqtt-trust-broker-acls",
+				"heartbeatAuth", heartbeatAuth)
+			os.Exit(1)
+		}
+		opts := heartbeatserver.MQTTOptions{
+			Broker:      mqttBroker,
+			TopicPrefix: mqttTopicPrefix,
+			ClientID:    mqttClientID,
+			Username:    mqttUsername,
+			CAFile:      mqttCAFile,
+		}
+		if opts.ClientID == "" {
+			hostname, _ := os.Hostname()
+			opts.ClientID = "edge-operator-" + hostname
+		}
+		if mqttPasswordFile != "" {
+			password, err := os.ReadFile(mqttPasswordFile)
+			if err != nil {
+				log.Error(err, "Unable to read the MQTT password")
+				os.Exit(1)
+			}
+			opts.Password = strings.TrimSpace(string(password))
+		}
+		mqttSubscriber, err := heartbeatserver.NewMQTT(opts, hbStore, log.WithName("heartbeat-mqtt"))
+		if err != nil {
+			log.Error(err, "Unable to configure the MQTT heartbeat subscriber")
+			os.Exit(1)
+		}
+		go mqttSubscriber.Start()
+	}
+
 	// El índice spec.nodeName se registra dentro de SetupWithManager
 	degradationMgr := degradation.New(
 		mgr.GetClient(),
 		ctrl.Log.WithName("degradation"),
 	)
+	degradationMgr.Recorder = mgr.GetEventRecorderFor("edge-operator")
+	switch mode := degradation.EvictionMode(evictionMode); mode {
+	case degradation.EvictionModeEvict, degradation.EvictionModeDelete:
+		degradationMgr.EvictionMode = mode
+	default:
+		log.Error(nil, "Invalid eviction mode", "evictionMode", evictionMode)
+		os.Exit(1)
+	}
 
 	if err = (&controller.ReducedNodePolicyReconciler{
 		Client:             mgr.GetClient(),
@@ -75,6 +242,8 @@ func main() {
 		Scheme:             mgr.GetScheme(),
 		HeartbeatStore:     hbStore,
 		DegradationManager: degradationMgr,
+		Recorder:           mgr.GetEventRecorderFor("edge-operator"),
+		NodeEvents:         nodeEvents,
 	}).SetupWithManager(mgr); err != nil {
 		log.Error(err, "Unable to create controller", "controller", "ReducedNodePolicy")
 		os.Exit(1)
@@ -0,0 +1,62 @@
+package heartbeat
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTokenAudience es la audiencia del token de ServiceAccount
+// proyectado que el agente presenta al operador.
+const DefaultTokenAudience = "edge-operator"
+
+// Cabeceras de las peticiones firmadas con HMAC.
+const (
+	// HeaderNode es el nodo cuya clave firma la petición.
+	HeaderNode = "X-Edge-Node"
+	// HeaderTimestamp es el instante de la firma, en segundos Unix.
+	HeaderTimestamp = "X-Edge-Timestamp"
+	// HeaderSignature es "sha256=" seguido de la firma en hexadecimal.
+	HeaderSignature = "X-Edge-Signature"
+)
+
+// MaxSignatureAge es la antigüedad máxima de una firma HMAC. Limita cuánto
+// tiempo se puede reenviar una petición capturada.
+const MaxSignatureAge = 5 * time.Minute
+
+const signaturePrefix = "sha256="
+
+// Sign devuelve el valor de HeaderSignature de una petición: el HMAC-SHA256
+// con key del método, la ruta, el instante y el cuerpo.
+func Sign(key []byte, method, path string, ts time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%s\n%d\n", method, path, ts.Unix())
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature comprueba una firma de Sign. timestamp es el valor de
+// HeaderTimestamp; se rechaza si se aleja de now más de MaxSignatureAge.
+func VerifySignature(key []byte, method, path, timestamp, signature string, body []byte, now time.Time) error {
+	secs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", HeaderTimestamp, timestamp)
+	}
+	ts := time.Unix(secs, 0)
+	if age := now.Sub(ts); age > MaxSignatureAge || age < -MaxSignatureAge {
+		return fmt.Errorf("signature timestamp %s is too far from now", ts.UTC().Format(time.RFC3339))
+	}
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return errors.New("unsupported signature scheme")
+	}
+	want := Sign(key, method, path, ts, body)
+	if !hmac.Equal([]byte(want), []byte(signature)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
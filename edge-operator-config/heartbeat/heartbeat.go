@@ -2,12 +2,365 @@
 // Tipos compartidos para el protocolo de heartbeat entre agente y operador.
 package heartbeat
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Versiones del payload. Un payload sin campo version es un v1.
+const (
+	// Version1 envía CPU y memoria como strings preformateados ("85.41%").
+	Version1 = 1
+	// Version2 envía la telemetría tipada en Resources.
+	Version2 = 2
+	// CurrentVersion es la versión que emiten los agentes actuales.
+	CurrentVersion = Version2
+)
 
 // Payload es el cuerpo JSON que el agente envía al operador.
 type Payload struct {
+	// Version del formato. 0 u omitido equivale a Version1.
+	Version   int       `json:"version,omitempty"`
 	NodeName  string    `json:"nodeName"`
 	Timestamp time.Time `json:"timestamp"`
-	CPU       string    `json:"cpu"`
-	Memory    string    `json:"memory"`
+	// CPU y Memory son los campos v1, p. ej. "85.41%". Tras Normalize
+	// contienen el valor de Resources formateado, o "" si no hay Resources.
+	CPU    string `json:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty"`
+	// Resources es la telemetría tipada (v2). Un v2 sin Resources solo indica
+	// que el nodo está vivo, p. ej. si los sensores del agente fallaron.
+	Resources *Resources `json:"resources,omitempty"`
+	// AgentVersion es la versión del binario del agente (v2).
+	AgentVersion string `json:"agentVersion,omitempty"`
+	// DroppedTelemetry explica, tras Normalize, por qué se descartó la
+	// telemetría v1 ilegible; vacío si no se descartó nada. No se envía.
+	DroppedTelemetry string `json:"-"`
+}
+
+// ReplayRequest es el cuerpo de POST /heartbeat/replay: las muestras que
+// el agente no pudo entregar mientras el operador no era alcanzable, en
+// orden cronológico.
+type ReplayRequest struct {
+	NodeName string    `json:"nodeName"`
+	Samples  []Payload `json:"samples"`
+}
+
+// StatusOK es el Status de una respuesta a un heartbeat aceptado.
+const StatusOK = "ok"
+
+// Response es el cuerpo JSON de la respuesta a POST /heartbeat.
+type Response struct {
+	Status string `json:"status"`
+	// Config es la configuración que el agente debe aplicar, tomada de la
+	// ReducedNodePolicy que selecciona el nodo, o nil si ninguna lo hace.
+	Config *AgentConfig `json:"config,omitempty"`
+}
+
+// BatchRequest es el cuerpo de POST /heartbeat/batch: heartbeats de varios
+// nodos en una sola petición, p. ej. los que un agente en modo relay agrega
+// en un sitio remoto. Puede ir comprimido con Content-Encoding: gzip.
+type BatchRequest struct {
+	Heartbeats []Payload `json:"heartbeats"`
+}
+
+// Status de los heartbeats de un lote que no se aceptaron.
+const (
+	// StatusRejected indica un payload inválido.
+	StatusRejected = "rejected"
+	// StatusForbidden indica que el llamador no puede reportar ese nodo.
+	StatusForbidden = "forbidden"
+)
+
+// BatchResult es el resultado de un heartbeat de un BatchRequest.
+type BatchResult struct {
+	NodeName string `json:"nodeName"`
+	// Status es StatusOK, StatusRejected o StatusForbidden.
+	Status string `json:"status"`
+	// Error explica por qué no se aceptó el heartbeat.
+	Error string `json:"error,omitempty"`
+	// Config es la configuración del nodo, como en Response.
+	Config *AgentConfig `json:"config,omitempty"`
+}
+
+// BatchResponse es el cuerpo JSON de la respuesta a POST /heartbeat/batch.
+// Results sigue el orden de BatchRequest.Heartbeats.
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// AgentConfig es la configuración remota de un agente. Los campos vacíos o
+// nil conservan la configuración local del agente.
+type AgentConfig struct {
+	// Policy es la ReducedNodePolicy de la que procede la configuración.
+	Policy string `json:"policy"`
+	// HeartbeatIntervalSeconds es el periodo entre heartbeats.
+	HeartbeatIntervalSeconds int `json:"heartbeatIntervalSeconds,omitempty"`
+	// CriticalLabelKey marca los pods críticos.
+	CriticalLabelKey string `json:"criticalLabelKey,omitempty"`
+	// NodeTypeLabel y NodeTypeValue identifican los nodos reducidos.
+	NodeTypeLabel string `json:"nodeTypeLabel,omitempty"`
+	NodeTypeValue string `json:"nodeTypeValue,omitempty"`
+	// Sensors selecciona la telemetría que el agente reporta.
+	Sensors *SensorConfig `json:"sensors,omitempty"`
+}
+
+// SensorConfig activa o desactiva la telemetría opcional del agente.
+type SensorConfig struct {
+	PerCPU       *bool    `json:"perCpu,omitempty"`
+	CgroupCPU    *bool    `json:"cgroupCpu,omitempty"`
+	Pressure     *bool    `json:"pressure,omitempty"`
+	Temperatures *bool    `json:"temperatures,omitempty"`
+	Power        *bool    `json:"power,omitempty"`
+	DiskMounts   []string `json:"diskMounts,omitempty"`
+}
+
+// Resources es la telemetría numérica de un nodo.
+type Resources struct {
+	// CPUPercent es el uso de CPU del nodo, de 0 a 100, medido durante el
+	// último intervalo de heartbeat.
+	CPUPercent float64 `json:"cpuPercent"`
+	// PerCPUPercent es el uso de cada núcleo, si el agente lo reporta.
+	PerCPUPercent []float64 `json:"perCpuPercent,omitempty"`
+	// CgroupCPUPercent es el uso del cgroup del agente respecto a su cuota
+	// de CPU, si el agente lo reporta.
+	CgroupCPUPercent *float64 `json:"cgroupCpuPercent,omitempty"`
+	// MemoryPercent es el uso de memoria, de 0 a 100. En v2 se calcula a
+	// partir de MemoryUsedBytes/MemoryTotalBytes si el agente no lo envía.
+	MemoryPercent    float64 `json:"memoryPercent"`
+	MemoryUsedBytes  uint64  `json:"memoryUsedBytes,omitempty"`
+	MemoryTotalBytes uint64  `json:"memoryTotalBytes,omitempty"`
+	// Load1, Load5 y Load15 son las cargas medias de /proc/loadavg.
+	Load1  float64 `json:"load1,omitempty"`
+	Load5  float64 `json:"load5,omitempty"`
+	Load15 float64 `json:"load15,omitempty"`
+	// UptimeSeconds es el tiempo desde el arranque del nodo.
+	UptimeSeconds float64 `json:"uptimeSeconds,omitempty"`
+	// Disks es el uso de los sistemas de ficheros vigilados por el agente.
+	Disks []DiskUsage `json:"disks,omitempty"`
+	// Pressure es la Pressure Stall Information de /proc/pressure, si el
+	// kernel la expone.
+	Pressure *Pressure `json:"pressure,omitempty"`
+	// Temperatures son las zonas de /sys/class/thermal.
+	Temperatures []Temperature `json:"temperatures,omitempty"`
+	// Power es el estado de alimentación de /sys/class/power_supply, o nil
+	// si el nodo no tiene batería.
+	Power *Power `json:"power,omitempty"`
+}
+
+// Power resume las fuentes de alimentación de un nodo con batería.
+type Power struct {
+	// BatteryPercent es la capacidad restante de la batería, de 0 a 100.
+	// Con varias baterías es la media.
+	BatteryPercent float64 `json:"batteryPercent"`
+	// BatteryStatus es el estado del kernel: Charging, Discharging, Full,
+	// Not charging o Unknown.
+	BatteryStatus string `json:"batteryStatus,omitempty"`
+	// OnBattery es true si el nodo se alimenta solo de la batería.
+	OnBattery bool `json:"onBattery"`
+}
+
+// Pressure agrupa la PSI de CPU, memoria e I/O.
+type Pressure struct {
+	CPU    PressureStat `json:"cpu"`
+	Memory PressureStat `json:"memory"`
+	IO     PressureStat `json:"io"`
+}
+
+// PressureStat son las medias "some" y "full" de un recurso de PSI, en
+// porcentaje de tiempo con tareas bloqueadas.
+type PressureStat struct {
+	SomeAvg10  float64 `json:"someAvg10"`
+	SomeAvg60  float64 `json:"someAvg60"`
+	SomeAvg300 float64 `json:"someAvg300"`
+	FullAvg10  float64 `json:"fullAvg10,omitempty"`
+	FullAvg60  float64 `json:"fullAvg60,omitempty"`
+	FullAvg300 float64 `json:"fullAvg300,omitempty"`
+}
+
+// Temperature es la lectura de una zona térmica.
+type Temperature struct {
+	// Zone es el nombre del directorio, p. ej. "thermal_zone0".
+	Zone string `json:"zone"`
+	// Type es el tipo declarado por el driver, p. ej. "cpu-thermal".
+	Type    string  `json:"type,omitempty"`
+	Celsius float64 `json:"celsius"`
+}
+
+// MaxDiskPercent devuelve el uso del punto de montaje más lleno.
+func (r *Resources) MaxDiskPercent() float64 {
+	max := 0.0
+	for _, d := range r.Disks {
+		if pct := d.Percent(); pct > max {
+			max = pct
+		}
+	}
+	return max
+}
+
+// MaxCelsius devuelve la temperatura de la zona más caliente.
+func (r *Resources) MaxCelsius() float64 {
+	max := 0.0
+	for _, t := range r.Temperatures {
+		if t.Celsius > max {
+			max = t.Celsius
+		}
+	}
+	return max
+}
+
+// DiskUsage es el uso de un punto de montaje.
+type DiskUsage struct {
+	Mount      string `json:"mount"`
+	UsedBytes  uint64 `json:"usedBytes"`
+	TotalBytes uint64 `json:"totalBytes"`
+}
+
+// Percent devuelve el porcentaje usado del disco.
+func (d DiskUsage) Percent() float64 {
+	if d.TotalBytes == 0 {
+		return 0
+	}
+	return float64(d.UsedBytes) / float64(d.TotalBytes) * 100
+}
+
+// Normalize valida el payload y lo lleva a la forma v2: rellena Resources a
+// partir de los strings v1 y viceversa. Devuelve error si algún valor v2
+// está fuera de rango, en lugar de recortarlo. Un v1 con cpu o memoria
+// ilegibles o fuera de rango, p. ej. "unavailable" porque falló un sensor,
+// sigue siendo un heartbeat válido: como un v2 sin Resources, solo indica
+// que el nodo está vivo, y DroppedTelemetry explica qué se descartó.
+func (p *Payload) Normalize() error {
+	if p.NodeName == "" {
+		return errors.New("nodeName is required")
+	}
+
+	switch p.Version {
+	case 0, Version1:
+		p.Version = Version1
+		cpu, err := parseV1Percent("cpu", p.CPU)
+		if err != nil {
+			p.dropTelemetry(err.Error())
+			return nil
+		}
+		mem, err := parseV1Percent("memory", p.Memory)
+		if err != nil {
+			p.dropTelemetry(err.Error())
+			return nil
+		}
+		p.Resources = &Resources{CPUPercent: cpu, MemoryPercent: mem}
+	case Version2:
+		if p.Resources == nil {
+			p.CPU, p.Memory = "", ""
+			return nil
+		}
+		if err := p.Resources.normalize(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported payload version %d", p.Version)
+	}
+
+	if err := checkPercent("cpu", p.Resources.CPUPercent); err != nil {
+		return err
+	}
+	if err := checkPercent("memory", p.Resources.MemoryPercent); err != nil {
+		return err
+	}
+	p.CPU = FormatPercent(p.Resources.CPUPercent)
+	p.Memory = FormatPercent(p.Resources.MemoryPercent)
+	return nil
+}
+
+// parseV1Percent interpreta el campo v1 field y comprueba su rango.
+func parseV1Percent(field, s string) (float64, error) {
+	v, err := ParsePercent(s)
+	if err == nil {
+		err = checkPercent(field, v)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", field, s, err)
+	}
+	return v, nil
+}
+
+// dropTelemetry deja el payload como heartbeat de solo vida.
+func (p *Payload) dropTelemetry(reason string) {
+	p.DroppedTelemetry = reason
+	p.Resources = nil
+	p.CPU, p.Memory = "", ""
+}
+
+func (r *Resources) normalize() error {
+	if r.MemoryUsedBytes > r.MemoryTotalBytes {
+		return fmt.Errorf("memoryUsedBytes %d exceeds memoryTotalBytes %d", r.MemoryUsedBytes, r.MemoryTotalBytes)
+	}
+	if r.MemoryPercent == 0 && r.MemoryTotalBytes > 0 {
+		r.MemoryPercent = float64(r.MemoryUsedBytes) / float64(r.MemoryTotalBytes) * 100
+	}
+	for _, v := range []float64{r.Load1, r.Load5, r.Load15, r.UptimeSeconds} {
+		if math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+			return fmt.Errorf("invalid load or uptime value %v", v)
+		}
+	}
+	for _, v := range r.PerCPUPercent {
+		if err := checkPercent("per-cpu", v); err != nil {
+			return err
+		}
+	}
+	if r.CgroupCPUPercent != nil {
+		if err := checkPercent("cgroup cpu", *r.CgroupCPUPercent); err != nil {
+			return err
+		}
+	}
+	for _, d := range r.Disks {
+		if d.Mount == "" || d.UsedBytes > d.TotalBytes {
+			return fmt.Errorf("invalid disk usage for mount %q", d.Mount)
+		}
+	}
+	if r.Pressure != nil {
+		for name, stat := range map[string]PressureStat{
+			"cpu": r.Pressure.CPU, "memory": r.Pressure.Memory, "io": r.Pressure.IO,
+		} {
+			for _, v := range []float64{stat.SomeAvg10, stat.SomeAvg60, stat.SomeAvg300,
+				stat.FullAvg10, stat.FullAvg60, stat.FullAvg300} {
+				if err := checkPercent(name+" pressure", v); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if r.Power != nil {
+		if err := checkPercent("battery", r.Power.BatteryPercent); err != nil {
+			return err
+		}
+	}
+	for _, t := range r.Temperatures {
+		// Rango físico amplio: solo descarta lecturas imposibles de sensores rotos
+		if t.Zone == "" || math.IsNaN(t.Celsius) || t.Celsius < -100 || t.Celsius > 250 {
+			return fmt.Errorf("invalid temperature %v for zone %q", t.Celsius, t.Zone)
+		}
+	}
+	return nil
+}
+
+func checkPercent(field string, v float64) error {
+	if math.IsNaN(v) || v < 0 || v > 100 {
+		return fmt.Errorf("%s percent %v out of range [0, 100]", field, v)
+	}
+	return nil
+}
+
+// ParsePercent convierte "85.41%" → 85.41
+func ParsePercent(s string) (float64, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "%")
+	return strconv.ParseFloat(strings.TrimSpace(s), 64)
+}
+
+// FormatPercent convierte 85.41 → "85.41%", el formato de los campos v1.
+func FormatPercent(v float64) string {
+	return fmt.Sprintf("%.2f%%", v)
 }
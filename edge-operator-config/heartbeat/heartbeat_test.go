@@ -0,0 +1,100 @@
+package heartbeat
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalize(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name    string
+		payload Payload
+		wantErr string
+		wantCPU float64
+		wantMem float64
+	}{
+		{
+			name:    "v1 sin versión",
+			payload: Payload{NodeName: "n1", Timestamp: ts, CPU: "85.41%", Memory: "40%"},
+			wantCPU: 85.41,
+			wantMem: 40,
+		},
+		{
+			name: "v2 fuera de rango",
+			payload: Payload{Version: Version2, NodeName: "n1", Timestamp: ts, Resources: &Resources{
+				CPUPercent: 140,
+			}},
+			wantErr: "out of range",
+		},
+		{
+			name: "v2 calcula el porcentaje de memoria",
+			payload: Payload{Version: Version2, NodeName: "n1", Timestamp: ts, Resources: &Resources{
+				CPUPercent: 12.5, MemoryUsedBytes: 1 << 30, MemoryTotalBytes: 4 << 30,
+			}},
+			wantCPU: 12.5,
+			wantMem: 25,
+		},
+		{
+			name: "v2 memoria usada mayor que total",
+			payload: Payload{Version: Version2, NodeName: "n1", Timestamp: ts, Resources: &Resources{
+				MemoryUsedBytes: 2, MemoryTotalBytes: 1,
+			}},
+			wantErr: "exceeds",
+		},
+		{
+			name:    "versión desconocida",
+			payload: Payload{Version: 9, NodeName: "n1", Timestamp: ts},
+			wantErr: "unsupported payload version",
+		},
+		{
+			name:    "sin nodeName",
+			payload: Payload{CPU: "1%", Memory: "1%"},
+			wantErr: "nodeName is required",
+		},
+	}
+
+	liveness := Payload{Version: Version2, NodeName: "n1", Timestamp: ts, CPU: "1%"}
+	if err := liveness.Normalize(); err != nil || liveness.Resources != nil || liveness.CPU != "" {
+		t.Errorf("un v2 sin resources es un heartbeat de solo vida: %+v, %v", liveness, err)
+	}
+
+	// Un sensor roto del agente v1 no debe hacer parecer caído el nodo, ni
+	// con un valor ilegible ni con uno fuera de rango
+	for _, broken := range []Payload{
+		{NodeName: "n1", Timestamp: ts, CPU: "unavailable", Memory: "40%"},
+		{NodeName: "n1", Timestamp: ts, CPU: "140%", Memory: "40%"},
+		{NodeName: "n1", Timestamp: ts, CPU: "40%", Memory: "-1%"},
+	} {
+		cpu, mem := broken.CPU, broken.Memory
+		if err := broken.Normalize(); err != nil || broken.Resources != nil || broken.CPU != "" || broken.Memory != "" {
+			t.Errorf("un v1 con cpu %q y memoria %q es un heartbeat de solo vida: %+v, %v", cpu, mem, broken, err)
+		}
+		if !strings.Contains(broken.DroppedTelemetry, "invalid ") {
+			t.Errorf("DroppedTelemetry = %q, se esperaba el motivo", broken.DroppedTelemetry)
+		}
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := tc.payload
+			err := p.Normalize()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("se esperaba error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error inesperado: %v", err)
+			}
+			if p.Resources.CPUPercent != tc.wantCPU || p.Resources.MemoryPercent != tc.wantMem {
+				t.Errorf("resources = %+v, se esperaba cpu=%v mem=%v", *p.Resources, tc.wantCPU, tc.wantMem)
+			}
+			if p.CPU != FormatPercent(tc.wantCPU) || p.Memory != FormatPercent(tc.wantMem) {
+				t.Errorf("campos v1 = %q/%q", p.CPU, p.Memory)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// Package stream define el transporte gRPC de heartbeats: un stream
+// bidireccional por agente, en el que el agente envía heartbeats y el
+// operador responde y le envía comandos. Que el stream se rompa es en sí
+// una señal de vida: el operador no espera al timeout para saberlo.
+//
+// Los mensajes son las estructuras del paquete heartbeat codificadas en
+// JSON con el códec CodecName, así que no hace falta protoc; el descriptor
+// del servicio está escrito a mano con la forma que generaría
+// protoc-gen-go-grpc.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+
+	"github.com/jaiderssjgod/edge-operator/heartbeat"
+)
+
+// ServiceName es el nombre del servicio gRPC.
+const ServiceName = "edge.heartbeat.v1.HeartbeatService"
+
+const streamMethod = "/" + ServiceName + "/Stream"
+
+// CodecName es el content-subtype de los mensajes: application/grpc+json.
+const CodecName = "json"
+
+type codec struct{}
+
+func (codec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (codec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (codec) Name() string                       { return CodecName }
+
+func init() {
+	encoding.RegisterCodec(codec{})
+}
+
+// AgentMessage es un mensaje del agente al operador.
+type AgentMessage struct {
+	Heartbeat *heartbeat.Payload `json:"heartbeat,omitempty"`
+}
+
+// OperatorMessage es un mensaje del operador al agente: la respuesta a un
+// heartbeat o un comando.
+type OperatorMessage struct {
+	// Response responde a cada heartbeat, como el cuerpo de POST
+	// /heartbeat. Su Status es heartbeat.StatusOK o heartbeat.StatusRejected.
+	Response *heartbeat.Response `json:"response,omitempty"`
+	// Error explica un heartbeat rechazado.
+	Error   string   `json:"error,omitempty"`
+	Command *Command `json:"command,omitempty"`
+}
+
+// Tipos de Command.
+const (
+	// CommandApplyConfig entrega la configuración remota en cuanto cambia,
+	// sin esperar a la respuesta del siguiente heartbeat.
+	CommandApplyConfig = "applyConfig"
+)
+
+// Command es una orden del operador al agente. Los agentes ignoran los
+// tipos que no conocen.
+type Command struct {
+	Type string `json:"type"`
+	// Config es la configuración de CommandApplyConfig; nil vuelve a la
+	// configuración local del agente.
+	Config *heartbeat.AgentConfig `json:"config,omitempty"`
+}
+
+// Server es el servicio que implementa el operador.
+type Server interface {
+	// Stream atiende el stream de un agente hasta que se cierra.
+	Stream(ServerStream) error
+}
+
+// ServerStream es el lado del operador de un stream.
+type ServerStream interface {
+	Send(*OperatorMessage) error
+	Recv() (*AgentMessage, error)
+	grpc.ServerStream
+}
+
+type serverStream struct {
+	grpc.ServerStream
+}
+
+func (s serverStream) Send(m *OperatorMessage) error {
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s serverStream) Recv() (*AgentMessage, error) {
+	m := new(AgentMessage)
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ClientStream es el lado del agente de un stream.
+type ClientStream interface {
+	Send(*AgentMessage) error
+	Recv() (*OperatorMessage, error)
+	grpc.ClientStream
+}
+
+type clientStream struct {
+	grpc.ClientStream
+}
+
+func (s clientStream) Send(m *AgentMessage) error {
+	return s.ClientStream.SendMsg(m)
+}
+
+func (s clientStream) Recv() (*OperatorMessage, error) {
+	m := new(OperatorMessage)
+	if err := s.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*Server)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName: "Stream",
+		Handler: func(srv any, s grpc.ServerStream) error {
+			return srv.(Server).Stream(serverStream{s})
+		},
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+	Metadata: "heartbeat/stream/stream.go",
+}
+
+// RegisterServer registra srv en s.
+func RegisterServer(s grpc.ServiceRegistrar, srv Server) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+// Open abre un stream con el operador en cc. El stream vive hasta que se
+// cancela ctx o se rompe la conexión.
+func Open(ctx context.Context, cc grpc.ClientConnInterface, opts ...grpc.CallOption) (ClientStream, error) {
+	opts = append([]grpc.CallOption{grpc.CallContentSubtype(CodecName)}, opts...)
+	s, err := cc.NewStream(ctx, &serviceDesc.Streams[0], streamMethod, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return clientStream{s}, nil
+}
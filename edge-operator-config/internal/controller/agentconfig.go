@@ -0,0 +1,29 @@
+package controller
+
+import (
+	iotv1alpha1 "github.com/jaiderssjgod/edge-operator/api/v1alpha1"
+	"github.com/jaiderssjgod/edge-operator/heartbeat"
+)
+
+// agentConfig traduce la spec de la policy, ya con sus valores por defecto,
+// a la configuración remota que se envía a los agentes de sus nodos.
+func agentConfig(policy *iotv1alpha1.ReducedNodePolicy) heartbeat.AgentConfig {
+	cfg := heartbeat.AgentConfig{
+		Policy:                   policy.Name,
+		HeartbeatIntervalSeconds: policy.Spec.HeartbeatIntervalSeconds,
+		CriticalLabelKey:         policy.Spec.CriticalLabelKey,
+		NodeTypeLabel:            policy.Spec.NodeLabelKey,
+		NodeTypeValue:            policy.Spec.NodeLabelValue,
+	}
+	if a := policy.Spec.Agent; a != nil {
+		cfg.Sensors = &heartbeat.SensorConfig{
+			PerCPU:       a.ReportPerCPU,
+			CgroupCPU:    a.ReportCgroupCPU,
+			Pressure:     a.ReportPressure,
+			Temperatures: a.ReportTemperatures,
+			Power:        a.ReportPower,
+			DiskMounts:   a.DiskMounts,
+		}
+	}
+	return cfg
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	iotv1alpha1 "github.com/jaiderssjgod/edge-operator/api/v1alpha1"
+)
+
+// reconcileSummary recoge lo observado durante un reconcile para derivar
+// las condiciones de la policy.
+type reconcileSummary struct {
+	offlineNodes  []string
+	exceededNodes []string
+	// blockedNodes son los nodos con evicciones bloqueadas por un
+	// PodDisruptionBudget, que se reintentan antes del requeue normal.
+	blockedNodes []string
+	// err y errReason describen el primer fallo del reconcile, si lo hubo.
+	err       error
+	errReason string
+}
+
+// fail registra un error del reconcile conservando el primero.
+func (s *reconcileSummary) fail(reason string, err error) {
+	if s.err == nil {
+		s.err, s.errReason = err, reason
+	}
+}
+
+// setConditions actualiza las condiciones estándar de la policy a partir del
+// resumen del reconcile y del estado por nodo ya calculado.
+func setConditions(policy *iotv1alpha1.ReducedNodePolicy, summary reconcileSummary) {
+	gen := policy.Generation
+	set := func(condType string, status bool, reason, message string) {
+		c := metav1.Condition{
+			Type:               condType,
+			Status:             metav1.ConditionFalse,
+			Reason:             reason,
+			Message:            message,
+			ObservedGeneration: gen,
+		}
+		if status {
+			c.Status = metav1.ConditionTrue
+		}
+		meta.SetStatusCondition(&policy.Status.Conditions, c)
+	}
+
+	if summary.err != nil {
+		set(iotv1alpha1.ConditionReconcileError, true, summary.errReason, summary.err.Error())
+		set(iotv1alpha1.ConditionReady, false, iotv1alpha1.ReasonReconcileFailed, summary.err.Error())
+	} else {
+		set(iotv1alpha1.ConditionReconcileError, false, iotv1alpha1.ReasonNoError, "")
+		set(iotv1alpha1.ConditionReady, true, iotv1alpha1.ReasonReconciled,
+			fmt.Sprintf("%d nodos observados", policy.Status.ObservedNodes))
+	}
+	policy.Status.ObservedGeneration = gen
+
+	// Con la spec inválida o sin lista de nodos el resto de condiciones no
+	// se puede evaluar: se conservan las del último reconcile correcto.
+	if summary.errReason == iotv1alpha1.ReasonInvalidSpec || summary.errReason == iotv1alpha1.ReasonNodeListFailed {
+		return
+	}
+
+	if len(summary.offlineNodes) > 0 {
+		set(iotv1alpha1.ConditionNodesOffline, true, iotv1alpha1.ReasonNodesOffline,
+			"nodos offline: "+joinSorted(summary.offlineNodes))
+	} else {
+		set(iotv1alpha1.ConditionNodesOffline, false, iotv1alpha1.ReasonAllNodesOnline, "")
+	}
+
+	if len(summary.exceededNodes) > 0 {
+		set(iotv1alpha1.ConditionThresholdExceeded, true, iotv1alpha1.ReasonThresholdExceeded,
+			"nodos sobre umbral: "+joinSorted(summary.exceededNodes))
+	} else {
+		set(iotv1alpha1.ConditionThresholdExceeded, false, iotv1alpha1.ReasonWithinThresholds, "")
+	}
+
+	var degraded []string
+	for name, node := range policy.Status.Nodes {
+		if len(node.DegradedTiers) > 0 || node.ResourceDegradationExecuted || len(node.ResourceDegradedTiers) > 0 {
+			degraded = append(degraded, name)
+		}
+	}
+	if len(degraded) > 0 {
+		set(iotv1alpha1.ConditionDegrading, true, iotv1alpha1.ReasonWorkloadsDegraded,
+			"cargas degradadas en: "+joinSorted(degraded))
+	} else {
+		set(iotv1alpha1.ConditionDegrading, false, iotv1alpha1.ReasonNoDegradation, "")
+	}
+}
+
+func joinSorted(names []string) string {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ", ")
+}
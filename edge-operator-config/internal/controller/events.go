@@ -0,0 +1,34 @@
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	iotv1alpha1 "github.com/jaiderssjgod/edge-operator/api/v1alpha1"
+)
+
+// Razones de los Events que el reconciler registra sobre Nodes y policies.
+const (
+	ReasonNodeOffline        = "EdgeNodeOffline"
+	ReasonNodeRecovered      = "EdgeNodeRecovered"
+	ReasonGracePeriodExpired = "EdgeGracePeriodExpired"
+	ReasonTierDegraded       = "EdgeTierDegraded"
+	ReasonDegradationBlocked = "EdgeDegradationBlocked"
+	ReasonDegradationFailed  = "EdgeDegradationFailed"
+	ReasonThresholdExceeded  = "EdgeResourceThresholdExceeded"
+	ReasonWorkloadsRestored  = "EdgeWorkloadsRestored"
+	ReasonInvalidPolicySpec  = "EdgeInvalidPolicySpec"
+	ReasonHeartbeatsReplayed = "EdgeHeartbeatsReplayed"
+)
+
+// nodeEvent registra el mismo Event sobre el Node y sobre la policy, para
+// que aparezca en `kubectl describe` de ambos.
+func (r *ReducedNodePolicyReconciler) nodeEvent(
+	policy *iotv1alpha1.ReducedNodePolicy, node *corev1.Node,
+	eventType, reason, messageFmt string, args ...interface{},
+) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(node, eventType, reason, messageFmt, args...)
+	r.Recorder.Eventf(policy, eventType, reason, "nodo %s: "+messageFmt, append([]interface{}{node.Name}, args...)...)
+}
@@ -0,0 +1,591 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	iotv1alpha1 "github.com/jaiderssjgod/edge-operator/api/v1alpha1"
+	"github.com/jaiderssjgod/edge-operator/heartbeat"
+	"github.com/jaiderssjgod/edge-operator/internal/degradation"
+	"github.com/jaiderssjgod/edge-operator/internal/heartbeatstore"
+)
+
+func TestCheckResourceThresholds_EscalatesOneTierPerReconcile(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(
+		tierDeployment("batch", "best-effort"),
+		tierPod("batch-abc", "best-effort", "batch"),
+		tierDeployment("web", "non-critical"),
+		tierPod("web-abc", "non-critical", "web"),
+	)
+	r := &ReducedNodePolicyReconciler{
+		Client:             c,
+		DegradationManager: degradation.New(c, logr.Discard()),
+	}
+	policy := &iotv1alpha1.ReducedNodePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-a"},
+		Spec: iotv1alpha1.ReducedNodePolicySpec{
+			PriorityLabelKey: iotv1alpha1.DefaultPriorityLabelKey,
+			WorkloadLabelKey: iotv1alpha1.DefaultWorkloadLabelKey,
+			PriorityTiers: []iotv1alpha1.PriorityTier{
+				{Name: "best-effort", MaxCPUThreshold: 70},
+				{Name: "non-critical", MaxCPUThreshold: 90},
+			},
+		},
+	}
+	hbStatus := &iotv1alpha1.NodeHeartbeatStatus{State: "online", CPU: "95.00%"}
+	state := cpuUsage(95)
+
+	r.checkResourceThresholds(ctx, logr.Discard(), policy, testNode("node-1"), state, hbStatus)
+	if replicas(t, c, "batch") != 0 || replicas(t, c, "web") != 2 {
+		t.Fatalf("el primer reconcile solo debe degradar best-effort (batch=%d, web=%d)",
+			replicas(t, c, "batch"), replicas(t, c, "web"))
+	}
+
+	r.checkResourceThresholds(ctx, logr.Discard(), policy, testNode("node-1"), state, hbStatus)
+	if replicas(t, c, "web") != 0 {
+		t.Fatal("con presión persistente el segundo reconcile debe degradar non-critical")
+	}
+	if len(hbStatus.ResourceDegradedTiers) != 2 {
+		t.Errorf("niveles degradados inesperados: %v", hbStatus.ResourceDegradedTiers)
+	}
+
+	hbStatus.CPU = "20.00%"
+	state = cpuUsage(20)
+	r.checkResourceThresholds(ctx, logr.Discard(), policy, testNode("node-1"), state, hbStatus)
+	if replicas(t, c, "batch") != 2 || replicas(t, c, "web") != 2 {
+		t.Error("al normalizarse los recursos deben restaurarse todos los niveles")
+	}
+	if hbStatus.ResourceDegradationExecuted || len(hbStatus.ResourceDegradedTiers) != 0 {
+		t.Errorf("el estado de degradación debería limpiarse: %+v", hbStatus)
+	}
+}
+
+func TestCheckResourceThresholds_DoesNotEscalateBelowNextTierThreshold(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(
+		tierDeployment("batch", "best-effort"),
+		tierPod("batch-abc", "best-effort", "batch"),
+		tierDeployment("web", "non-critical"),
+		tierPod("web-abc", "non-critical", "web"),
+	)
+	r := &ReducedNodePolicyReconciler{
+		Client:             c,
+		DegradationManager: degradation.New(c, logr.Discard()),
+	}
+	policy := &iotv1alpha1.ReducedNodePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-a"},
+		Spec: iotv1alpha1.ReducedNodePolicySpec{
+			PriorityLabelKey: iotv1alpha1.DefaultPriorityLabelKey,
+			WorkloadLabelKey: iotv1alpha1.DefaultWorkloadLabelKey,
+			PriorityTiers: []iotv1alpha1.PriorityTier{
+				{Name: "best-effort", MaxCPUThreshold: 70},
+				{Name: "non-critical", MaxCPUThreshold: 90},
+			},
+		},
+	}
+	hbStatus := &iotv1alpha1.NodeHeartbeatStatus{State: "online", CPU: "80.00%"}
+	state := cpuUsage(80)
+
+	for i := 0; i < 3; i++ {
+		r.checkResourceThresholds(ctx, logr.Discard(), policy, testNode("node-1"), state, hbStatus)
+	}
+	if replicas(t, c, "batch") != 0 {
+		t.Error("best-effort debería estar degradado")
+	}
+	if replicas(t, c, "web") != 2 {
+		t.Error("non-critical no debería degradarse por debajo de su umbral")
+	}
+}
+
+func cpuUsage(percent float64) heartbeatstore.NodeState {
+	return heartbeatstore.NodeState{Resources: &heartbeat.Resources{CPUPercent: percent}}
+}
+
+func testNode(name string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+func newTestClient(objs ...client.Object) client.Client {
+	return newTestClientWithInterceptor(interceptor.Funcs{}, objs...)
+}
+
+func newTestClientWithInterceptor(funcs interceptor.Funcs, objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = appsv1.AddToScheme(scheme)
+	_ = batchv1.AddToScheme(scheme)
+	_ = iotv1alpha1.AddToScheme(scheme)
+
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&iotv1alpha1.ReducedNodePolicy{}).
+		WithIndex(&corev1.Pod{}, "spec.nodeName", func(obj client.Object) []string {
+			return []string{obj.(*corev1.Pod).Spec.NodeName}
+		}).
+		WithInterceptorFuncs(funcs).
+		Build()
+}
+
+func tierDeployment(name, priority string) *appsv1.Deployment {
+	replicas := int32(2)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{iotv1alpha1.DefaultPriorityLabelKey: priority},
+		},
+		Spec: appsv1.DeploymentSpec{Replicas: &replicas},
+	}
+}
+
+func tierPod(name, priority, app string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{iotv1alpha1.DefaultPriorityLabelKey: priority, "app": app},
+		},
+		Spec:   corev1.PodSpec{NodeName: "node-1"},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func replicas(t *testing.T, c client.Client, name string) int32 {
+	t.Helper()
+	var deploy appsv1.Deployment
+	if err := c.Get(context.Background(), client.ObjectKey{Name: name, Namespace: "default"}, &deploy); err != nil {
+		t.Fatalf("no se pudo obtener deployment %s: %v", name, err)
+	}
+	return *deploy.Spec.Replicas
+}
+
+func TestGracePeriod_PolicyOverridesEnv(t *testing.T) {
+	t.Setenv("GRACE_PERIOD_SECONDS", "120")
+
+	policy := &iotv1alpha1.ReducedNodePolicy{}
+	if got := gracePeriod(policy); got != 120*time.Second {
+		t.Errorf("sin gracePeriodSeconds debería usarse el entorno, got %s", got)
+	}
+
+	policy.Spec.GracePeriodSeconds = 30
+	if got := gracePeriod(policy); got != 30*time.Second {
+		t.Errorf("gracePeriodSeconds de la policy debería tener prioridad, got %s", got)
+	}
+}
+
+func TestReconcile_SetsConditions(t *testing.T) {
+	ctx := context.Background()
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "node-1",
+		Labels: map[string]string{iotv1alpha1.DefaultNodeLabelKey: iotv1alpha1.DefaultNodeLabelValue},
+	}}
+	policy := &iotv1alpha1.ReducedNodePolicy{ObjectMeta: metav1.ObjectMeta{Name: "policy-a"}}
+	c := newTestClient(node, policy)
+	r := &ReducedNodePolicyReconciler{
+		Client:             c,
+		Log:                logr.Discard(),
+		HeartbeatStore:     heartbeatstore.New(30 * time.Second),
+		DegradationManager: degradation.New(c, logr.Discard()),
+	}
+
+	// Sin heartbeats el nodo está offline pero dentro del grace period
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "policy-a"}}); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	var got iotv1alpha1.ReducedNodePolicy
+	if err := c.Get(ctx, client.ObjectKey{Name: "policy-a"}, &got); err != nil {
+		t.Fatal(err)
+	}
+	for condType, want := range map[string]metav1.ConditionStatus{
+		iotv1alpha1.ConditionReady:             metav1.ConditionTrue,
+		iotv1alpha1.ConditionReconcileError:    metav1.ConditionFalse,
+		iotv1alpha1.ConditionNodesOffline:      metav1.ConditionTrue,
+		iotv1alpha1.ConditionThresholdExceeded: metav1.ConditionFalse,
+		iotv1alpha1.ConditionDegrading:         metav1.ConditionFalse,
+	} {
+		cond := meta.FindStatusCondition(got.Status.Conditions, condType)
+		if cond == nil {
+			t.Errorf("falta la condición %s", condType)
+			continue
+		}
+		if cond.Status != want || cond.ObservedGeneration != got.Generation {
+			t.Errorf("%s: status=%s observedGeneration=%d, se esperaba %s/%d",
+				condType, cond.Status, cond.ObservedGeneration, want, got.Generation)
+		}
+	}
+}
+
+func TestReconcile_InvalidSpecSetsReconcileError(t *testing.T) {
+	ctx := context.Background()
+	policy := &iotv1alpha1.ReducedNodePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-a"},
+		Spec: iotv1alpha1.ReducedNodePolicySpec{
+			PriorityTiers: []iotv1alpha1.PriorityTier{{Name: "dup"}, {Name: "dup"}},
+		},
+	}
+	c := newTestClient(policy)
+	r := &ReducedNodePolicyReconciler{
+		Client:             c,
+		Log:                logr.Discard(),
+		HeartbeatStore:     heartbeatstore.New(30 * time.Second),
+		DegradationManager: degradation.New(c, logr.Discard()),
+	}
+
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "policy-a"}}); err != nil {
+		t.Fatalf("una spec inválida no debería devolver error: %v", err)
+	}
+
+	var got iotv1alpha1.ReducedNodePolicy
+	if err := c.Get(ctx, client.ObjectKey{Name: "policy-a"}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !meta.IsStatusConditionTrue(got.Status.Conditions, iotv1alpha1.ConditionReconcileError) {
+		t.Error("ReconcileError debería ser True")
+	}
+	if ready := meta.FindStatusCondition(got.Status.Conditions, iotv1alpha1.ConditionReady); ready == nil ||
+		ready.Status != metav1.ConditionFalse || ready.Reason != iotv1alpha1.ReasonReconcileFailed {
+		t.Errorf("Ready debería ser False/ReconcileFailed: %+v", ready)
+	}
+}
+
+func TestExceededSignals_DiskPressureAndTemperature(t *testing.T) {
+	tier := iotv1alpha1.PriorityTier{
+		Name:                  "best-effort",
+		MaxDiskThreshold:      90,
+		MaxIOPressure:         40,
+		MaxTemperatureCelsius: 80,
+	}
+
+	// Sin discos, PSI ni zonas térmicas ninguna señal puede dispararse
+	if got := exceededSignals(tier, &heartbeat.Resources{CPUPercent: 99}); len(got) != 0 {
+		t.Errorf("señales no reportadas no deben superar umbrales: %v", got)
+	}
+
+	res := &heartbeat.Resources{
+		Disks: []heartbeat.DiskUsage{
+			{Mount: "/", UsedBytes: 10, TotalBytes: 100},
+			{Mount: "/data", UsedBytes: 95, TotalBytes: 100},
+		},
+		Pressure:     &heartbeat.Pressure{IO: heartbeat.PressureStat{SomeAvg10: 12}},
+		Temperatures: []heartbeat.Temperature{{Zone: "thermal_zone0", Celsius: 85.5}},
+	}
+	got := exceededSignals(tier, res)
+	want := []string{"disk 95.00% >= 90%", "temperature 85.50°C >= 80°C"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("señales = %v, se esperaba %v", got, want)
+	}
+}
+
+func TestExceededSignals_Battery(t *testing.T) {
+	tier := iotv1alpha1.PriorityTier{Name: "best-effort", MinBatteryPercent: 20, DegradeOnBattery: true}
+	if !hasResourceThresholds(tier) {
+		t.Fatal("los umbrales de batería deben contar como umbrales de recursos")
+	}
+
+	cases := []struct {
+		power *heartbeat.Power
+		want  []string
+	}{
+		{nil, nil},
+		{&heartbeat.Power{BatteryPercent: 80, BatteryStatus: "Charging"}, nil},
+		{&heartbeat.Power{BatteryPercent: 80, OnBattery: true}, []string{"on battery power"}},
+		{&heartbeat.Power{BatteryPercent: 15, OnBattery: true}, []string{"battery 15.00% < 20%", "on battery power"}},
+	}
+	for _, tc := range cases {
+		got := exceededSignals(tier, &heartbeat.Resources{Power: tc.power})
+		if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+			t.Errorf("power %+v: señales = %v, se esperaba %v", tc.power, got, tc.want)
+		}
+	}
+}
+
+func TestReconcile_RecordsReplayedWindowOnce(t *testing.T) {
+	ctx := context.Background()
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "node-1",
+		Labels: map[string]string{iotv1alpha1.DefaultNodeLabelKey: iotv1alpha1.DefaultNodeLabelValue},
+	}}
+	policy := &iotv1alpha1.ReducedNodePolicy{ObjectMeta: metav1.ObjectMeta{Name: "policy-a"}}
+	c := newTestClient(node, policy)
+	store := heartbeatstore.New(30 * time.Second)
+	r := &ReducedNodePolicyReconciler{
+		Client:             c,
+		Log:                logr.Discard(),
+		HeartbeatStore:     store,
+		DegradationManager: degradation.New(c, logr.Discard()),
+	}
+
+	now := time.Now()
+	store.Record(heartbeat.Payload{NodeName: "node-1", Timestamp: now,
+		Resources: &heartbeat.Resources{CPUPercent: 10}})
+	store.RecordReplay("node-1", []heartbeat.Payload{
+		{NodeName: "node-1", Timestamp: now.Add(-5 * time.Minute), Resources: &heartbeat.Resources{CPUPercent: 99}},
+		{NodeName: "node-1", Timestamp: now.Add(-time.Minute), Resources: &heartbeat.Resources{CPUPercent: 30}},
+	})
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "policy-a"}}
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			t.Fatalf("reconcile %d: %v", i, err)
+		}
+	}
+
+	var got iotv1alpha1.ReducedNodePolicy
+	if err := c.Get(ctx, client.ObjectKey{Name: "policy-a"}, &got); err != nil {
+		t.Fatal(err)
+	}
+	events := got.Status.Nodes["node-1"].OfflineEvents
+	if len(events) != 1 {
+		t.Fatalf("la ventana reproducida debe registrarse una sola vez: %v", events)
+	}
+	if !strings.Contains(events[0], "replayed 2 samples") || !strings.Contains(events[0], "peak cpu: 99.00%") {
+		t.Errorf("evento inesperado: %s", events[0])
+	}
+}
+
+func TestReconcile_PushesAgentConfigAndForgetsDeletedPolicy(t *testing.T) {
+	ctx := context.Background()
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "node-1",
+		Labels: map[string]string{iotv1alpha1.DefaultNodeLabelKey: iotv1alpha1.DefaultNodeLabelValue},
+	}}
+	perCPU := true
+	policy := &iotv1alpha1.ReducedNodePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-a"},
+		Spec: iotv1alpha1.ReducedNodePolicySpec{
+			CriticalLabelKey:         "edge/critical",
+			HeartbeatIntervalSeconds: 30,
+			Agent:                    &iotv1alpha1.AgentSettings{ReportPerCPU: &perCPU, DiskMounts: []string{"/data"}},
+		},
+	}
+	c := newTestClient(node, policy)
+	store := heartbeatstore.New(30 * time.Second)
+	r := &ReducedNodePolicyReconciler{
+		Client:             c,
+		Log:                logr.Discard(),
+		HeartbeatStore:     store,
+		DegradationManager: degradation.New(c, logr.Discard()),
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "policy-a"}}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	cfg, ok := store.AgentConfig("node-1")
+	if !ok {
+		t.Fatal("el nodo seleccionado debe tener configuración remota")
+	}
+	if cfg.Policy != "policy-a" || cfg.HeartbeatIntervalSeconds != 30 || cfg.CriticalLabelKey != "edge/critical" ||
+		cfg.NodeTypeLabel != iotv1alpha1.DefaultNodeLabelKey || cfg.NodeTypeValue != iotv1alpha1.DefaultNodeLabelValue {
+		t.Errorf("configuración inesperada: %+v", cfg)
+	}
+	if cfg.Sensors == nil || cfg.Sensors.PerCPU == nil || !*cfg.Sensors.PerCPU || len(cfg.Sensors.DiskMounts) != 1 {
+		t.Errorf("sensores inesperados: %+v", cfg.Sensors)
+	}
+
+	if err := c.Delete(ctx, policy); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("reconcile tras borrar: %v", err)
+	}
+	if _, ok := store.AgentConfig("node-1"); ok {
+		t.Error("una policy borrada no debe seguir configurando el agente")
+	}
+}
+
+func TestReconcile_DeletedPolicyRestoresItsWorkloads(t *testing.T) {
+	ctx := context.Background()
+	degraded := func(name, policy, node string) *appsv1.Deployment {
+		d := tierDeployment(name, "best-effort")
+		zero := int32(0)
+		d.Spec.Replicas = &zero
+		d.Labels[degradation.DegradedLabel] = "true"
+		d.Annotations = map[string]string{
+			degradation.DegradationActionAnnotation: degradation.ActionScaled,
+			degradation.OriginalReplicasAnnotation:  "2",
+			degradation.DegradedPolicyAnnotation:    policy,
+			degradation.DegradedNodeAnnotation:      node,
+		}
+		return d
+	}
+	policy := &iotv1alpha1.ReducedNodePolicy{ObjectMeta: metav1.ObjectMeta{Name: "policy-a"}}
+	c := newTestClient(policy,
+		degraded("batch", "policy-a", "node-1"),
+		degraded("web", "policy-a", "node-2"),
+		degraded("other", "policy-b", "node-1"),
+	)
+	r := &ReducedNodePolicyReconciler{
+		Client:             c,
+		Log:                logr.Discard(),
+		HeartbeatStore:     heartbeatstore.New(30 * time.Second),
+		DegradationManager: degradation.New(c, logr.Discard()),
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "policy-a"}}
+
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	var got iotv1alpha1.ReducedNodePolicy
+	if err := c.Get(ctx, client.ObjectKey{Name: "policy-a"}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Finalizers) != 1 || got.Finalizers[0] != restoreFinalizer {
+		t.Fatalf("finalizers = %v, se esperaba %s", got.Finalizers, restoreFinalizer)
+	}
+
+	// Con el finalizer el borrado espera al reconcile que restaura
+	if err := c.Delete(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, req); err != nil {
+		t.Fatalf("reconcile tras borrar: %v", err)
+	}
+	if replicas(t, c, "batch") != 2 || replicas(t, c, "web") != 2 {
+		t.Error("las cargas de la policy borrada deben restaurarse en todos sus nodos")
+	}
+	if replicas(t, c, "other") != 0 {
+		t.Error("las cargas de otra policy no deben tocarse")
+	}
+	if err := c.Get(ctx, client.ObjectKey{Name: "policy-a"}, &got); err == nil {
+		t.Errorf("la policy debía borrarse al retirar el finalizer: %+v", got.ObjectMeta)
+	}
+}
+
+func TestReconcile_RequeuesSoonWhenAPDBBlocksEvictions(t *testing.T) {
+	ctx := context.Background()
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "node-1",
+		Labels: map[string]string{iotv1alpha1.DefaultNodeLabelKey: iotv1alpha1.DefaultNodeLabelValue},
+	}}
+	policy := &iotv1alpha1.ReducedNodePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-a"},
+		Status: iotv1alpha1.ReducedNodePolicyStatus{Nodes: map[string]iotv1alpha1.NodeHeartbeatStatus{
+			"node-1": {State: "offline", OfflineSince: metav1.NewTime(time.Now().Add(-time.Hour))},
+		}},
+	}
+	attempts := 0
+	c := newTestClientWithInterceptor(interceptor.Funcs{
+		SubResourceCreate: func(context.Context, client.Client, string, client.Object, client.Object, ...client.SubResourceCreateOption) error {
+			attempts++
+			return apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+		},
+	}, node, policy, tierPod("web-abc", iotv1alpha1.DefaultPriorityTier, "web"))
+	r := &ReducedNodePolicyReconciler{
+		Client:             c,
+		Log:                logr.Discard(),
+		HeartbeatStore:     heartbeatstore.New(30 * time.Second),
+		DegradationManager: degradation.New(c, logr.Discard()),
+	}
+
+	start := time.Now()
+	res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "policy-a"}})
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	// El reconcile no espera a que el PDB deje margen
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("el reconcile tardó %s con una evicción bloqueada", elapsed)
+	}
+	if attempts != 1 || res.RequeueAfter != blockedEvictionRequeue {
+		t.Errorf("intentos = %d, requeueAfter = %s; se esperaba 1 y %s", attempts, res.RequeueAfter, blockedEvictionRequeue)
+	}
+
+	var got iotv1alpha1.ReducedNodePolicy
+	if err := c.Get(ctx, client.ObjectKey{Name: "policy-a"}, &got); err != nil {
+		t.Fatal(err)
+	}
+	status := got.Status.Nodes["node-1"]
+	if len(status.BlockedEvictions) != 1 || len(status.DegradedTiers) != 0 {
+		t.Errorf("status = %+v, se esperaba la evicción bloqueada y el nivel sin degradar", status)
+	}
+}
+
+func TestReconcile_OfflineKeepsResourceTierProgress(t *testing.T) {
+	ctx := context.Background()
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:   "node-1",
+		Labels: map[string]string{iotv1alpha1.DefaultNodeLabelKey: iotv1alpha1.DefaultNodeLabelValue},
+	}}
+	policy := &iotv1alpha1.ReducedNodePolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-a"},
+		Spec: iotv1alpha1.ReducedNodePolicySpec{
+			PriorityTiers: []iotv1alpha1.PriorityTier{
+				{Name: "best-effort", MaxCPUThreshold: 70},
+				{Name: "non-critical", MaxCPUThreshold: 90},
+			},
+		},
+	}
+	c := newTestClient(node, policy,
+		tierDeployment("batch", "best-effort"),
+		tierPod("batch-abc", "best-effort", "batch"),
+		tierDeployment("web", "non-critical"),
+		tierPod("web-abc", "non-critical", "web"),
+	)
+	store := heartbeatstore.New(30 * time.Second)
+	r := &ReducedNodePolicyReconciler{
+		Client:             c,
+		Log:                logr.Discard(),
+		HeartbeatStore:     store,
+		DegradationManager: degradation.New(c, logr.Discard()),
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "policy-a"}}
+	reconcile := func() iotv1alpha1.NodeHeartbeatStatus {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, req); err != nil {
+			t.Fatalf("reconcile: %v", err)
+		}
+		var got iotv1alpha1.ReducedNodePolicy
+		if err := c.Get(ctx, client.ObjectKey{Name: "policy-a"}, &got); err != nil {
+			t.Fatal(err)
+		}
+		return got.Status.Nodes["node-1"]
+	}
+	beat := func(cpu float64) {
+		store.Record(heartbeat.Payload{NodeName: "node-1", Timestamp: time.Now(),
+			Resources: &heartbeat.Resources{CPUPercent: cpu}})
+	}
+
+	// Online bajo presión: se degrada best-effort
+	beat(80)
+	if status := reconcile(); len(status.ResourceDegradedTiers) != 1 || replicas(t, c, "batch") != 0 {
+		t.Fatalf("status = %+v, se esperaba best-effort degradado", status)
+	}
+
+	// Offline: el progreso de los niveles se conserva
+	store.Disconnect("node-1")
+	status := reconcile()
+	if status.State != "offline" || len(status.ResourceDegradedTiers) != 1 || !status.ResourceDegradationExecuted {
+		t.Fatalf("status offline = %+v, se esperaba conservar best-effort degradado", status)
+	}
+
+	// De vuelta con más presión: se continúa por el siguiente nivel
+	beat(95)
+	status = reconcile()
+	want := []string{"best-effort", "non-critical"}
+	if status.State != "online" || strings.Join(status.ResourceDegradedTiers, ",") != strings.Join(want, ",") {
+		t.Errorf("niveles degradados = %v, se esperaba %v", status.ResourceDegradedTiers, want)
+	}
+	if replicas(t, c, "web") != 0 {
+		t.Error("al volver con presión debía degradarse non-critical")
+	}
+}
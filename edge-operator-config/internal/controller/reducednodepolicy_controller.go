@@ -9,27 +9,47 @@ import (
     "time"
 
     "github.com/go-logr/logr"
+    "github.com/prometheus/client_golang/prometheus"
     corev1 "k8s.io/api/core/v1"
+    apierrors "k8s.io/apimachinery/pkg/api/errors"
     metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
     "k8s.io/apimachinery/pkg/runtime"
+    "k8s.io/client-go/tools/record"
     ctrl "sigs.k8s.io/controller-runtime"
     "sigs.k8s.io/controller-runtime/pkg/client"
     controller "sigs.k8s.io/controller-runtime/pkg/controller"
-    appsv1 "k8s.io/api/apps/v1"
+    "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+    "sigs.k8s.io/controller-runtime/pkg/event"
+    "sigs.k8s.io/controller-runtime/pkg/handler"
+    "sigs.k8s.io/controller-runtime/pkg/reconcile"
+    "sigs.k8s.io/controller-runtime/pkg/source"
 
     iotv1alpha1 "github.com/jaiderssjgod/edge-operator/api/v1alpha1"
+    "github.com/jaiderssjgod/edge-operator/heartbeat"
     "github.com/jaiderssjgod/edge-operator/internal/degradation"
     "github.com/jaiderssjgod/edge-operator/internal/heartbeatstore"
+    "github.com/jaiderssjgod/edge-operator/internal/metrics"
 )
 
 const (
-    heartbeatTimeout        = 30 * time.Second
     requeueInterval         = 15 * time.Second
     defaultGracePeriodSecs  = 60
+    // blockedEvictionRequeue es la espera para reintentar las evicciones
+    // bloqueadas por un PodDisruptionBudget. El reintento se hace en un
+    // reconcile posterior para no retener el worker mientras tanto.
+    blockedEvictionRequeue  = 5 * time.Second
 )
 
-// gracePeriod lee GRACE_PERIOD_SECONDS del entorno; si no existe usa el default.
-func gracePeriod() time.Duration {
+// restoreFinalizer retiene el borrado de una policy hasta restaurar las
+// cargas que degradó: sin la policy ningún reconcile las restauraría.
+const restoreFinalizer = "iot.mydomain.com/restore-workloads"
+
+// gracePeriod devuelve el grace period de la policy. Si la policy no lo
+// define lee GRACE_PERIOD_SECONDS del entorno; si no existe usa el default.
+func gracePeriod(policy *iotv1alpha1.ReducedNodePolicy) time.Duration {
+    if policy.Spec.GracePeriodSeconds > 0 {
+        return time.Duration(policy.Spec.GracePeriodSeconds) * time.Second
+    }
     if raw := os.Getenv("GRACE_PERIOD_SECONDS"); raw != "" {
         if secs, err := strconv.Atoi(raw); err == nil && secs > 0 {
             return time.Duration(secs) * time.Second
@@ -38,6 +58,16 @@ func gracePeriod() time.Duration {
     return defaultGracePeriodSecs * time.Second
 }
 
+// heartbeatThresholds traduce la configuración de heartbeats de la policy a
+// los umbrales con los que el store evalúa si un nodo está offline.
+func heartbeatThresholds(policy *iotv1alpha1.ReducedNodePolicy) heartbeatstore.Thresholds {
+    return heartbeatstore.Thresholds{
+        Timeout:          time.Duration(policy.Spec.HeartbeatTimeoutSeconds) * time.Second,
+        Interval:         time.Duration(policy.Spec.HeartbeatIntervalSeconds) * time.Second,
+        MissedHeartbeats: policy.Spec.MissedHeartbeatsThreshold,
+    }
+}
+
 // ReducedNodePolicyReconciler reconcilia objetos ReducedNodePolicy.
 type ReducedNodePolicyReconciler struct {
     client.Client
@@ -45,6 +75,13 @@ type ReducedNodePolicyReconciler struct {
     Scheme             *runtime.Scheme
     HeartbeatStore     *heartbeatstore.Store
     DegradationManager *degradation.Manager
+    // Recorder registra Events sobre Nodes, policies y cargas afectadas.
+    // Puede ser nil, p. ej. en tests.
+    Recorder           record.EventRecorder
+    // NodeEvents, si no es nil, notifica nodos cuyo estado cambió fuera de
+    // los heartbeats, p. ej. al romperse su stream, para reconciliar sus
+    // policies sin esperar al requeue.
+    NodeEvents <-chan event.GenericEvent
 }
 
 func (r *ReducedNodePolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -52,11 +89,47 @@ func (r *ReducedNodePolicyReconciler) Reconcile(ctx context.Context, req ctrl.Re
 
     var policy iotv1alpha1.ReducedNodePolicy
     if err := r.Get(ctx, req.NamespacedName, &policy); err != nil {
-        return ctrl.Result{}, client.IgnoreNotFound(err)
+        if apierrors.IsNotFound(err) {
+            // Policy borrada sin finalizer, p. ej. creada antes de que
+            // existiera: restaurar lo que quede y olvidarla
+            return ctrl.Result{}, r.forgetPolicy(ctx, log, req.Name)
+        }
+        return ctrl.Result{}, err
+    }
+
+    if !policy.DeletionTimestamp.IsZero() {
+        if !controllerutil.ContainsFinalizer(&policy, restoreFinalizer) {
+            return ctrl.Result{}, nil
+        }
+        if err := r.forgetPolicy(ctx, log, policy.Name); err != nil {
+            return ctrl.Result{}, err
+        }
+        controllerutil.RemoveFinalizer(&policy, restoreFinalizer)
+        return ctrl.Result{}, r.Update(ctx, &policy)
+    }
+    if controllerutil.AddFinalizer(&policy, restoreFinalizer) {
+        if err := r.Update(ctx, &policy); err != nil {
+            return ctrl.Result{}, err
+        }
+    }
+
+    policy.Spec.Default()
+    if err := policy.Spec.Validate(); err != nil {
+        // Una spec inválida no se corrige reintentando: esperar a que la editen
+        log.Error(err, "ReducedNodePolicy inválida, omitiendo reconcile")
+        if r.Recorder != nil {
+            r.Recorder.Eventf(&policy, corev1.EventTypeWarning, ReasonInvalidPolicySpec, "Spec inválida: %v", err)
+        }
+        setConditions(&policy, reconcileSummary{err: err, errReason: iotv1alpha1.ReasonInvalidSpec})
+        return ctrl.Result{}, r.updateStatus(ctx, log, &policy)
     }
 
     var nodeList corev1.NodeList
     if err := r.List(ctx, &nodeList, client.MatchingLabels(policy.Spec.NodeSelector)); err != nil {
+        setConditions(&policy, reconcileSummary{err: err, errReason: iotv1alpha1.ReasonNodeListFailed})
+        if statusErr := r.updateStatus(ctx, log, &policy); statusErr != nil {
+            log.Error(statusErr, "No se pudieron publicar las condiciones del error")
+        }
         return ctrl.Result{}, err
     }
 
@@ -64,24 +137,44 @@ func (r *ReducedNodePolicyReconciler) Reconcile(ctx context.Context, req ctrl.Re
         policy.Status.Nodes = make(map[string]iotv1alpha1.NodeHeartbeatStatus)
     }
 
-    offlineCount := 0
-    gp := gracePeriod()
+    var summary reconcileSummary
+    gp := gracePeriod(&policy)
+
+    // Los nodos que ya no coinciden con el selector dejan de exportarse y de
+    // recibir la configuración de la policy
+    selected := make(map[string]bool, len(nodeList.Items))
+    for _, node := range nodeList.Items {
+        selected[node.Name] = true
+    }
+    for name := range policy.Status.Nodes {
+        if !selected[name] {
+            metrics.NodeOffline.DeleteLabelValues(policy.Name, name)
+            r.HeartbeatStore.ForgetAgentConfig(policy.Name, name)
+        }
+    }
 
     for _, node := range nodeList.Items {
         log.Info("Procesando nodo", "name", node.Name)
 
-        if err := r.ensureNodeLabeled(ctx, log, &node); err != nil {
+        if err := r.ensureNodeLabeled(ctx, log, &policy, &node); err != nil {
+            summary.fail(iotv1alpha1.ReasonNodeLabelFailed, fmt.Errorf("etiquetando nodo %s: %w", node.Name, err))
             continue
         }
 
-        nodeState := r.HeartbeatStore.GetNodeState(node.Name)
+        // El agente del nodo recibe esta configuración en su próximo heartbeat
+        r.HeartbeatStore.SetAgentConfig(node.Name, agentConfig(&policy))
+
+        nodeState := r.HeartbeatStore.EvaluateNodeState(node.Name, heartbeatThresholds(&policy))
         existing := policy.Status.Nodes[node.Name]
 
         var hbStatus iotv1alpha1.NodeHeartbeatStatus
 
         if nodeState.Offline {
-            offlineCount++
-            hbStatus = r.handleOfflineNode(ctx, log, existing, node.Name, nodeState, gp)
+            summary.offlineNodes = append(summary.offlineNodes, node.Name)
+            hbStatus = r.handleOfflineNode(ctx, log, &policy, existing, &node, nodeState, gp)
+            if len(hbStatus.BlockedEvictions) > 0 {
+                summary.blockedNodes = append(summary.blockedNodes, node.Name)
+            }
         } else {
             // Nodo online: limpiar estado offline previo
             hbStatus = iotv1alpha1.NodeHeartbeatStatus{
@@ -93,10 +186,11 @@ func (r *ReducedNodePolicyReconciler) Reconcile(ctx context.Context, req ctrl.Re
                 hbStatus.LastHeartbeat = metav1.NewTime(nodeState.LastHeartbeat)
             }
             hbStatus.OfflineEvents = existing.OfflineEvents
+            hbStatus.ResourceDegradedTiers = existing.ResourceDegradedTiers
             // Preservar el flag de degradación por recursos del ciclo anterior
 // Usar estado real de deployments como fuente de verdad
-            hbStatus.ResourceDegradationExecuted = existing.ResourceDegradationExecuted || 
-                r.hasScaledDownDeployments(ctx)
+            hbStatus.ResourceDegradationExecuted = existing.ResourceDegradationExecuted ||
+                r.hasDegradedWorkloads(ctx, policy.Name, node.Name)
 
             if existing.State == "offline" {
                 log.Info("Nodo recuperado antes de que expirara el grace period",
@@ -104,40 +198,84 @@ func (r *ReducedNodePolicyReconciler) Reconcile(ctx context.Context, req ctrl.Re
                 log.Info("Node reconnected after offline period",
                     "node", node.Name,
                     "offlineDuration", time.Since(existing.OfflineSince.Time))
+                r.nodeEvent(&policy, &node, corev1.EventTypeNormal, ReasonNodeRecovered,
+                    "Heartbeats recibidos de nuevo tras %s offline",
+                    time.Since(existing.OfflineSince.Time).Round(time.Second))
             }
 
             // Evaluar umbrales de recursos
-            r.checkResourceThresholds(ctx, log, &policy, node.Name, &hbStatus)
+            if r.checkResourceThresholds(ctx, log, &policy, &node, nodeState, &hbStatus) {
+                summary.exceededNodes = append(summary.exceededNodes, node.Name)
+            }
             // OfflineSince y DegradationExecuted quedan en zero value → reset implícito
         }
 
+        r.recordReplays(&policy, &node, existing, &hbStatus)
         policy.Status.Nodes[node.Name] = hbStatus
+        offline := 0.0
+        if nodeState.Offline {
+            offline = 1
+        }
+        metrics.NodeOffline.WithLabelValues(policy.Name, node.Name).Set(offline)
         r.checkCriticalPods(ctx, log, &policy, node.Name)
     }
 
     policy.Status.ObservedNodes = len(nodeList.Items)
-    policy.Status.OfflineNodes = offlineCount
+    policy.Status.OfflineNodes = len(summary.offlineNodes)
     policy.Status.LastSync = metav1.NewTime(time.Now())
+    setConditions(&policy, summary)
 
-    if err := r.Status().Update(ctx, &policy); err != nil {
-        log.Error(err, "Unable to update ReducedNodePolicy status")
+    if err := r.updateStatus(ctx, log, &policy); err != nil {
         return ctrl.Result{}, err
     }
 
+    if len(summary.blockedNodes) > 0 {
+        log.Info("Evicciones bloqueadas por PodDisruptionBudget, reintentando pronto",
+            "nodes", summary.blockedNodes, "requeueAfter", blockedEvictionRequeue)
+        return ctrl.Result{RequeueAfter: blockedEvictionRequeue}, nil
+    }
     return ctrl.Result{RequeueAfter: requeueInterval}, nil
 }
 
+// forgetPolicy restaura todas las cargas que degradó la policy policyName,
+// en cualquier nodo, y deja de exportar el estado de sus nodos y de
+// configurar sus agentes. Devuelve error si alguna carga sigue degradada.
+func (r *ReducedNodePolicyReconciler) forgetPolicy(ctx context.Context, log logr.Logger, policyName string) error {
+    if err := r.DegradationManager.RestoreWorkloads(ctx, degradation.Scope{Policy: policyName}); err != nil {
+        log.Error(err, "No se pudieron restaurar las cargas de la policy borrada")
+        return err
+    }
+    metrics.NodeOffline.DeletePartialMatch(prometheus.Labels{"policy": policyName})
+    r.HeartbeatStore.ForgetAgentConfig(policyName, "")
+    return nil
+}
+
+// updateStatus persiste el status de la policy.
+func (r *ReducedNodePolicyReconciler) updateStatus(
+    ctx context.Context, log logr.Logger, policy *iotv1alpha1.ReducedNodePolicy,
+) error {
+    if err := r.Status().Update(ctx, policy); err != nil {
+        log.Error(err, "Unable to update ReducedNodePolicy status")
+        return err
+    }
+    return nil
+}
+
 // handleOfflineNode gestiona la lógica de grace period para un nodo offline.
+// Desaloja los niveles de prioridad de menor a mayor, como máximo uno por
+// reconcile y solo cuando el nodo lleva offline el retardo de ese nivel.
 // Retorna el NodeHeartbeatStatus actualizado.
 func (r *ReducedNodePolicyReconciler) handleOfflineNode(
     ctx context.Context,
     log logr.Logger,
+    policy *iotv1alpha1.ReducedNodePolicy,
     existing iotv1alpha1.NodeHeartbeatStatus,
-    nodeName string,
+    node *corev1.Node,
     nodeState heartbeatstore.NodeState, // ajusta al tipo real de tu store
     gp time.Duration,
 ) iotv1alpha1.NodeHeartbeatStatus {
 
+    nodeName := node.Name
     now := time.Now()
 
     // Determinar offlineSince: conservar el existente o fijar ahora
@@ -150,6 +288,8 @@ func (r *ReducedNodePolicyReconciler) handleOfflineNode(
             "offlineSince", offlineSince,
             "gracePeriod", gp,
         )
+        r.nodeEvent(policy, node, corev1.EventTypeWarning, ReasonNodeOffline,
+            "Nodo offline (último heartbeat: %s), grace period de %s iniciado", lastSeen(nodeState), gp)
     }
 
     hbStatus := iotv1alpha1.NodeHeartbeatStatus{
@@ -158,62 +298,167 @@ func (r *ReducedNodePolicyReconciler) handleOfflineNode(
         Memory:              nodeState.Memory,
         OfflineSince:        offlineSince,
         DegradationExecuted: existing.DegradationExecuted,
+        DegradedTiers:       existing.DegradedTiers,
+        OfflineEvents:       existing.OfflineEvents,
+        // La degradación por recursos sigue vigente mientras el nodo está
+        // offline: al volver se continúa desde el mismo nivel
+        ResourceDegradedTiers:       existing.ResourceDegradedTiers,
+        ResourceDegradationExecuted: existing.ResourceDegradationExecuted,
     }
     if !nodeState.LastHeartbeat.IsZero() {
         hbStatus.LastHeartbeat = metav1.NewTime(nodeState.LastHeartbeat)
     }
 
-    // RF-05: solo degradar si el grace period ya expiró y no se ha degradado antes
+    // RF-05: solo degradar si el retardo del siguiente nivel ya expiró y
+    // quedan niveles por degradar
+    tiers := priorityTiers(policy)
     offlineDuration := now.Sub(offlineSince.Time)
-    switch {
-    case existing.DegradationExecuted:
+    if existing.DegradationExecuted || len(existing.DegradedTiers) >= len(tiers) {
         log.Info("Degradación ya ejecutada para este evento offline, omitiendo",
             "node", nodeName)
-        hbStatus.OfflineEvents = existing.OfflineEvents
+        return hbStatus
+    }
 
-    case offlineDuration >= gp:
-        log.Info("Grace period expirado, ejecutando degradación",
+    tier := tiers[len(existing.DegradedTiers)]
+    delay := gp
+    if tier.OfflineDelaySeconds > 0 {
+        delay = time.Duration(tier.OfflineDelaySeconds) * time.Second
+    }
+    if offlineDuration < delay {
+        log.Info("Nodo offline dentro del grace period, esperando",
             "node", nodeName,
+            "tier", tier.Name,
             "offlineDuration", offlineDuration,
-            "gracePeriod", gp,
+            "remaining", delay-offlineDuration,
         )
-        if err := r.DegradationManager.EvictNonCriticalPods(ctx, nodeName); err != nil {
-            log.Error(err, "Error durante la degradación del nodo", "node", nodeName)
-            // No marcamos DegradationExecuted para poder reintentar
-        } else {
-            hbStatus.DegradationExecuted = true
-                // RF-06: registrar el evento offline con su duración
-            offlineEvent := fmt.Sprintf("offline from %s, degraded at %s (duration: %s)",
-                offlineSince.Time.Format(time.RFC3339),
-                time.Now().UTC().Format(time.RFC3339),
-                offlineDuration.Round(time.Second).String(),
-            )
-            hbStatus.OfflineEvents = append(existing.OfflineEvents, offlineEvent)
-        }
+        return hbStatus
+    }
 
-    default:
-        remaining := gp - offlineDuration
-        log.Info("Nodo offline dentro del grace period, esperando",
+    log.Info("Grace period expirado, ejecutando degradación",
+        "node", nodeName,
+        "tier", tier.Name,
+        "offlineDuration", offlineDuration,
+        "gracePeriod", delay,
+    )
+    r.nodeEvent(policy, node, corev1.EventTypeWarning, ReasonGracePeriodExpired,
+        "Offline durante %s, desalojando el nivel %s", offlineDuration.Round(time.Second), tier.Name)
+    result, err := r.degradationFor(policy).EvictPods(ctx, nodeName, tier.Name)
+    hbStatus.BlockedEvictions = result.Blocked
+    switch {
+    case err != nil:
+        log.Error(err, "Error durante la degradación del nodo", "node", nodeName)
+        r.nodeEvent(policy, node, corev1.EventTypeWarning, ReasonDegradationFailed,
+            "Error desalojando el nivel %s: %v", tier.Name, err)
+        // No avanzamos de nivel para poder reintentar
+    case len(result.Blocked) > 0:
+        // Degradación parcial: los PDB bloquearon algunos pods, se reintenta
+        // el mismo nivel tras blockedEvictionRequeue
+        log.Info("Degradación parcial, evicciones bloqueadas por PodDisruptionBudget",
             "node", nodeName,
-            "offlineDuration", offlineDuration,
-            "remaining", remaining,
+            "tier", tier.Name,
+            "evicted", result.Evicted,
+            "blocked", len(result.Blocked),
+        )
+        r.nodeEvent(policy, node, corev1.EventTypeWarning, ReasonDegradationBlocked,
+            "Nivel %s: %d pods desalojados, %d bloqueados por PodDisruptionBudget",
+            tier.Name, result.Evicted, len(result.Blocked))
+    default:
+        hbStatus.DegradedTiers = append(append([]string{}, existing.DegradedTiers...), tier.Name)
+        hbStatus.DegradationExecuted = len(hbStatus.DegradedTiers) == len(tiers)
+        // RF-06: registrar el evento offline con su duración
+        offlineEvent := fmt.Sprintf("offline from %s, tier %s degraded at %s (duration: %s)",
+            offlineSince.Time.Format(time.RFC3339),
+            tier.Name,
+            time.Now().UTC().Format(time.RFC3339),
+            offlineDuration.Round(time.Second).String(),
         )
+        hbStatus.OfflineEvents = append(existing.OfflineEvents, offlineEvent)
+        r.nodeEvent(policy, node, corev1.EventTypeNormal, ReasonTierDegraded,
+            "Nivel %s desalojado (%d pods)", tier.Name, result.Evicted)
     }
 
     return hbStatus
 }
 
-// ensureNodeLabeled garantiza que el nodo tenga la etiqueta "node-type=reducido".
+// recordReplays añade a OfflineEvents las ventanas de heartbeats que el
+// agente reprodujo al recuperar la conexión y que esta policy aún no ha
+// registrado, con la duración real del corte y los picos de recursos.
+func (r *ReducedNodePolicyReconciler) recordReplays(
+    policy *iotv1alpha1.ReducedNodePolicy,
+    node *corev1.Node,
+    existing iotv1alpha1.NodeHeartbeatStatus,
+    hbStatus *iotv1alpha1.NodeHeartbeatStatus,
+) {
+    hbStatus.LastReplayAt = existing.LastReplayAt
+    for _, w := range r.HeartbeatStore.ReplaysSince(node.Name, existing.LastReplayAt.Time) {
+        duration := w.To.Sub(w.From).Round(time.Second)
+        event := fmt.Sprintf("unreachable from %s to %s, agent replayed %d samples (duration: %s, peak cpu: %s, peak memory: %s)",
+            w.From.UTC().Format(time.RFC3339),
+            w.To.UTC().Format(time.RFC3339),
+            w.Samples,
+            duration.String(),
+            heartbeat.FormatPercent(w.PeakCPU),
+            heartbeat.FormatPercent(w.PeakMemory),
+        )
+        hbStatus.OfflineEvents = append(hbStatus.OfflineEvents, event)
+        hbStatus.LastReplayAt = metav1.NewMicroTime(w.ReceivedAt)
+        r.nodeEvent(policy, node, corev1.EventTypeNormal, ReasonHeartbeatsReplayed,
+            "El agente reprodujo %d heartbeats de un corte de %s (pico cpu: %s, pico memoria: %s)",
+            w.Samples, duration, heartbeat.FormatPercent(w.PeakCPU), heartbeat.FormatPercent(w.PeakMemory))
+    }
+}
+
+// lastSeen describe el último heartbeat de un nodo para los Events.
+func lastSeen(state heartbeatstore.NodeState) string {
+    if state.LastHeartbeat.IsZero() {
+        return "nunca"
+    }
+    return state.LastHeartbeat.UTC().Format(time.RFC3339)
+}
+
+// priorityTiers devuelve los niveles de prioridad de la policy, de menor a
+// mayor prioridad. Sin PriorityTiers se usa un único nivel non-critical con
+// los umbrales globales de la policy.
+func priorityTiers(policy *iotv1alpha1.ReducedNodePolicy) []iotv1alpha1.PriorityTier {
+    if len(policy.Spec.PriorityTiers) > 0 {
+        return policy.Spec.PriorityTiers
+    }
+    return []iotv1alpha1.PriorityTier{{
+        Name:                  iotv1alpha1.DefaultPriorityTier,
+        MaxCPUThreshold:       policy.Spec.MaxCPUThreshold,
+        MaxMemoryThreshold:    policy.Spec.MaxMemoryThreshold,
+        MaxDiskThreshold:      policy.Spec.MaxDiskThreshold,
+        MaxCPUPressure:        policy.Spec.MaxCPUPressure,
+        MaxMemoryPressure:     policy.Spec.MaxMemoryPressure,
+        MaxIOPressure:         policy.Spec.MaxIOPressure,
+        MaxTemperatureCelsius: policy.Spec.MaxTemperatureCelsius,
+        MinBatteryPercent:     policy.Spec.MinBatteryPercent,
+        DegradeOnBattery:      policy.Spec.DegradeOnBattery,
+    }}
+}
+
+// degradationFor devuelve el Manager de degradación configurado con las
+// convenciones de etiquetado de la policy.
+func (r *ReducedNodePolicyReconciler) degradationFor(policy *iotv1alpha1.ReducedNodePolicy) *degradation.Manager {
+    return r.DegradationManager.WithLabels(degradation.Labels{
+        PriorityKey: policy.Spec.PriorityLabelKey,
+        WorkloadKey: policy.Spec.WorkloadLabelKey,
+    })
+}
+
+// ensureNodeLabeled garantiza que el nodo tenga la etiqueta NodeLabelKey=NodeLabelValue
+// de la policy ("node-type=reducido" por defecto).
 func (r *ReducedNodePolicyReconciler) ensureNodeLabeled(
-    ctx context.Context, log logr.Logger, node *corev1.Node,
+    ctx context.Context, log logr.Logger, policy *iotv1alpha1.ReducedNodePolicy, node *corev1.Node,
 ) error {
-    if node.Labels["node-type"] == "reducido" {
+    key, value := policy.Spec.NodeLabelKey, policy.Spec.NodeLabelValue
+    if v, ok := node.Labels[key]; ok && v == value {
         return nil
     }
     if node.Labels == nil {
         node.Labels = map[string]string{}
     }
-    node.Labels["node-type"] = "reducido"
+    node.Labels[key] = value
     if err := r.Update(ctx, node); err != nil {
         log.Error(err, "No se pudo etiquetar el nodo", "node", node.Name)
         return err
@@ -239,95 +484,112 @@ func (r *ReducedNodePolicyReconciler) checkCriticalPods(
     log.Info("Nodo sin pods críticos activos", "node", nodeName)
 }
 
-// parsePercent convierte "85.41%" → 85.41
-func parsePercent(s string) float64 {
-    s = strings.TrimSuffix(s, "%")
-    v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
-    if err != nil {
-        return 0
-    }
-    return v
-}
-
-// checkResourceThresholds evalúa si CPU o memoria superan los umbrales
-// definidos en la policy y ejecuta degradación si corresponde.
+// checkResourceThresholds evalúa si CPU o memoria superan los umbrales de
+// cada nivel de prioridad y ejecuta degradación si corresponde. Se escala a 0
+// un nivel por reconcile, empezando por el de menor prioridad, y solo se
+// avanza al siguiente si la presión persiste y supera sus propios umbrales.
+// Retorna true si el nodo supera el umbral de algún nivel.
 func (r *ReducedNodePolicyReconciler) checkResourceThresholds(
     ctx context.Context,
     log logr.Logger,
     policy *iotv1alpha1.ReducedNodePolicy,
-    nodeName string,
+    node *corev1.Node,
+    nodeState heartbeatstore.NodeState,
     hbStatus *iotv1alpha1.NodeHeartbeatStatus,
-) {
-    if policy.Spec.MaxCPUThreshold == 0 && policy.Spec.MaxMemoryThreshold == 0 {
-        return
+) bool {
+    nodeName := node.Name
+    // Solo los niveles con algún umbral participan en la degradación por recursos
+    var tiers []iotv1alpha1.PriorityTier
+    for _, tier := range priorityTiers(policy) {
+        if hasResourceThresholds(tier) {
+            tiers = append(tiers, tier)
+        }
+    }
+    if len(tiers) == 0 {
+        return false
     }
 
-    cpu := parsePercent(hbStatus.CPU)
-    mem := parsePercent(hbStatus.Memory)
-
-    cpuExceeded := policy.Spec.MaxCPUThreshold > 0 && cpu >= float64(policy.Spec.MaxCPUThreshold)
-    memExceeded := policy.Spec.MaxMemoryThreshold > 0 && mem >= float64(policy.Spec.MaxMemoryThreshold)
+    if nodeState.Resources == nil {
+        // Sin telemetría no hay nada que evaluar: no degradar ni restaurar
+        log.Info("Heartbeat sin telemetría de recursos, omitiendo umbrales", "node", nodeName)
+        return false
+    }
+    underPressure := false
+    for _, tier := range tiers {
+        if len(exceededSignals(tier, nodeState.Resources)) > 0 {
+            underPressure = true
+            break
+        }
+    }
 
-    if !cpuExceeded && !memExceeded {
+    scope := degradation.Scope{Policy: policy.Name, Node: nodeName}
+    if !underPressure {
         // Recursos normalizados → restaurar deployments si estaban escalados a 0
-        if hbStatus.ResourceDegradationExecuted || r.hasScaledDownDeployments(ctx) {
-                log.Info("Recursos normalizados, restaurando deployments", "node", nodeName)
-                if err := r.DegradationManager.ScaleUpNonCriticalDeployments(
-                    ctx, nodeName, policy.Spec.CriticalLabelKey,
-                ); err != nil {
-                    log.Error(err, "Error restaurando deployments", "node", nodeName)
-                }
+        if hbStatus.ResourceDegradationExecuted || r.hasDegradedWorkloads(ctx, policy.Name, nodeName) {
+            log.Info("Recursos normalizados, restaurando cargas degradadas", "node", nodeName)
+            if err := r.degradationFor(policy).RestoreWorkloads(ctx, scope); err != nil {
+                log.Error(err, "Error restaurando cargas degradadas", "node", nodeName)
+            } else {
+                r.nodeEvent(policy, node, corev1.EventTypeNormal, ReasonWorkloadsRestored,
+                    "Recursos normalizados (cpu: %s, memory: %s), cargas restauradas", hbStatus.CPU, hbStatus.Memory)
             }
+        }
         hbStatus.ResourceDegradationExecuted = false
-        return
+        hbStatus.ResourceDegradedTiers = nil
+        return false
     }
 
-    if hbStatus.ResourceDegradationExecuted {
+    next := len(hbStatus.ResourceDegradedTiers)
+    if next >= len(tiers) {
         log.Info("Degradación por recursos ya ejecutada, omitiendo",
             "node", nodeName, "cpu", hbStatus.CPU, "memory", hbStatus.Memory)
-        return
+        return true
     }
 
-    if cpuExceeded {
-        log.Info("Umbral de CPU superado, escalando deployments a 0",
-            "node", nodeName, "cpu", hbStatus.CPU, "threshold", policy.Spec.MaxCPUThreshold)
-    }
-    if memExceeded {
-        log.Info("Umbral de memoria superado, escalando deployments a 0",
-            "node", nodeName, "memory", hbStatus.Memory, "threshold", policy.Spec.MaxMemoryThreshold)
+    tier := tiers[next]
+    signals := exceededSignals(tier, nodeState.Resources)
+    if len(signals) == 0 {
+        log.Info("Presión de recursos persistente por debajo de los umbrales del siguiente nivel",
+            "node", nodeName, "tier", tier.Name, "cpu", hbStatus.CPU, "memory", hbStatus.Memory)
+        return true
     }
 
-    // ← CAMBIO: ScaleDown en lugar de EvictNonCriticalPods
-    if err := r.DegradationManager.ScaleDownNonCriticalDeployments(
-        ctx, nodeName, policy.Spec.CriticalLabelKey,
-    ); err != nil {
-        log.Error(err, "Error en degradación por recursos", "node", nodeName)
-        return
+    log.Info("Umbrales superados, degradando cargas",
+        "node", nodeName, "tier", tier.Name, "signals", signals)
+
+    r.nodeEvent(policy, node, corev1.EventTypeWarning, ReasonThresholdExceeded,
+        "Umbral del nivel %s superado (%s), degradando cargas", tier.Name, strings.Join(signals, ", "))
+    if err := r.degradationFor(policy).DegradeWorkloads(ctx, scope, tier.Name); err != nil {
+        log.Error(err, "Error en degradación por recursos", "node", nodeName, "tier", tier.Name)
+        r.nodeEvent(policy, node, corev1.EventTypeWarning, ReasonDegradationFailed,
+            "Error degradando el nivel %s: %v", tier.Name, err)
+        return true
     }
 
     hbStatus.ResourceDegradationExecuted = true
-    event := fmt.Sprintf("resource degradation at %s (cpu: %s, memory: %s)",
+    hbStatus.ResourceDegradedTiers = append(hbStatus.ResourceDegradedTiers, tier.Name)
+    event := fmt.Sprintf("resource degradation of tier %s at %s (cpu: %s, memory: %s)",
+        tier.Name,
         time.Now().UTC().Format(time.RFC3339),
         hbStatus.CPU,
         hbStatus.Memory,
     )
     hbStatus.OfflineEvents = append(hbStatus.OfflineEvents, event)
-    log.Info("Degradación por recursos completada", "node", nodeName)
+    log.Info("Degradación por recursos completada", "node", nodeName, "tier", tier.Name)
+    r.nodeEvent(policy, node, corev1.EventTypeNormal, ReasonTierDegraded,
+        "Nivel %s degradado por presión de recursos", tier.Name)
+    return true
 }
 
-func (r *ReducedNodePolicyReconciler) hasScaledDownDeployments(ctx context.Context) bool {
-    var deployList appsv1.DeploymentList
-    if err := r.Client.List(ctx, &deployList,
-        client.MatchingLabels{"edge.priority": "non-critical"},
-    ); err != nil {
+// hasDegradedWorkloads indica si quedan cargas degradadas por el operador a
+// causa de nodeName bajo la policy indicada, pendientes de restaurar.
+func (r *ReducedNodePolicyReconciler) hasDegradedWorkloads(ctx context.Context, policyName, nodeName string) bool {
+    scaled, err := r.DegradationManager.HasDegradedWorkloads(ctx,
+        degradation.Scope{Policy: policyName, Node: nodeName})
+    if err != nil {
         return false
     }
-    for _, deploy := range deployList.Items {
-        if deploy.Spec.Replicas != nil && *deploy.Spec.Replicas == 0 {
-            return true
-        }
-    }
-    return false
+    return scaled
 }
 
 func (r *ReducedNodePolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
@@ -346,8 +608,26 @@ func (r *ReducedNodePolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
         return err
     }
 
-    return ctrl.NewControllerManagedBy(mgr).
+    b := ctrl.NewControllerManagedBy(mgr).
         For(&iotv1alpha1.ReducedNodePolicy{}).
-        WithOptions(controller.Options{MaxConcurrentReconciles: 1}).
-        Complete(r)
+        WithOptions(controller.Options{MaxConcurrentReconciles: 1})
+    if r.NodeEvents != nil {
+        b = b.WatchesRawSource(source.Channel(r.NodeEvents, handler.EnqueueRequestsFromMapFunc(r.policiesForNode)))
+    }
+    return b.Complete(r)
+}
+
+// policiesForNode encola todas las policies: cada una decide en Reconcile
+// si selecciona el nodo, y suele haber pocas.
+func (r *ReducedNodePolicyReconciler) policiesForNode(ctx context.Context, _ client.Object) []reconcile.Request {
+    var policies iotv1alpha1.ReducedNodePolicyList
+    if err := r.List(ctx, &policies); err != nil {
+        r.Log.Error(err, "No se pudieron listar las policies para un evento de nodo")
+        return nil
+    }
+    reqs := make([]reconcile.Request, 0, len(policies.Items))
+    for _, p := range policies.Items {
+        reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&p)})
+    }
+    return reqs
 }
\ No newline at end of file
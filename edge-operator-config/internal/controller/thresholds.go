@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"fmt"
+
+	iotv1alpha1 "github.com/jaiderssjgod/edge-operator/api/v1alpha1"
+	"github.com/jaiderssjgod/edge-operator/heartbeat"
+)
+
+// resourceSignal es una señal de la telemetría del nodo comparable con un
+// umbral de PriorityTier.
+type resourceSignal struct {
+	// enabled indica si el nivel define un umbral para la señal.
+	enabled func(iotv1alpha1.PriorityTier) bool
+	// exceeded devuelve la descripción de la señal si supera el umbral del
+	// nivel. Las señales que el agente no reporta nunca se superan.
+	exceeded func(iotv1alpha1.PriorityTier, *heartbeat.Resources) (string, bool)
+}
+
+// above construye una señal que se supera cuando value >= umbral.
+func above(name, unit string, threshold func(iotv1alpha1.PriorityTier) int,
+	value func(*heartbeat.Resources) (float64, bool)) resourceSignal {
+	return resourceSignal{
+		enabled: func(t iotv1alpha1.PriorityTier) bool { return threshold(t) > 0 },
+		exceeded: func(t iotv1alpha1.PriorityTier, r *heartbeat.Resources) (string, bool) {
+			limit := threshold(t)
+			v, ok := value(r)
+			if limit <= 0 || !ok || v < float64(limit) {
+				return "", false
+			}
+			return fmt.Sprintf("%s %.2f%s >= %d%s", name, v, unit, limit, unit), true
+		},
+	}
+}
+
+// below construye una señal que se supera cuando value < umbral.
+func below(name, unit string, threshold func(iotv1alpha1.PriorityTier) int,
+	value func(*heartbeat.Resources) (float64, bool)) resourceSignal {
+	return resourceSignal{
+		enabled: func(t iotv1alpha1.PriorityTier) bool { return threshold(t) > 0 },
+		exceeded: func(t iotv1alpha1.PriorityTier, r *heartbeat.Resources) (string, bool) {
+			limit := threshold(t)
+			v, ok := value(r)
+			if limit <= 0 || !ok || v >= float64(limit) {
+				return "", false
+			}
+			return fmt.Sprintf("%s %.2f%s < %d%s", name, v, unit, limit, unit), true
+		},
+	}
+}
+
+func pressure(stat func(*heartbeat.Pressure) heartbeat.PressureStat) func(*heartbeat.Resources) (float64, bool) {
+	return func(r *heartbeat.Resources) (float64, bool) {
+		if r.Pressure == nil {
+			return 0, false
+		}
+		return stat(r.Pressure).SomeAvg10, true
+	}
+}
+
+var resourceSignals = []resourceSignal{
+	above("cpu", "%",
+		func(t iotv1alpha1.PriorityTier) int { return t.MaxCPUThreshold },
+		func(r *heartbeat.Resources) (float64, bool) { return r.CPUPercent, true }),
+	above("memory", "%",
+		func(t iotv1alpha1.PriorityTier) int { return t.MaxMemoryThreshold },
+		func(r *heartbeat.Resources) (float64, bool) { return r.MemoryPercent, true }),
+	above("disk", "%",
+		func(t iotv1alpha1.PriorityTier) int { return t.MaxDiskThreshold },
+		func(r *heartbeat.Resources) (float64, bool) { return r.MaxDiskPercent(), len(r.Disks) > 0 }),
+	above("cpu pressure", "%",
+		func(t iotv1alpha1.PriorityTier) int { return t.MaxCPUPressure },
+		pressure(func(p *heartbeat.Pressure) heartbeat.PressureStat { return p.CPU })),
+	above("memory pressure", "%",
+		func(t iotv1alpha1.PriorityTier) int { return t.MaxMemoryPressure },
+		pressure(func(p *heartbeat.Pressure) heartbeat.PressureStat { return p.Memory })),
+	above("io pressure", "%",
+		func(t iotv1alpha1.PriorityTier) int { return t.MaxIOPressure },
+		pressure(func(p *heartbeat.Pressure) heartbeat.PressureStat { return p.IO })),
+	above("temperature", "°C",
+		func(t iotv1alpha1.PriorityTier) int { return t.MaxTemperatureCelsius },
+		func(r *heartbeat.Resources) (float64, bool) { return r.MaxCelsius(), len(r.Temperatures) > 0 }),
+	below("battery", "%",
+		func(t iotv1alpha1.PriorityTier) int { return t.MinBatteryPercent },
+		func(r *heartbeat.Resources) (float64, bool) {
+			if r.Power == nil {
+				return 0, false
+			}
+			return r.Power.BatteryPercent, true
+		}),
+	{
+		enabled: func(t iotv1alpha1.PriorityTier) bool { return t.DegradeOnBattery },
+		exceeded: func(t iotv1alpha1.PriorityTier, r *heartbeat.Resources) (string, bool) {
+			if !t.DegradeOnBattery || r.Power == nil || !r.Power.OnBattery {
+				return "", false
+			}
+			return "on battery power", true
+		},
+	},
+}
+
+// hasResourceThresholds indica si el nivel define algún umbral de recursos.
+func hasResourceThresholds(tier iotv1alpha1.PriorityTier) bool {
+	for _, s := range resourceSignals {
+		if s.enabled(tier) {
+			return true
+		}
+	}
+	return false
+}
+
+// exceededSignals devuelve, descritas para logs y Events, las señales de
+// res que alcanzan los umbrales del nivel, p. ej. "cpu 95.00% >= 90%".
+// Las señales que el agente no reporta nunca se consideran superadas.
+func exceededSignals(tier iotv1alpha1.PriorityTier, res *heartbeat.Resources) []string {
+	var exceeded []string
+	for _, s := range resourceSignals {
+		if desc, ok := s.exceeded(tier, res); ok {
+			exceeded = append(exceeded, desc)
+		}
+	}
+	return exceeded
+}
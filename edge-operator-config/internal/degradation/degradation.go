@@ -3,55 +3,107 @@ package degradation
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// EvictionMode define cómo se retiran los pods no críticos de un nodo.
+type EvictionMode string
+
 const (
-	// PriorityLabelKey es la etiqueta que clasifica la prioridad del pod.
-	PriorityLabelKey = "edge.priority"
-	// PriorityNonCritical es el valor que identifica pods no críticos.
-	PriorityNonCritical = "non-critical"
+	// EvictionModeDelete borra los pods directamente, ignorando PodDisruptionBudgets.
+	EvictionModeDelete EvictionMode = "delete"
+	// EvictionModeEvict usa el subrecurso Eviction de policy/v1, que respeta
+	// los PodDisruptionBudgets y el borrado ordenado del pod.
+	EvictionModeEvict EvictionMode = "evict"
 )
 
+// Labels describe las convenciones de etiquetado de una ReducedNodePolicy.
+type Labels struct {
+	// PriorityKey es la etiqueta de pod cuyo valor indica su nivel de prioridad.
+	PriorityKey string
+	// WorkloadKey es la etiqueta de pod cuyo valor es el nombre de su Deployment.
+	// Solo se consulta si el pod no tiene controlador en sus ownerReferences.
+	WorkloadKey string
+}
+
 // Manager se encarga de reducir la carga de trabajo no crítica en un nodo degradado.
 type Manager struct {
 	Client client.Client
 	Log    logr.Logger
+	// Labels indica cómo identificar prioridad y Deployment de cada pod.
+	// Ver WithLabels.
+	Labels Labels
+	// EvictionMode selecciona entre borrado directo y Eviction API. New
+	// usa la Eviction API, que respeta los PodDisruptionBudgets.
+	EvictionMode EvictionMode
+	// Recorder, si no es nil, registra un Event en cada pod y carga afectada.
+	Recorder record.EventRecorder
 }
 
 // New crea un Manager de degradación listo para usar.
 func New(c client.Client, log logr.Logger) *Manager {
-	return &Manager{Client: c, Log: log}
+	return &Manager{
+		Client:       c,
+		Log:          log,
+		EvictionMode: EvictionModeEvict,
+	}
 }
 
-// EvictNonCriticalPods lista y elimina todos los pods con label
-// edge.priority=non-critical que corren en el nodo indicado.
-// Pods sin la etiqueta edge.priority nunca son tocados.
-func (m *Manager) EvictNonCriticalPods(ctx context.Context, nodeName string) error {
-	log := m.Log.WithValues("node", nodeName)
+// WithLabels devuelve una copia del Manager que usa las convenciones de
+// etiquetado indicadas, normalmente las de la policy que se reconcilia.
+func (m *Manager) WithLabels(labels Labels) *Manager {
+	c := *m
+	c.Labels = labels
+	return &c
+}
+
+// EvictionResult resume el resultado de EvictPods.
+type EvictionResult struct {
+	// Evicted es el número de pods retirados del nodo.
+	Evicted int
+	// Blocked contiene "namespace/pod: motivo" por cada pod cuya evicción
+	// bloqueó un PodDisruptionBudget. EvictPods no reintenta: el llamador
+	// vuelve a invocarlo más tarde, sin bloquear mientras tanto.
+	Blocked []string
+}
+
+// EvictPods lista y retira todos los pods con label Labels.PriorityKey=<priority>
+// que corren en el nodo indicado.
+// Pods sin la etiqueta de prioridad nunca son tocados.
+func (m *Manager) EvictPods(ctx context.Context, nodeName, priority string) (EvictionResult, error) {
+	log := m.Log.WithValues("node", nodeName, "priority", priority)
+	var result EvictionResult
 
 	var podList corev1.PodList
 	if err := m.Client.List(ctx, &podList,
 		client.MatchingFields{"spec.nodeName": nodeName},
 	); err != nil {
 		log.Error(err, "Error al listar pods del nodo")
-		return err
+		return result, err
 	}
 
-	evicted := 0
 	for i := range podList.Items {
 		pod := &podList.Items[i]
 
-		priority, hasPriorityLabel := pod.Labels[PriorityLabelKey]
+		podPriority, hasPriorityLabel := pod.Labels[m.Labels.PriorityKey]
 		// Restricción: no tocar pods sin la etiqueta
 		if !hasPriorityLabel {
 			continue
 		}
-		// No tocar pods críticos
-		if priority != PriorityNonCritical {
+		// No tocar pods de otros niveles
+		if podPriority != priority {
+			continue
+		}
+		// Los pods de DaemonSet volverían a crearse en el mismo nodo: nunca tocarlos
+		if ref := metav1.GetControllerOf(pod); ref != nil && ref.Kind == KindDaemonSet {
 			continue
 		}
 		// Pods en fase terminal ya no necesitan acción
@@ -59,15 +111,64 @@ func (m *Manager) EvictNonCriticalPods(ctx context.Context, nodeName string) err
 			continue
 		}
 
-		log.Info("Eliminando pod no crítico", "pod", pod.Name, "namespace", pod.Namespace)
+		if m.EvictionMode == EvictionModeEvict {
+			log.Info("Desalojando pod", "pod", pod.Name, "namespace", pod.Namespace)
+			blocked, err := m.evictPod(ctx, pod)
+			if err != nil {
+				log.Error(err, "No se pudo desalojar pod", "pod", pod.Name)
+				countAction(pod, ReasonEvictionFailed)
+				m.warning(pod, ReasonEvictionFailed, "Evicción en nodo %s fallida: %v", nodeName, err)
+				continue
+			}
+			if blocked != "" {
+				log.Info("Evicción bloqueada por PodDisruptionBudget",
+					"pod", pod.Name, "reason", blocked)
+				countAction(pod, ReasonEvictionBlocked)
+				m.warning(pod, ReasonEvictionBlocked,
+					"Evicción por nodo %s degradado bloqueada por PodDisruptionBudget: %s", nodeName, blocked)
+				result.Blocked = append(result.Blocked,
+					fmt.Sprintf("%s/%s: %s", pod.Namespace, pod.Name, blocked))
+				continue
+			}
+			countAction(pod, ReasonPodEvicted)
+			m.normal(pod, ReasonPodEvicted, "Desalojado por degradación del nodo %s (prioridad %s)", nodeName, priority)
+			result.Evicted++
+			continue
+		}
+
+		log.Info("Eliminando pod", "pod", pod.Name, "namespace", pod.Namespace)
 		if err := m.Client.Delete(ctx, pod); err != nil {
-			log.Error(err, "No se pudo eliminar pod no crítico", "pod", pod.Name)
+			log.Error(err, "No se pudo eliminar pod", "pod", pod.Name)
+			countAction(pod, ReasonEvictionFailed)
+			m.warning(pod, ReasonEvictionFailed, "Borrado en nodo %s fallido: %v", nodeName, err)
 			// Continuar con el resto aunque uno falle
 			continue
 		}
-		evicted++
+		countAction(pod, ReasonPodDeleted)
+		m.normal(pod, ReasonPodDeleted, "Eliminado por degradación del nodo %s (prioridad %s)", nodeName, priority)
+		result.Evicted++
 	}
 
-	log.Info("Degradación completada", "podsEviccionados", evicted)
-	return nil
-}
\ No newline at end of file
+	log.Info("Degradación completada",
+		"podsEviccionados", result.Evicted,
+		"podsBloqueados", len(result.Blocked),
+	)
+	return result, nil
+}
+
+// evictPod crea un Eviction para pod. Si el API server responde 429
+// (PodDisruptionBudget sin margen) devuelve el motivo del bloqueo.
+func (m *Manager) evictPod(ctx context.Context, pod *corev1.Pod) (string, error) {
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+	}
+	err := m.Client.SubResource("eviction").Create(ctx, pod, eviction)
+	switch {
+	case err == nil, apierrors.IsNotFound(err):
+		return "", nil
+	case apierrors.IsTooManyRequests(err):
+		return err.Error(), nil
+	default:
+		return "", err
+	}
+}
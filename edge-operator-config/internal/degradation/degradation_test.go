@@ -2,21 +2,28 @@ package degradation_test
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 
 	"github.com/jaiderssjgod/edge-operator/internal/degradation"
+	"github.com/jaiderssjgod/edge-operator/internal/metrics"
 )
 
-func TestEvictNonCriticalPods(t *testing.T) {
-	scheme := runtime.NewScheme()
-	_ = corev1.AddToScheme(scheme)
-
+func TestEvictPods(t *testing.T) {
 	pods := []corev1.Pod{
 		makePod("critical-pod",   "default", "node-1", "critical"),
 		makePod("noncrit-pod",    "default", "node-1", "non-critical"),
@@ -29,14 +36,18 @@ func TestEvictNonCriticalPods(t *testing.T) {
 		objs[i] = &pods[i]
 	}
 
-	fakeClient := fake.NewClientBuilder().
-		WithScheme(scheme).
-		WithRuntimeObjects(objs...).
-		Build()
+	fakeClient := newFakeClient(objs...)
 
-	mgr := degradation.New(fakeClient, logr.Discard())
-	if err := mgr.EvictNonCriticalPods(context.Background(), "node-1"); err != nil {
-		t.Fatalf("EvictNonCriticalPods returned error: %v", err)
+	// Sin Recorder las acciones se contabilizan igual
+	evicted := metrics.DegradationActions.WithLabelValues(degradation.KindPod, degradation.ReasonPodEvicted)
+	before := testutil.ToFloat64(evicted)
+
+	mgr := degradation.New(fakeClient, logr.Discard()).WithLabels(testLabels)
+	if _, err := mgr.EvictPods(context.Background(), "node-1", "non-critical"); err != nil {
+		t.Fatalf("EvictPods returned error: %v", err)
+	}
+	if got := testutil.ToFloat64(evicted) - before; got != 1 {
+		t.Errorf("edge_degradation_actions_total de %s aumentó %v, se esperaba 1", degradation.ReasonPodEvicted, got)
 	}
 
 	var remaining corev1.PodList
@@ -56,10 +67,338 @@ func TestEvictNonCriticalPods(t *testing.T) {
 	}
 }
 
+func TestNew_DefaultsToEvictionAPI(t *testing.T) {
+	mgr := degradation.New(newFakeClient(), logr.Discard())
+	if mgr.EvictionMode != degradation.EvictionModeEvict {
+		t.Errorf("EvictionMode por defecto = %q, se esperaba %q para respetar los PodDisruptionBudgets",
+			mgr.EvictionMode, degradation.EvictionModeEvict)
+	}
+}
+
+func TestEvictPods_EvictionAPIReportsPDBBlocks(t *testing.T) {
+	guarded := makePod("guarded-pod", "default", "node-1", "non-critical")
+	free := makePod("free-pod", "default", "node-1", "non-critical")
+
+	attempts := 0
+	fakeClient := newFakeClientWithInterceptor(interceptor.Funcs{
+		SubResourceCreate: func(ctx context.Context, c client.Client, subResource string,
+			obj client.Object, sub client.Object, opts ...client.SubResourceCreateOption) error {
+			if obj.GetName() == "guarded-pod" {
+				attempts++
+				return apierrors.NewTooManyRequests(
+					"Cannot evict pod as it would violate the pod's disruption budget.", 0)
+			}
+			return c.SubResource(subResource).Create(ctx, obj, sub, opts...)
+		},
+	}, &guarded, &free)
+
+	mgr := degradation.New(fakeClient, logr.Discard()).WithLabels(testLabels)
+	mgr.EvictionMode = degradation.EvictionModeEvict
+	recorder := record.NewFakeRecorder(10)
+	mgr.Recorder = recorder
+
+	result, err := mgr.EvictPods(context.Background(), "node-1", "non-critical")
+	if err != nil {
+		t.Fatalf("EvictPods returned error: %v", err)
+	}
+	if result.Evicted != 1 {
+		t.Errorf("se esperaba 1 pod desalojado, hubo %d", result.Evicted)
+	}
+	if len(result.Blocked) != 1 || !strings.HasPrefix(result.Blocked[0], "default/guarded-pod: ") {
+		t.Errorf("evicciones bloqueadas inesperadas: %v", result.Blocked)
+	}
+	// Sin reintentos dentro de EvictPods: el reconcile vuelve más tarde
+	if attempts != 1 {
+		t.Errorf("se esperaba 1 intento de evicción para guarded-pod, hubo %d", attempts)
+	}
+
+	var remaining corev1.PodList
+	_ = fakeClient.List(context.Background(), &remaining)
+	names := podNames(remaining.Items)
+	if !contains(names, "guarded-pod") || contains(names, "free-pod") {
+		t.Errorf("pods restantes inesperados: %v", names)
+	}
+
+	close(recorder.Events)
+	var events []string
+	for e := range recorder.Events {
+		events = append(events, strings.Join(strings.Fields(e)[:2], " "))
+	}
+	if len(events) != 2 ||
+		!contains(events, "Warning "+degradation.ReasonEvictionBlocked) ||
+		!contains(events, "Normal "+degradation.ReasonPodEvicted) {
+		t.Errorf("Events inesperados: %v", events)
+	}
+}
+
+func TestDegradeAndRestoreDeploymentReplicas(t *testing.T) {
+	ctx := context.Background()
+
+	web := makeDeployment("web", "default", "non-critical", 3)
+	parked := makeDeployment("parked", "default", "non-critical", 0)
+	webPod := makePod("web-abc", "default", "node-1", "non-critical")
+	webPod.Labels["app"] = "web"
+	parkedPod := makePod("parked-abc", "default", "node-1", "non-critical")
+	parkedPod.Labels["app"] = "parked"
+
+	fakeClient := newFakeClient(&web, &parked, &webPod, &parkedPod)
+	mgr := degradation.New(fakeClient, logr.Discard()).WithLabels(testLabels)
+	scope := degradation.Scope{Policy: "policy-a", Node: "node-1"}
+
+	if err := mgr.DegradeWorkloads(ctx, scope, "non-critical"); err != nil {
+		t.Fatalf("DegradeWorkloads returned error: %v", err)
+	}
+
+	got := getDeployment(t, fakeClient, "web")
+	if *got.Spec.Replicas != 0 {
+		t.Errorf("web debería estar en 0 réplicas, tiene %d", *got.Spec.Replicas)
+	}
+	if got.Annotations[degradation.OriginalReplicasAnnotation] != "3" {
+		t.Errorf("anotación de réplicas originales inesperada: %q",
+			got.Annotations[degradation.OriginalReplicasAnnotation])
+	}
+	if _, ok := getDeployment(t, fakeClient, "parked").Annotations[degradation.OriginalReplicasAnnotation]; ok {
+		t.Error("parked ya estaba en 0 y no debería haber sido anotado")
+	}
+
+	scaled, err := mgr.HasDegradedWorkloads(ctx, scope)
+	if err != nil || !scaled {
+		t.Fatalf("HasDegradedWorkloads = %v, %v; se esperaba true", scaled, err)
+	}
+
+	if err := mgr.RestoreWorkloads(ctx, scope); err != nil {
+		t.Fatalf("RestoreWorkloads returned error: %v", err)
+	}
+
+	got = getDeployment(t, fakeClient, "web")
+	if *got.Spec.Replicas != 3 {
+		t.Errorf("web debería volver a 3 réplicas, tiene %d", *got.Spec.Replicas)
+	}
+	if _, ok := got.Annotations[degradation.OriginalReplicasAnnotation]; ok {
+		t.Error("la anotación de réplicas originales debería eliminarse al restaurar")
+	}
+	if *getDeployment(t, fakeClient, "parked").Spec.Replicas != 0 {
+		t.Error("parked fue detenido deliberadamente y no debería restaurarse")
+	}
+}
+
+func TestRestoreOnlyUndoesSameScope(t *testing.T) {
+	ctx := context.Background()
+
+	web := makeDeployment("web", "default", "non-critical", 2)
+	webPod := makePod("web-abc", "default", "node-1", "non-critical")
+	webPod.Labels["app"] = "web"
+
+	fakeClient := newFakeClient(&web, &webPod)
+	mgr := degradation.New(fakeClient, logr.Discard()).WithLabels(testLabels)
+	degraded := degradation.Scope{Policy: "policy-a", Node: "node-1"}
+
+	if err := mgr.DegradeWorkloads(ctx, degraded, "non-critical"); err != nil {
+		t.Fatalf("DegradeWorkloads returned error: %v", err)
+	}
+
+	// Otro nodo, u otra policy sobre el mismo nodo, se recupera: no debe restaurar web
+	for _, other := range []degradation.Scope{
+		{Policy: "policy-a", Node: "node-2"},
+		{Policy: "policy-b", Node: "node-1"},
+	} {
+		scaled, err := mgr.HasDegradedWorkloads(ctx, other)
+		if err != nil || scaled {
+			t.Errorf("HasDegradedWorkloads(%v) = %v, %v; se esperaba false", other, scaled, err)
+		}
+		if err := mgr.RestoreWorkloads(ctx, other); err != nil {
+			t.Fatalf("RestoreWorkloads returned error: %v", err)
+		}
+		if *getDeployment(t, fakeClient, "web").Spec.Replicas != 0 {
+			t.Fatalf("web fue restaurado por %v, que no lo degradó", other)
+		}
+	}
+
+	if err := mgr.RestoreWorkloads(ctx, degraded); err != nil {
+		t.Fatalf("RestoreWorkloads returned error: %v", err)
+	}
+	got := getDeployment(t, fakeClient, "web")
+	if *got.Spec.Replicas != 2 {
+		t.Errorf("web debería volver a 2 réplicas, tiene %d", *got.Spec.Replicas)
+	}
+	if _, ok := got.Labels[degradation.DegradedLabel]; ok {
+		t.Error("la etiqueta de degradación debería eliminarse al restaurar")
+	}
+}
+
+func TestDegradeResolvesDeploymentThroughOwnerReferences(t *testing.T) {
+	ctx := context.Background()
+
+	// Chart de Helm: sin label app, solo app.kubernetes.io/name
+	web := makeDeployment("web-chart", "default", "non-critical", 2)
+	rs := appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "web-chart-7f9c",
+			Namespace:       "default",
+			OwnerReferences: []metav1.OwnerReference{controllerRef("Deployment", "web-chart")},
+		},
+	}
+	pod := makePod("web-chart-7f9c-abc", "default", "node-1", "non-critical")
+	pod.Labels["app.kubernetes.io/name"] = "web"
+	pod.OwnerReferences = []metav1.OwnerReference{controllerRef("ReplicaSet", "web-chart-7f9c")}
+
+	fakeClient := newFakeClient(&web, &rs, &pod)
+	mgr := degradation.New(fakeClient, logr.Discard()).WithLabels(testLabels)
+
+	scope := degradation.Scope{Policy: "policy-a", Node: "node-1"}
+	if err := mgr.DegradeWorkloads(ctx, scope, "non-critical"); err != nil {
+		t.Fatalf("DegradeWorkloads returned error: %v", err)
+	}
+	if *getDeployment(t, fakeClient, "web-chart").Spec.Replicas != 0 {
+		t.Error("web-chart debería resolverse vía ReplicaSet y escalarse a 0")
+	}
+}
+
+func TestDegradeStatefulSetsCronJobsAndSkipsDaemonSets(t *testing.T) {
+	ctx := context.Background()
+
+	replicas := int32(3)
+	sts := appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "cache", Namespace: "default"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+	}
+	stsPod := makePod("cache-0", "default", "node-1", "non-critical")
+	stsPod.OwnerReferences = []metav1.OwnerReference{controllerRef("StatefulSet", "cache")}
+
+	cron := batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "default"},
+		Status: batchv1.CronJobStatus{
+			Active: []corev1.ObjectReference{{Namespace: "default", Name: "report-123"}},
+		},
+	}
+	job := batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "report-123",
+			Namespace:       "default",
+			OwnerReferences: []metav1.OwnerReference{controllerRef("CronJob", "report")},
+		},
+	}
+	jobPod := makePod("report-123-xyz", "default", "node-1", "non-critical")
+	jobPod.OwnerReferences = []metav1.OwnerReference{controllerRef("Job", "report-123")}
+
+	dsPod := makePod("log-agent-abc", "default", "node-1", "non-critical")
+	dsPod.OwnerReferences = []metav1.OwnerReference{controllerRef("DaemonSet", "log-agent")}
+
+	fakeClient := newFakeClient(&sts, &stsPod, &cron, &job, &jobPod, &dsPod)
+	mgr := degradation.New(fakeClient, logr.Discard()).WithLabels(testLabels)
+	scope := degradation.Scope{Policy: "policy-a", Node: "node-1"}
+
+	if err := mgr.DegradeWorkloads(ctx, scope, "non-critical"); err != nil {
+		t.Fatalf("DegradeWorkloads returned error: %v", err)
+	}
+	if _, err := mgr.EvictPods(ctx, "node-1", "non-critical"); err != nil {
+		t.Fatalf("EvictPods returned error: %v", err)
+	}
+
+	var gotSts appsv1.StatefulSet
+	_ = fakeClient.Get(ctx, client.ObjectKeyFromObject(&sts), &gotSts)
+	if *gotSts.Spec.Replicas != 0 || gotSts.Annotations[degradation.DegradationActionAnnotation] != degradation.ActionScaled {
+		t.Errorf("cache debería estar escalado a 0 con acción scaled: %+v", gotSts.ObjectMeta.Annotations)
+	}
+	var gotCron batchv1.CronJob
+	_ = fakeClient.Get(ctx, client.ObjectKeyFromObject(&cron), &gotCron)
+	if gotCron.Spec.Suspend == nil || !*gotCron.Spec.Suspend ||
+		gotCron.Annotations[degradation.DegradationActionAnnotation] != degradation.ActionSuspended {
+		t.Error("report debería estar suspendido con acción suspended")
+	}
+	var gotJob batchv1.Job
+	_ = fakeClient.Get(ctx, client.ObjectKeyFromObject(&job), &gotJob)
+	if gotJob.Spec.Suspend == nil || !*gotJob.Spec.Suspend {
+		t.Error("el Job activo del CronJob debería estar suspendido")
+	}
+	var gotDsPod corev1.Pod
+	if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(&dsPod), &gotDsPod); err != nil {
+		t.Errorf("el pod del DaemonSet nunca debe tocarse: %v", err)
+	}
+
+	if err := mgr.RestoreWorkloads(ctx, scope); err != nil {
+		t.Fatalf("RestoreWorkloads returned error: %v", err)
+	}
+	_ = fakeClient.Get(ctx, client.ObjectKeyFromObject(&sts), &gotSts)
+	if *gotSts.Spec.Replicas != 3 {
+		t.Errorf("cache debería volver a 3 réplicas, tiene %d", *gotSts.Spec.Replicas)
+	}
+	_ = fakeClient.Get(ctx, client.ObjectKeyFromObject(&cron), &gotCron)
+	if *gotCron.Spec.Suspend {
+		t.Error("report debería reanudarse al restaurar")
+	}
+	_ = fakeClient.Get(ctx, client.ObjectKeyFromObject(&job), &gotJob)
+	if *gotJob.Spec.Suspend {
+		t.Error("el Job activo debería reanudarse al restaurar")
+	}
+	if degraded, _ := mgr.HasDegradedWorkloads(ctx, scope); degraded {
+		t.Error("no deberían quedar cargas degradadas tras restaurar")
+	}
+}
+
+func controllerRef(kind, name string) metav1.OwnerReference {
+	isController := true
+	apiVersion := "apps/v1"
+	if kind == "Job" || kind == "CronJob" {
+		apiVersion = "batch/v1"
+	}
+	return metav1.OwnerReference{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Name:       name,
+		UID:        types.UID(name),
+		Controller: &isController,
+	}
+}
+
+var testLabels = degradation.Labels{PriorityKey: "edge.priority", WorkloadKey: "app"}
+
+// newFakeClient crea un cliente fake con el índice spec.nodeName registrado,
+// igual que SetupWithManager en el operador real.
+func newFakeClient(objs ...runtime.Object) client.Client {
+	return newFakeClientWithInterceptor(interceptor.Funcs{}, objs...)
+}
+
+func newFakeClientWithInterceptor(funcs interceptor.Funcs, objs ...runtime.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = appsv1.AddToScheme(scheme)
+	_ = batchv1.AddToScheme(scheme)
+
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithRuntimeObjects(objs...).
+		WithIndex(&corev1.Pod{}, "spec.nodeName", func(obj client.Object) []string {
+			return []string{obj.(*corev1.Pod).Spec.NodeName}
+		}).
+		WithInterceptorFuncs(funcs).
+		Build()
+}
+
+func makeDeployment(name, ns, priority string, replicas int32) appsv1.Deployment {
+	return appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels:    map[string]string{"edge.priority": priority},
+		},
+		Spec: appsv1.DeploymentSpec{Replicas: &replicas},
+	}
+}
+
+func getDeployment(t *testing.T, c client.Client, name string) appsv1.Deployment {
+	t.Helper()
+	var deploy appsv1.Deployment
+	if err := c.Get(context.Background(), client.ObjectKey{Name: name, Namespace: "default"}, &deploy); err != nil {
+		t.Fatalf("no se pudo obtener deployment %s: %v", name, err)
+	}
+	return deploy
+}
+
 func makePod(name, ns, node, priority string) corev1.Pod {
 	labels := map[string]string{}
 	if priority != "" {
-		labels[degradation.PriorityLabelKey] = priority
+		labels["edge.priority"] = priority
 	}
 	return corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Labels: labels},
@@ -0,0 +1,43 @@
+// internal/degradation/events.go
+package degradation
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/jaiderssjgod/edge-operator/internal/metrics"
+)
+
+// Razones de los Events que el Manager registra sobre pods y cargas.
+const (
+	ReasonPodEvicted       = "EdgePodEvicted"
+	ReasonPodDeleted       = "EdgePodDeleted"
+	ReasonEvictionBlocked  = "EdgeEvictionBlocked"
+	ReasonEvictionFailed   = "EdgeEvictionFailed"
+	ReasonWorkloadDegraded = "EdgeWorkloadDegraded"
+	ReasonWorkloadRestored = "EdgeWorkloadRestored"
+)
+
+// event registra un Event sobre obj si el Manager tiene Recorder.
+func (m *Manager) event(obj client.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	if m.Recorder == nil {
+		return
+	}
+	m.Recorder.Eventf(obj, eventType, reason, messageFmt, args...)
+}
+
+// warning es un atajo de event para Events de tipo Warning.
+func (m *Manager) warning(obj client.Object, reason, messageFmt string, args ...interface{}) {
+	m.event(obj, corev1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+// normal es un atajo de event para Events de tipo Normal.
+func (m *Manager) normal(obj client.Object, reason, messageFmt string, args ...interface{}) {
+	m.event(obj, corev1.EventTypeNormal, reason, messageFmt, args...)
+}
+
+// countAction contabiliza en edge_degradation_actions_total una acción sobre
+// obj, con la misma razón que su Event.
+func countAction(obj client.Object, reason string) {
+	metrics.DegradationActions.WithLabelValues(kindOf(obj), reason).Inc()
+}
@@ -3,72 +3,378 @@ package degradation
 
 import (
     "context"
+    "errors"
+    "fmt"
+    "strconv"
 
     appsv1 "k8s.io/api/apps/v1"
+    batchv1 "k8s.io/api/batch/v1"
     corev1 "k8s.io/api/core/v1"
     "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-// ScaleDownNonCriticalDeployments escala a 0 los Deployments no críticos
-// cuyos pods corren en el nodo indicado.
-// Usa la misma lógica de labels que EvictNonCriticalPods: edge.priority=non-critical
-func (m *Manager) ScaleDownNonCriticalDeployments(ctx context.Context, nodeName, _ string) error {
-    deployments, err := m.findNonCriticalDeployments(ctx, nodeName)
+const (
+    // OriginalReplicasAnnotation guarda las réplicas que tenía un Deployment o
+    // StatefulSet antes de que el operador lo escalara a 0.
+    OriginalReplicasAnnotation = "iot.mydomain.com/original-replicas"
+    // DegradationActionAnnotation registra qué hizo el operador sobre el objeto
+    // (ActionScaled o ActionSuspended) para poder deshacerlo al restaurar.
+    DegradationActionAnnotation = "iot.mydomain.com/degradation-action"
+    // DegradedNodeAnnotation guarda el nodo cuya sobrecarga provocó la degradación.
+    DegradedNodeAnnotation = "iot.mydomain.com/degraded-node"
+    // DegradedPolicyAnnotation guarda la ReducedNodePolicy que ordenó la degradación.
+    DegradedPolicyAnnotation = "iot.mydomain.com/degraded-policy"
+    // DegradedLabel marca los objetos degradados por el operador para poder
+    // listarlos sin recorrer todo el clúster. Su presencia indica que la
+    // degradación fue hecha por el operador y que el objeto debe restaurarse.
+    DegradedLabel = "iot.mydomain.com/degraded"
+)
+
+const (
+    // ActionScaled indica réplicas llevadas a 0 (Deployment, StatefulSet).
+    ActionScaled = "scaled"
+    // ActionSuspended indica spec.suspend=true (CronJob, Job).
+    ActionSuspended = "suspended"
+)
+
+// Scope identifica la policy y el nodo en cuyo nombre se degrada una carga.
+// La restauración de un nodo solo deshace lo degradado con su mismo Scope.
+// Un Scope sin Node abarca todos los nodos de la policy, p. ej. al borrarla.
+type Scope struct {
+    Policy string
+    Node   string
+}
+
+// DegradeWorkloads detiene los controladores del nivel priority cuyos pods
+// corren en el nodo indicado: escala a 0 Deployments y StatefulSets y
+// suspende CronJobs (junto con sus Jobs activos) y Jobs sueltos.
+// Los pods de DaemonSets nunca se tocan.
+// Usa la misma lógica de labels que EvictPods: Labels.PriorityKey=<priority>
+// Antes de modificar cada objeto registra la acción, el estado original y el
+// Scope en anotaciones.
+func (m *Manager) DegradeWorkloads(ctx context.Context, scope Scope, priority string) error {
+    workloads, err := m.findWorkloads(ctx, scope.Node, priority)
     if err != nil {
         return err
     }
 
-    for i := range deployments {
-        deploy := &deployments[i]
-        if deploy.Spec.Replicas != nil && *deploy.Spec.Replicas == 0 {
+    for _, w := range workloads {
+        objs, err := m.degradationTargets(ctx, w)
+        if err != nil {
+            m.Log.Error(err, "Error obteniendo carga a degradar", "kind", w.Kind, "name", w.Name)
             continue
         }
-        zero := int32(0)
-        deploy.Spec.Replicas = &zero
-        if err := m.Client.Update(ctx, deploy); err != nil {
-            m.Log.Error(err, "Error escalando deployment a 0", "deployment", deploy.Name)
-            continue
+        for _, obj := range objs {
+            action, changed := degradeObject(obj, scope)
+            if !changed {
+                continue
+            }
+            if err := m.Client.Update(ctx, obj); err != nil {
+                m.Log.Error(err, "Error degradando carga", "kind", kindOf(obj), "name", obj.GetName())
+                continue
+            }
+            m.Log.Info("Carga degradada por umbral de recursos",
+                "kind", kindOf(obj),
+                "name", obj.GetName(),
+                "namespace", obj.GetNamespace(),
+                "action", action,
+                "node", scope.Node,
+                "policy", scope.Policy,
+                "priority", priority,
+            )
+            countAction(obj, ReasonWorkloadDegraded)
+            m.normal(obj, ReasonWorkloadDegraded,
+                "Degradada (%s) por presión de recursos en el nodo %s, policy %s, prioridad %s",
+                action, scope.Node, scope.Policy, priority)
         }
-        m.Log.Info("Deployment escalado a 0 por umbral de recursos",
-            "deployment", deploy.Name,
-            "node", nodeName,
-        )
     }
     return nil
 }
 
-// ScaleUpNonCriticalDeployments restaura a 1 los Deployments escalados a 0.
+// RestoreWorkloads deshace, según DegradationActionAnnotation, la degradación
+// de todos los objetos que el propio operador degradó para el Scope indicado.
 // Busca por labels directamente, no por pods activos (pueden estar en 0).
-func (m *Manager) ScaleUpNonCriticalDeployments(ctx context.Context, nodeName, _ string) error {
-    var deployList appsv1.DeploymentList
-    if err := m.Client.List(ctx, &deployList,
-        client.MatchingLabels{PriorityLabelKey: PriorityNonCritical},
-    ); err != nil {
+// Los objetos degradados por otro nodo u otra policy nunca son tocados.
+// Devuelve los errores de los objetos que no se pudieron actualizar, para
+// reintentar; los que tienen anotaciones inválidas solo se registran.
+func (m *Manager) RestoreWorkloads(ctx context.Context, scope Scope) error {
+    objs, err := m.listDegraded(ctx, scope)
+    if err != nil {
         return err
     }
 
-    for i := range deployList.Items {
-        deploy := &deployList.Items[i]
-        if deploy.Spec.Replicas == nil || *deploy.Spec.Replicas != 0 {
-            continue // no fue escalado a 0, omitir
-        }
-        one := int32(1)
-        deploy.Spec.Replicas = &one
-        if err := m.Client.Update(ctx, deploy); err != nil {
-            m.Log.Error(err, "Error restaurando deployment", "deployment", deploy.Name)
+    var errs []error
+    for _, obj := range objs {
+        if err := restoreObject(obj); err != nil {
+            m.Log.Error(err, "Anotaciones de degradación inválidas, omitiendo",
+                "kind", kindOf(obj), "name", obj.GetName())
+            continue
+        }
+        if err := m.Client.Update(ctx, obj); err != nil {
+            m.Log.Error(err, "Error restaurando carga", "kind", kindOf(obj), "name", obj.GetName())
+            errs = append(errs, fmt.Errorf("restaurando %s %s/%s: %w", kindOf(obj), obj.GetNamespace(), obj.GetName(), err))
             continue
         }
-        m.Log.Info("Deployment restaurado por normalización de recursos",
-            "deployment", deploy.Name,
-            "node", nodeName,
+        m.Log.Info("Carga restaurada por normalización de recursos",
+            "kind", kindOf(obj),
+            "name", obj.GetName(),
+            "namespace", obj.GetNamespace(),
+            "node", scope.Node,
+            "policy", scope.Policy,
         )
+        countAction(obj, ReasonWorkloadRestored)
+        if scope.Node == "" {
+            m.normal(obj, ReasonWorkloadRestored, "Restaurada al borrarse la policy %s", scope.Policy)
+        } else {
+            m.normal(obj, ReasonWorkloadRestored,
+                "Restaurada tras normalizarse los recursos del nodo %s, policy %s", scope.Node, scope.Policy)
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// HasDegradedWorkloads indica si existe algún objeto degradado por el
+// operador para el Scope indicado que aún no ha sido restaurado.
+func (m *Manager) HasDegradedWorkloads(ctx context.Context, scope Scope) (bool, error) {
+    objs, err := m.listDegraded(ctx, scope)
+    if err != nil {
+        return false, err
+    }
+    return len(objs) > 0, nil
+}
+
+// listDegraded devuelve los objetos de todos los tipos soportados degradados
+// por el operador para el Scope indicado.
+func (m *Manager) listDegraded(ctx context.Context, scope Scope) ([]client.Object, error) {
+    selector := client.MatchingLabels{DegradedLabel: "true"}
+    var result []client.Object
+
+    var deployList appsv1.DeploymentList
+    if err := m.Client.List(ctx, &deployList, selector); err != nil {
+        return nil, err
+    }
+    for i := range deployList.Items {
+        result = append(result, &deployList.Items[i])
+    }
+
+    var stsList appsv1.StatefulSetList
+    if err := m.Client.List(ctx, &stsList, selector); err != nil {
+        return nil, err
+    }
+    for i := range stsList.Items {
+        result = append(result, &stsList.Items[i])
+    }
+
+    var cronList batchv1.CronJobList
+    if err := m.Client.List(ctx, &cronList, selector); err != nil {
+        return nil, err
     }
+    for i := range cronList.Items {
+        result = append(result, &cronList.Items[i])
+    }
+
+    var jobList batchv1.JobList
+    if err := m.Client.List(ctx, &jobList, selector); err != nil {
+        return nil, err
+    }
+    for i := range jobList.Items {
+        result = append(result, &jobList.Items[i])
+    }
+
+    inScopeOnly := result[:0]
+    for _, obj := range result {
+        if inScope(obj, scope) {
+            inScopeOnly = append(inScopeOnly, obj)
+        }
+    }
+    return inScopeOnly, nil
+}
+
+// degradationTargets obtiene los objetos a modificar para degradar w. Un
+// CronJob arrastra a sus Jobs activos para que sus pods también se detengan.
+func (m *Manager) degradationTargets(ctx context.Context, w Workload) ([]client.Object, error) {
+    key := client.ObjectKey{Namespace: w.Namespace, Name: w.Name}
+    switch w.Kind {
+    case KindDeployment:
+        var deploy appsv1.Deployment
+        if err := m.Client.Get(ctx, key, &deploy); err != nil {
+            return nil, client.IgnoreNotFound(err)
+        }
+        return []client.Object{&deploy}, nil
+    case KindStatefulSet:
+        var sts appsv1.StatefulSet
+        if err := m.Client.Get(ctx, key, &sts); err != nil {
+            return nil, client.IgnoreNotFound(err)
+        }
+        return []client.Object{&sts}, nil
+    case KindJob:
+        var job batchv1.Job
+        if err := m.Client.Get(ctx, key, &job); err != nil {
+            return nil, client.IgnoreNotFound(err)
+        }
+        return []client.Object{&job}, nil
+    case KindCronJob:
+        var cron batchv1.CronJob
+        if err := m.Client.Get(ctx, key, &cron); err != nil {
+            return nil, client.IgnoreNotFound(err)
+        }
+        objs := []client.Object{&cron}
+        for _, ref := range cron.Status.Active {
+            var job batchv1.Job
+            if err := m.Client.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, &job); err != nil {
+                continue
+            }
+            objs = append(objs, &job)
+        }
+        return objs, nil
+    }
+    m.Log.Info("Tipo de carga no soportado para degradación, omitiendo", "kind", w.Kind, "name", w.Name)
+    return nil, nil
+}
+
+// degradeObject aplica a obj la acción de degradación que corresponde a su
+// tipo. Devuelve false si obj ya estaba detenido por alguien más: en ese caso
+// no es nuestro y no se anota.
+func degradeObject(obj client.Object, scope Scope) (string, bool) {
+    if replicas := replicasField(obj); replicas != nil {
+        if *replicas != nil && **replicas == 0 {
+            return "", false
+        }
+        original := int32(1)
+        if *replicas != nil {
+            original = **replicas
+        }
+        markDegraded(obj, scope, ActionScaled)
+        obj.GetAnnotations()[OriginalReplicasAnnotation] = strconv.Itoa(int(original))
+        zero := int32(0)
+        *replicas = &zero
+        return ActionScaled, true
+    }
+    if suspend := suspendField(obj); suspend != nil {
+        if *suspend != nil && **suspend {
+            return "", false
+        }
+        markDegraded(obj, scope, ActionSuspended)
+        suspended := true
+        *suspend = &suspended
+        return ActionSuspended, true
+    }
+    return "", false
+}
+
+// restoreObject deshace la acción registrada en DegradationActionAnnotation
+// y elimina las marcas de degradación. Si alguien cambió el objeto mientras
+// estaba degradado se respeta su decisión.
+func restoreObject(obj client.Object) error {
+    annotations := obj.GetAnnotations()
+    switch annotations[DegradationActionAnnotation] {
+    case ActionScaled:
+        original, err := strconv.Atoi(annotations[OriginalReplicasAnnotation])
+        if err != nil {
+            return err
+        }
+        if original < 0 {
+            return fmt.Errorf("réplicas originales negativas: %d", original)
+        }
+        if replicas := replicasField(obj); replicas != nil && (*replicas == nil || **replicas == 0) {
+            restored := int32(original)
+            *replicas = &restored
+        }
+    case ActionSuspended:
+        if suspend := suspendField(obj); suspend != nil && *suspend != nil && **suspend {
+            resumed := false
+            *suspend = &resumed
+        }
+    }
+    clearDegraded(obj)
     return nil
 }
 
-// findNonCriticalDeployments busca Deployments no críticos con pods en el nodo.
-// Usa edge.priority=non-critical igual que EvictNonCriticalPods.
-func (m *Manager) findNonCriticalDeployments(ctx context.Context, nodeName string) ([]appsv1.Deployment, error) {
+// replicasField devuelve el puntero a spec.replicas de los tipos escalables.
+func replicasField(obj client.Object) **int32 {
+    switch o := obj.(type) {
+    case *appsv1.Deployment:
+        return &o.Spec.Replicas
+    case *appsv1.StatefulSet:
+        return &o.Spec.Replicas
+    }
+    return nil
+}
+
+// suspendField devuelve el puntero a spec.suspend de los tipos suspendibles.
+func suspendField(obj client.Object) **bool {
+    switch o := obj.(type) {
+    case *batchv1.CronJob:
+        return &o.Spec.Suspend
+    case *batchv1.Job:
+        return &o.Spec.Suspend
+    }
+    return nil
+}
+
+// kindOf devuelve el Kind de los tipos soportados, para logs.
+func kindOf(obj client.Object) string {
+    switch obj.(type) {
+    case *corev1.Pod:
+        return KindPod
+    case *appsv1.Deployment:
+        return KindDeployment
+    case *appsv1.StatefulSet:
+        return KindStatefulSet
+    case *batchv1.CronJob:
+        return KindCronJob
+    case *batchv1.Job:
+        return KindJob
+    }
+    return ""
+}
+
+// markDegraded etiqueta y anota obj como degradado por el operador en scope.
+func markDegraded(obj client.Object, scope Scope, action string) {
+    labels := obj.GetLabels()
+    if labels == nil {
+        labels = map[string]string{}
+    }
+    labels[DegradedLabel] = "true"
+    obj.SetLabels(labels)
+
+    annotations := obj.GetAnnotations()
+    if annotations == nil {
+        annotations = map[string]string{}
+    }
+    annotations[DegradationActionAnnotation] = action
+    annotations[DegradedNodeAnnotation] = scope.Node
+    annotations[DegradedPolicyAnnotation] = scope.Policy
+    obj.SetAnnotations(annotations)
+}
+
+// clearDegraded elimina las marcas que markDegraded y degradeObject dejaron en obj.
+func clearDegraded(obj client.Object) {
+    labels := obj.GetLabels()
+    delete(labels, DegradedLabel)
+    obj.SetLabels(labels)
+
+    annotations := obj.GetAnnotations()
+    delete(annotations, DegradationActionAnnotation)
+    delete(annotations, OriginalReplicasAnnotation)
+    delete(annotations, DegradedNodeAnnotation)
+    delete(annotations, DegradedPolicyAnnotation)
+    obj.SetAnnotations(annotations)
+}
+
+// inScope indica si obj fue degradado por el operador para scope.
+func inScope(obj client.Object, scope Scope) bool {
+    annotations := obj.GetAnnotations()
+    return obj.GetLabels()[DegradedLabel] == "true" &&
+        (scope.Node == "" || annotations[DegradedNodeAnnotation] == scope.Node) &&
+        annotations[DegradedPolicyAnnotation] == scope.Policy
+}
+
+// findWorkloads busca los controladores del nivel priority con pods en el nodo.
+// Usa Labels.PriorityKey=<priority> igual que EvictPods y resuelve el
+// controlador de cada pod a través de sus ownerReferences. Los DaemonSets
+// se excluyen explícitamente: deben seguir corriendo en todos los nodos.
+func (m *Manager) findWorkloads(ctx context.Context, nodeName, priority string) ([]Workload, error) {
     var podList corev1.PodList
     if err := m.Client.List(ctx, &podList,
         client.MatchingFields{"spec.nodeName": nodeName},
@@ -76,31 +382,27 @@ func (m *Manager) findNonCriticalDeployments(ctx context.Context, nodeName strin
         return nil, err
     }
 
-    seen := map[string]bool{}
-    var result []appsv1.Deployment
+    seen := map[Workload]bool{}
+    var result []Workload
 
-    for _, pod := range podList.Items {
-        // Usar la misma lógica que EvictNonCriticalPods
-        priority, hasPriorityLabel := pod.Labels[PriorityLabelKey]
-        if !hasPriorityLabel || priority != PriorityNonCritical {
+    for i := range podList.Items {
+        pod := &podList.Items[i]
+        // Usar la misma lógica que EvictPods
+        podPriority, hasPriorityLabel := pod.Labels[m.Labels.PriorityKey]
+        if !hasPriorityLabel || podPriority != priority {
             continue
         }
 
-        // Buscar el Deployment dueño via label app
-        deployName := pod.Labels["app"]
-        if deployName == "" || seen[deployName] {
+        owner, found, err := m.resolveOwner(ctx, pod)
+        if err != nil {
+            m.Log.Error(err, "No se pudo resolver el dueño del pod", "pod", pod.Name)
             continue
         }
-        seen[deployName] = true
-
-        var deploy appsv1.Deployment
-        if err := m.Client.Get(ctx, client.ObjectKey{
-            Name:      deployName,
-            Namespace: pod.Namespace,
-        }, &deploy); err != nil {
+        if !found || owner.Kind == KindDaemonSet || seen[owner] {
             continue
         }
-        result = append(result, deploy)
+        seen[owner] = true
+        result = append(result, owner)
     }
     return result, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,66 @@
+// internal/degradation/owners.go
+package degradation
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Tipos de controlador que resolveOwner sabe identificar.
+const (
+	KindPod         = "Pod"
+	KindDeployment  = "Deployment"
+	KindReplicaSet  = "ReplicaSet"
+	KindStatefulSet = "StatefulSet"
+	KindDaemonSet   = "DaemonSet"
+	KindJob         = "Job"
+	KindCronJob     = "CronJob"
+)
+
+// Workload identifica el controlador de nivel superior de un pod.
+type Workload struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// resolveOwner sigue las ownerReferences del pod hasta su controlador de
+// nivel superior: Pod → ReplicaSet → Deployment, Pod → StatefulSet,
+// Pod → Job → CronJob, Pod → DaemonSet. Si el pod no tiene controlador se recurre a Labels.WorkloadKey,
+// que nombra directamente a un Deployment. Devuelve false si no hay dueño.
+func (m *Manager) resolveOwner(ctx context.Context, pod *corev1.Pod) (Workload, bool, error) {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		name := pod.Labels[m.Labels.WorkloadKey]
+		if m.Labels.WorkloadKey == "" || name == "" {
+			return Workload{}, false, nil
+		}
+		return Workload{Kind: KindDeployment, Namespace: pod.Namespace, Name: name}, true, nil
+	}
+
+	owner := Workload{Kind: ref.Kind, Namespace: pod.Namespace, Name: ref.Name}
+	switch ref.Kind {
+	case KindReplicaSet:
+		var rs appsv1.ReplicaSet
+		if err := m.Client.Get(ctx, client.ObjectKey{Namespace: pod.Namespace, Name: ref.Name}, &rs); err != nil {
+			return Workload{}, false, client.IgnoreNotFound(err)
+		}
+		if parent := metav1.GetControllerOf(&rs); parent != nil && parent.Kind == KindDeployment {
+			owner = Workload{Kind: KindDeployment, Namespace: pod.Namespace, Name: parent.Name}
+		}
+	case KindJob:
+		var job batchv1.Job
+		if err := m.Client.Get(ctx, client.ObjectKey{Namespace: pod.Namespace, Name: ref.Name}, &job); err != nil {
+			return Workload{}, false, client.IgnoreNotFound(err)
+		}
+		if parent := metav1.GetControllerOf(&job); parent != nil && parent.Kind == KindCronJob {
+			owner = Workload{Kind: KindCronJob, Namespace: pod.Namespace, Name: parent.Name}
+		}
+	}
+	return owner, true, nil
+}
@@ -0,0 +1,60 @@
+// Package heartbeatauth autentica a quien envía heartbeats y determina en
+// qué nodo corre, para que un pod cualquiera no pueda mantener online un
+// nodo caído ni falsear la telemetría de otro nodo.
+package heartbeatauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Modos de autenticación del flag --heartbeat-auth.
+const (
+	// ModeNone acepta cualquier petición, como antes de existir la
+	// autenticación.
+	ModeNone = "none"
+	// ModeTokenReview verifica el token de ServiceAccount del agente con la
+	// API TokenReview.
+	ModeTokenReview = "tokenreview"
+	// ModeHMAC verifica peticiones firmadas con una clave por nodo.
+	ModeHMAC = "hmac"
+	// ModeClientCert toma el nodo del certificado de cliente verificado
+	// en el handshake TLS.
+	ModeClientCert = "clientcert"
+)
+
+var (
+	// ErrUnauthenticated indica que la petición no trae credenciales
+	// válidas. El servidor responde 401.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrForbidden indica credenciales válidas de una identidad que no
+	// puede enviar heartbeats. El servidor responde 403.
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Identity es la identidad autenticada de quien envía un heartbeat.
+type Identity struct {
+	// NodeName es el nodo en el que corre el llamador. Solo puede enviar
+	// heartbeats de ese nodo.
+	NodeName string
+	// Subject describe la credencial para los logs, p. ej. el usuario de
+	// la ServiceAccount o "hmac:<nodo>".
+	Subject string
+}
+
+// Authenticator verifica las credenciales de una petición. body es el
+// cuerpo ya leído, necesario para verificar firmas.
+type Authenticator interface {
+	Authenticate(r *http.Request, body []byte) (Identity, error)
+}
+
+// unauthenticated envuelve un motivo en ErrUnauthenticated.
+func unauthenticated(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrUnauthenticated, fmt.Sprintf(format, args...))
+}
+
+// forbidden envuelve un motivo en ErrForbidden.
+func forbidden(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrForbidden, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,183 @@
+package heartbeatauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/jaiderssjgod/edge-operator/heartbeat"
+)
+
+// fakeReviewer responde a TokenReview con los usuarios de users.
+type fakeReviewer struct {
+	users map[string]authenticationv1.UserInfo
+	calls int
+}
+
+func (f *fakeReviewer) Review(_ context.Context, token string, _ []string) (authenticationv1.TokenReviewStatus, error) {
+	f.calls++
+	user, ok := f.users[token]
+	if !ok {
+		return authenticationv1.TokenReviewStatus{Error: "invalid token"}, nil
+	}
+	return authenticationv1.TokenReviewStatus{Authenticated: true, User: user}, nil
+}
+
+func bearer(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/heartbeat", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func TestTokenReview_ResolvesNodeFromBoundPod(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "agent-abc", Namespace: "default", UID: "uid-1"},
+		Spec:       corev1.PodSpec{NodeName: "node-1"},
+	}
+	pods := fake.NewClientBuilder().WithScheme(scheme).WithObjects(pod).Build()
+
+	agent := "system:serviceaccount:default:reduced-node-agent"
+	reviewer := &fakeReviewer{users: map[string]authenticationv1.UserInfo{
+		"agent": {Username: agent, Extra: map[string]authenticationv1.ExtraValue{
+			extraPodName: {"agent-abc"}, extraPodUID: {"uid-1"}}},
+		"signed-node": {Username: agent, Extra: map[string]authenticationv1.ExtraValue{
+			extraNodeName: {"node-2"}}},
+		"replaced": {Username: agent, Extra: map[string]authenticationv1.ExtraValue{
+			extraPodName: {"agent-abc"}, extraPodUID: {"uid-old"}}},
+		"other-sa": {Username: "system:serviceaccount:default:intruder", Extra: map[string]authenticationv1.ExtraValue{
+			extraNodeName: {"node-1"}}},
+		"user": {Username: "alice"},
+	}}
+	auth := NewTokenReview(nil, pods, heartbeat.DefaultTokenAudience, []string{"default/reduced-node-agent"})
+	auth.Reviewer = reviewer
+
+	for token, wantNode := range map[string]string{"agent": "node-1", "signed-node": "node-2"} {
+		id, err := auth.Authenticate(bearer(token), nil)
+		if err != nil || id.NodeName != wantNode || id.Subject != agent {
+			t.Errorf("%s: identidad %+v, err %v; se esperaba el nodo %s", token, id, err, wantNode)
+		}
+	}
+
+	for token, want := range map[string]error{
+		"invalid":  ErrUnauthenticated,
+		"replaced": ErrForbidden,
+		"other-sa": ErrForbidden,
+		"user":     ErrForbidden,
+	} {
+		if _, err := auth.Authenticate(bearer(token), nil); !errors.Is(err, want) {
+			t.Errorf("%s: se esperaba %v, got %v", token, want, err)
+		}
+	}
+	if _, err := auth.Authenticate(httptest.NewRequest(http.MethodPost, "/heartbeat", nil), nil); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("sin token: se esperaba ErrUnauthenticated, got %v", err)
+	}
+
+	// Una identidad verificada se reutiliza durante tokenCacheTTL
+	calls := reviewer.calls
+	if _, err := auth.Authenticate(bearer("agent"), nil); err != nil || reviewer.calls != calls {
+		t.Errorf("se esperaba la identidad de la caché (err %v, llamadas %d -> %d)", err, calls, reviewer.calls)
+	}
+	now := time.Now().Add(tokenCacheTTL)
+	auth.now = func() time.Time { return now }
+	if _, err := auth.Authenticate(bearer("agent"), nil); err != nil || reviewer.calls != calls+1 {
+		t.Errorf("una entrada caducada debe volver a revisarse (err %v, llamadas %d)", err, reviewer.calls)
+	}
+}
+
+func TestTokenReview_CacheIsBounded(t *testing.T) {
+	auth := NewTokenReview(nil, nil, heartbeat.DefaultTokenAudience, nil)
+	now := time.Now()
+	auth.now = func() time.Time { return now }
+	key := func(i int) [sha256.Size]byte { return sha256.Sum256([]byte(strconv.Itoa(i))) }
+
+	for i := 0; i < maxCachedTokens; i++ {
+		auth.remember(key(i), Identity{NodeName: "node-" + strconv.Itoa(i)})
+		now = now.Add(time.Millisecond)
+	}
+	// Con la caché llena y sin entradas caducadas se descarta la más antigua
+	auth.remember(key(maxCachedTokens), Identity{NodeName: "new"})
+	if len(auth.cache) != maxCachedTokens {
+		t.Fatalf("la caché tiene %d entradas, el máximo es %d", len(auth.cache), maxCachedTokens)
+	}
+	if _, ok := auth.cached(key(0)); ok {
+		t.Error("la entrada más antigua debería haberse descartado")
+	}
+	for _, i := range []int{1, maxCachedTokens} {
+		if _, ok := auth.cached(key(i)); !ok {
+			t.Errorf("la entrada %d debería seguir en la caché", i)
+		}
+	}
+
+	// Renovar una entrada existente no descarta otra
+	auth.remember(key(1), Identity{NodeName: "node-1"})
+	if _, ok := auth.cached(key(2)); !ok || len(auth.cache) != maxCachedTokens {
+		t.Errorf("renovar una entrada no debería descartar otras (%d entradas)", len(auth.cache))
+	}
+
+	// Si hay entradas caducadas se purgan antes que las vigentes
+	now = now.Add(tokenCacheTTL - time.Millisecond)
+	auth.remember(key(-1), Identity{NodeName: "fresh"})
+	if len(auth.cache) != 3 {
+		t.Errorf("tras purgar las caducadas se esperaban 3 entradas, got %d", len(auth.cache))
+	}
+	for _, i := range []int{-1, 1, maxCachedTokens} {
+		if _, ok := auth.cached(key(i)); !ok {
+			t.Errorf("la entrada vigente %d no debería purgarse", i)
+		}
+	}
+}
+
+func TestHMAC_VerifiesPerNodeKey(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "node-1"), []byte("secret-1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	auth := NewHMAC(dir)
+	auth.now = func() time.Time { return now }
+	body := []byte(`{"nodeName":"node-1"}`)
+
+	signed := func(node string, key []byte, ts time.Time) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/heartbeat", strings.NewReader(string(body)))
+		r.Header.Set(heartbeat.HeaderNode, node)
+		r.Header.Set(heartbeat.HeaderTimestamp, strconv.FormatInt(ts.Unix(), 10))
+		r.Header.Set(heartbeat.HeaderSignature, heartbeat.Sign(key, http.MethodPost, "/heartbeat", ts, body))
+		return r
+	}
+
+	id, err := auth.Authenticate(signed("node-1", []byte("secret-1"), now), body)
+	if err != nil || id.NodeName != "node-1" {
+		t.Fatalf("firma válida rechazada: %+v, %v", id, err)
+	}
+
+	for name, r := range map[string]*http.Request{
+		"clave ajena":    signed("node-1", []byte("secret-2"), now),
+		"nodo sin clave": signed("node-2", []byte("secret-1"), now),
+		"firma antigua":  signed("node-1", []byte("secret-1"), now.Add(-2*heartbeat.MaxSignatureAge)),
+		"ruta":           signed("../node-1", []byte("secret-1"), now),
+	} {
+		if _, err := auth.Authenticate(r, body); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("%s: se esperaba ErrUnauthenticated, got %v", name, err)
+		}
+	}
+	if _, err := auth.Authenticate(signed("node-1", []byte("secret-1"), now), []byte(`{"nodeName":"node-2"}`)); err == nil {
+		t.Error("un cuerpo modificado no debe verificar")
+	}
+}
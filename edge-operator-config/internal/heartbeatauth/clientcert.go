@@ -0,0 +1,33 @@
+package heartbeatauth
+
+import (
+	"net/http"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// nodeCertPrefix es el prefijo del CN de los certificados de kubelet. Se
+// acepta para poder reutilizar la PKI de los nodos.
+const nodeCertPrefix = "system:node:"
+
+// ClientCert autentica peticiones por el certificado de cliente que el
+// servidor verificó en el handshake (mTLS). El nodo es el Common Name del
+// sujeto, "<nodo>" o "system:node:<nodo>". Requiere que el servidor
+// verifique los certificados contra una CA de confianza.
+type ClientCert struct{}
+
+// Authenticate implementa Authenticator.
+func (ClientCert) Authenticate(r *http.Request, _ []byte) (Identity, error) {
+	// VerifiedChains solo se rellena si la CA del servidor aceptó el
+	// certificado; PeerCertificates sin verificar no valen
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Identity{}, unauthenticated("missing verified client certificate")
+	}
+	cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	node := strings.TrimPrefix(cn, nodeCertPrefix)
+	if errs := validation.IsDNS1123Subdomain(node); len(errs) > 0 {
+		return Identity{}, forbidden("certificate subject %q does not name a node", cn)
+	}
+	return Identity{NodeName: node, Subject: "x509:" + cn}, nil
+}
@@ -0,0 +1,61 @@
+package heartbeatauth
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/jaiderssjgod/edge-operator/heartbeat"
+)
+
+// HMAC autentica peticiones firmadas con heartbeat.Sign. Cada nodo tiene su
+// propia clave, en el fichero KeysDir/<nodo>, p. ej. un Secret montado
+// como volumen. El agente de cada nodo solo debe tener acceso a la suya,
+// p. ej. provisionándola en el host al dar de alta el nodo.
+type HMAC struct {
+	KeysDir string
+
+	// now se sustituye en los tests.
+	now func() time.Time
+}
+
+// NewHMAC crea un autenticador HMAC con las claves de keysDir. Las claves
+// se leen en cada petición, así que rotarlas no requiere reiniciar.
+func NewHMAC(keysDir string) *HMAC {
+	return &HMAC{KeysDir: keysDir, now: time.Now}
+}
+
+// Authenticate implementa Authenticator.
+func (h *HMAC) Authenticate(r *http.Request, body []byte) (Identity, error) {
+	node := r.Header.Get(heartbeat.HeaderNode)
+	if node == "" {
+		return Identity{}, unauthenticated("missing %s header", heartbeat.HeaderNode)
+	}
+	// El nombre se usa como nombre de fichero: nada de "../"
+	if errs := validation.IsDNS1123Subdomain(node); len(errs) > 0 {
+		return Identity{}, unauthenticated("invalid node name %q", node)
+	}
+
+	key, err := os.ReadFile(filepath.Join(h.KeysDir, node))
+	if errors.Is(err, os.ErrNotExist) {
+		return Identity{}, unauthenticated("no key for node %q", node)
+	}
+	if err != nil {
+		return Identity{}, err
+	}
+	key = bytes.TrimSpace(key)
+	if len(key) == 0 {
+		return Identity{}, unauthenticated("empty key for node %q", node)
+	}
+
+	if err := heartbeat.VerifySignature(key, r.Method, r.URL.Path,
+		r.Header.Get(heartbeat.HeaderTimestamp), r.Header.Get(heartbeat.HeaderSignature), body, h.now()); err != nil {
+		return Identity{}, unauthenticated("%v", err)
+	}
+	return Identity{NodeName: node, Subject: "hmac:" + node}, nil
+}
@@ -0,0 +1,216 @@
+package heartbeatauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Claves de User.Extra que el API server añade a los tokens de
+// ServiceAccount ligados a un pod.
+const (
+	extraPodName  = "authentication.kubernetes.io/pod-name"
+	extraPodUID   = "authentication.kubernetes.io/pod-uid"
+	extraNodeName = "authentication.kubernetes.io/node-name"
+)
+
+const serviceAccountPrefix = "system:serviceaccount:"
+
+// tokenCacheTTL es cuánto se reutiliza una identidad verificada antes de
+// volver a consultar TokenReview. Evita una llamada al API server por
+// heartbeat.
+const tokenCacheTTL = time.Minute
+
+// maxCachedTokens acota la caché; al alcanzarlo se purgan las entradas
+// caducadas y, si no queda hueco, la más antigua.
+const maxCachedTokens = 4096
+
+// TokenReviewer verifica un token con la API TokenReview.
+type TokenReviewer interface {
+	Review(ctx context.Context, token string, audiences []string) (authenticationv1.TokenReviewStatus, error)
+}
+
+// clientReviewer implementa TokenReviewer creando TokenReviews con un
+// cliente de controller-runtime.
+type clientReviewer struct {
+	c client.Client
+}
+
+func (r clientReviewer) Review(ctx context.Context, token string, audiences []string) (authenticationv1.TokenReviewStatus, error) {
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: audiences},
+	}
+	if err := r.c.Create(ctx, review); err != nil {
+		return authenticationv1.TokenReviewStatus{}, err
+	}
+	return review.Status, nil
+}
+
+// TokenReview autentica peticiones con "Authorization: Bearer <token>",
+// donde el token es un token de ServiceAccount proyectado con la audiencia
+// Audience. El nodo del llamador sale del propio token (Kubernetes 1.30+)
+// o del pod al que está ligado.
+type TokenReview struct {
+	Reviewer TokenReviewer
+	// Pods lee los pods ligados a los tokens, normalmente sin caché.
+	Pods     client.Reader
+	Audience string
+	// AllowedServiceAccounts, si no está vacío, limita los llamadores a esas
+	// ServiceAccounts, como "namespace/nombre".
+	AllowedServiceAccounts map[string]bool
+
+	// now se sustituye en los tests.
+	now func() time.Time
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]cachedIdentity
+}
+
+type cachedIdentity struct {
+	identity Identity
+	expires  time.Time
+}
+
+// NewTokenReview crea un autenticador que verifica los tokens con c y lee
+// los pods con pods.
+func NewTokenReview(c client.Client, pods client.Reader, audience string, allowed []string) *TokenReview {
+	t := &TokenReview{
+		Reviewer: clientReviewer{c: c},
+		Pods:     pods,
+		Audience: audience,
+		now:      time.Now,
+		cache:    make(map[[sha256.Size]byte]cachedIdentity),
+	}
+	if len(allowed) > 0 {
+		t.AllowedServiceAccounts = make(map[string]bool, len(allowed))
+		for _, sa := range allowed {
+			t.AllowedServiceAccounts[sa] = true
+		}
+	}
+	return t
+}
+
+// Authenticate implementa Authenticator.
+func (t *TokenReview) Authenticate(r *http.Request, _ []byte) (Identity, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return Identity{}, unauthenticated("missing bearer token")
+	}
+
+	key := sha256.Sum256([]byte(token))
+	if id, ok := t.cached(key); ok {
+		return id, nil
+	}
+
+	id, err := t.review(r.Context(), token)
+	if err != nil {
+		return Identity{}, err
+	}
+	t.remember(key, id)
+	return id, nil
+}
+
+func (t *TokenReview) review(ctx context.Context, token string) (Identity, error) {
+	status, err := t.Reviewer.Review(ctx, token, []string{t.Audience})
+	if err != nil {
+		return Identity{}, err
+	}
+	if !status.Authenticated {
+		return Identity{}, unauthenticated("token rejected: %s", status.Error)
+	}
+
+	user := status.User
+	sa, ok := strings.CutPrefix(user.Username, serviceAccountPrefix)
+	if !ok {
+		return Identity{}, forbidden("%s is not a service account", user.Username)
+	}
+	namespace, _, _ := strings.Cut(sa, ":")
+	sa = strings.Replace(sa, ":", "/", 1)
+	if t.AllowedServiceAccounts != nil && !t.AllowedServiceAccounts[sa] {
+		return Identity{}, forbidden("service account %s may not send heartbeats", sa)
+	}
+
+	// Desde Kubernetes 1.30 el token lleva el nodo firmado por el API server
+	if node := extra(user, extraNodeName); node != "" {
+		return Identity{NodeName: node, Subject: user.Username}, nil
+	}
+
+	podName, podUID := extra(user, extraPodName), extra(user, extraPodUID)
+	if podName == "" {
+		return Identity{}, forbidden("token of %s is not bound to a pod", user.Username)
+	}
+	var pod corev1.Pod
+	if err := t.Pods.Get(ctx, client.ObjectKey{Namespace: namespace, Name: podName}, &pod); err != nil {
+		if apierrors.IsNotFound(err) {
+			return Identity{}, forbidden("pod %s/%s of the token no longer exists", namespace, podName)
+		}
+		return Identity{}, err
+	}
+	// Un pod recreado con el mismo nombre no hereda los tokens del anterior
+	if podUID != "" && string(pod.UID) != podUID {
+		return Identity{}, forbidden("pod %s/%s was replaced", namespace, podName)
+	}
+	if pod.Spec.NodeName == "" {
+		return Identity{}, forbidden("pod %s/%s is not scheduled", namespace, podName)
+	}
+	return Identity{NodeName: pod.Spec.NodeName, Subject: user.Username}, nil
+}
+
+func extra(user authenticationv1.UserInfo, key string) string {
+	if v := user.Extra[key]; len(v) == 1 {
+		return v[0]
+	}
+	return ""
+}
+
+func (t *TokenReview) cached(key [sha256.Size]byte) (Identity, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.cache[key]
+	if !ok || !t.now().Before(entry.expires) {
+		return Identity{}, false
+	}
+	return entry.identity, true
+}
+
+func (t *TokenReview) remember(key [sha256.Size]byte, id Identity) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if _, ok := t.cache[key]; !ok && len(t.cache) >= maxCachedTokens {
+		t.evict(now)
+	}
+	t.cache[key] = cachedIdentity{identity: id, expires: now.Add(tokenCacheTTL)}
+}
+
+// evict purga las entradas caducadas y, si la caché sigue llena, la que
+// caduca antes, que es la más antigua porque todas comparten el TTL. Se
+// llama con mu bloqueado.
+func (t *TokenReview) evict(now time.Time) {
+	var (
+		oldest    [sha256.Size]byte
+		oldestExp time.Time
+	)
+	for k, entry := range t.cache {
+		if !now.Before(entry.expires) {
+			delete(t.cache, k)
+			continue
+		}
+		if oldestExp.IsZero() || entry.expires.Before(oldestExp) {
+			oldest, oldestExp = k, entry.expires
+		}
+	}
+	if len(t.cache) >= maxCachedTokens {
+		delete(t.cache, oldest)
+	}
+}
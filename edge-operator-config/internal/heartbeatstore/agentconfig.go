@@ -0,0 +1,67 @@
+package heartbeatstore
+
+import (
+	"sort"
+
+	"github.com/jaiderssjgod/edge-operator/heartbeat"
+)
+
+// SetAgentConfig guarda la configuración remota que la policy cfg.Policy
+// asigna al agente de nodeName. El reconciler la actualiza en cada pasada
+// y el servidor de heartbeats la devuelve al agente.
+func (s *Store) SetAgentConfig(nodeName string, cfg heartbeat.AgentConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byPolicy := s.agentConfigs[nodeName]
+	if byPolicy == nil {
+		byPolicy = make(map[string]heartbeat.AgentConfig)
+		s.agentConfigs[nodeName] = byPolicy
+	}
+	byPolicy[cfg.Policy] = cfg
+}
+
+// ForgetAgentConfig descarta la configuración de policy para nodeName, p.
+// ej. porque la policy ya no selecciona el nodo. Con nodeName vacío la
+// descarta para todos los nodos.
+func (s *Store) ForgetAgentConfig(policy, nodeName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for node, byPolicy := range s.agentConfigs {
+		if nodeName != "" && node != nodeName {
+			continue
+		}
+		delete(byPolicy, policy)
+		if len(byPolicy) == 0 {
+			delete(s.agentConfigs, node)
+		}
+	}
+}
+
+// AgentConfig devuelve la configuración remota de nodeName. Si varias
+// policies seleccionan el nodo gana la primera por nombre, para que la
+// respuesta no dependa del orden de reconciliación.
+func (s *Store) AgentConfig(nodeName string) (heartbeat.AgentConfig, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byPolicy := s.agentConfigs[nodeName]
+	if len(byPolicy) == 0 {
+		return heartbeat.AgentConfig{}, false
+	}
+	policies := make([]string, 0, len(byPolicy))
+	for name := range byPolicy {
+		policies = append(policies, name)
+	}
+	sort.Strings(policies)
+	return byPolicy[policies[0]], true
+}
+
+// Known indica si alguna policy selecciona nodeName, es decir, si tiene
+// configuración remota.
+func (s *Store) Known(nodeName string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.agentConfigs[nodeName]) > 0
+}
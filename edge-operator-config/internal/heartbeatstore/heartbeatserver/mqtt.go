@@ -0,0 +1,231 @@
+package heartbeatserver
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/go-logr/logr"
+
+	"github.com/jaiderssjgod/edge-operator/heartbeat"
+	"github.com/jaiderssjgod/edge-operator/internal/heartbeatstore"
+	"github.com/jaiderssjgod/edge-operator/internal/metrics"
+)
+
+// mqttQoS es la QoS de la suscripción: el broker reintenta cada heartbeat
+// hasta que el operador lo confirma.
+const mqttQoS = 1
+
+// MQTTOptions configura la suscripción a un broker MQTT.
+type MQTTOptions struct {
+	// Broker es la URL del broker, p. ej. tcp://mosquitto:1883 o
+	// ssl://mosquitto:8883.
+	Broker string
+	// TopicPrefix es la raíz del árbol de topics; vacío usa
+	// heartbeat.DefaultMQTTTopicPrefix.
+	TopicPrefix string
+	// ClientID identifica al operador ante el broker. Cada réplica necesita
+	// el suyo: el broker desconecta al cliente anterior con el mismo ID.
+	ClientID string
+	// Username y Password, si no están vacíos, autentican ante el broker.
+	Username string
+	Password string
+	// CAFile es el bundle de CAs que verifica un broker ssl://; vacío usa
+	// las CAs del sistema.
+	CAFile string
+}
+
+// MQTTSubscriber recibe los heartbeats que los agentes publican en un
+// broker MQTT y los registra en el store como los del endpoint HTTP. No
+// hay respuesta, así que los agentes MQTT no reciben configuración remota.
+//
+// El nodo de cada mensaje es el de su topic. El operador no autentica a
+// quien publica: las ACL del broker deben limitar cada agente a sus topics.
+type MQTTSubscriber struct {
+	store  *heartbeatstore.Store
+	log    logr.Logger
+	prefix string
+	client mqtt.Client
+}
+
+// NewMQTT crea un MQTTSubscriber que almacena en store.
+func NewMQTT(opts MQTTOptions, store *heartbeatstore.Store, log logr.Logger) (*MQTTSubscriber, error) {
+	if opts.Broker == "" {
+		return nil, errors.New("MQTT requires a broker URL")
+	}
+	if opts.ClientID == "" {
+		return nil, errors.New("MQTT requires a client ID")
+	}
+	m := &MQTTSubscriber{
+		store:  store,
+		log:    log.WithValues("broker", opts.Broker),
+		prefix: opts.TopicPrefix,
+	}
+	if m.prefix == "" {
+		m.prefix = heartbeat.DefaultMQTTTopicPrefix
+	}
+	tlsConfig, err := brokerTLSConfig(opts.Broker, opts.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	clientOpts := mqtt.NewClientOptions().
+		AddBroker(opts.Broker).
+		SetClientID(opts.ClientID).
+		SetUsername(opts.Username).
+		SetPassword(opts.Password).
+		SetTLSConfig(tlsConfig).
+		// Los heartbeats perdidos con el operador caído no se reclaman: el
+		// siguiente de cada agente basta
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		// Una sesión limpia pierde las suscripciones: se renuevan en cada
+		// conexión
+		SetOnConnectHandler(m.subscribe).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			m.log.Error(err, "Lost connection to the MQTT broker, reconnecting")
+		})
+	m.client = mqtt.NewClient(clientOpts)
+	return m, nil
+}
+
+// Start conecta con el broker, reintentando hasta conseguirlo. Bloquea
+// hasta entonces; se debe invocar con `go`. Después el cliente reconecta
+// solo si se pierde la conexión.
+func (m *MQTTSubscriber) Start() {
+	m.log.Info("Connecting to the MQTT broker", "topics", m.filters())
+	if token := m.client.Connect(); token.Wait() && token.Error() != nil {
+		m.log.Error(token.Error(), "Unable to connect to the MQTT broker")
+	}
+}
+
+// Stop se desconecta del broker.
+func (m *MQTTSubscriber) Stop() {
+	m.client.Disconnect(250)
+}
+
+// brokerTLSConfig devuelve la configuración TLS de broker, o nil si no usa
+// TLS. El cliente MQTT no deduce el nombre del servidor de la URL.
+func brokerTLSConfig(broker, caFile string) (*tls.Config, error) {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT broker URL: %w", err)
+	}
+	switch u.Scheme {
+	case "ssl", "tls", "mqtts", "tcps", "wss":
+	default:
+		if caFile != "" {
+			return nil, fmt.Errorf("a broker CA file needs a TLS broker URL, got %s", broker)
+		}
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: u.Hostname()}
+	if caFile != "" {
+		if cfg.RootCAs, err = loadCertPool(caFile); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// filters son los filtros de la suscripción: heartbeats y replays de
+// cualquier nodo.
+func (m *MQTTSubscriber) filters() map[string]byte {
+	return map[string]byte{
+		heartbeat.MQTTTopic(m.prefix, "+"):       mqttQoS,
+		heartbeat.MQTTReplayTopic(m.prefix, "+"): mqttQoS,
+	}
+}
+
+func (m *MQTTSubscriber) subscribe(c mqtt.Client) {
+	token := c.SubscribeMultiple(m.filters(), func(_ mqtt.Client, msg mqtt.Message) {
+		m.handle(msg.Topic(), msg.Payload())
+	})
+	if token.Wait() && token.Error() != nil {
+		m.log.Error(token.Error(), "Failed to subscribe to heartbeat topics")
+		return
+	}
+	m.log.Info("Subscribed to heartbeat topics")
+}
+
+// handle procesa un mensaje publicado en topic. Los mensajes inválidos se
+// descartan: no hay a quién responder.
+func (m *MQTTSubscriber) handle(topic string, body []byte) {
+	node, replay, ok := heartbeat.ParseMQTTTopic(m.prefix, topic)
+	if !ok {
+		m.log.Info("Ignored message outside the heartbeat topic tree", "topic", topic)
+		return
+	}
+	if replay {
+		m.handleReplay(node, body)
+		return
+	}
+
+	var payload heartbeat.Payload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		m.log.Info("Discarded undecodable heartbeat", "topic", topic, "reason", err.Error())
+		return
+	}
+	if payload.NodeName == "" {
+		payload.NodeName = node
+	}
+	if payload.NodeName != node {
+		m.log.Info("Discarded heartbeat for another node", "topic", topic, "claimedNode", payload.NodeName)
+		return
+	}
+	if err := payload.Normalize(); err != nil {
+		m.log.Info("Rejected invalid heartbeat payload", "node", node, "reason", err.Error())
+		return
+	}
+
+	if !m.store.Record(payload) {
+		m.log.V(1).Info("Ignored heartbeat not newer than the last one", "node", node, "ts", payload.Timestamp, "transport", "mqtt")
+		return
+	}
+	reportDroppedTelemetry(m.log, m.store, payload)
+	metrics.HeartbeatsReceived.WithLabelValues(metrics.NodeLabel(m.store, node)).Inc()
+	m.log.V(1).Info("Heartbeat received",
+		"node", node, "ts", payload.Timestamp, "version", payload.Version, "transport", "mqtt")
+}
+
+// handleReplay procesa un ReplayRequest publicado en el topic de replay de
+// node, con las mismas reglas que POST /heartbeat/replay.
+func (m *MQTTSubscriber) handleReplay(node string, body []byte) {
+	var req heartbeat.ReplayRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		m.log.Info("Discarded undecodable replay", "node", node, "reason", err.Error())
+		return
+	}
+	if req.NodeName != "" && req.NodeName != node {
+		m.log.Info("Discarded replay for another node", "node", node, "claimedNode", req.NodeName)
+		return
+	}
+	if len(req.Samples) == 0 {
+		return
+	}
+	for i := range req.Samples {
+		sample := &req.Samples[i]
+		if sample.NodeName == "" {
+			sample.NodeName = node
+		}
+		if sample.NodeName != node {
+			m.log.Info("Discarded replay with a sample of another node", "node", node, "sample", i)
+			return
+		}
+		if err := sample.Normalize(); err != nil {
+			m.log.Info("Discarded replay with an invalid sample", "node", node, "sample", i, "reason", err.Error())
+			return
+		}
+		reportDroppedTelemetry(m.log, m.store, *sample)
+	}
+
+	window := m.store.RecordReplay(node, req.Samples)
+	m.log.Info("Replayed heartbeats received",
+		"node", node, "samples", window.Samples, "from", window.From, "to", window.To, "transport", "mqtt")
+}
@@ -0,0 +1,86 @@
+package heartbeatserver
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/go-logr/logr"
+	mqttbroker "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+
+	"github.com/jaiderssjgod/edge-operator/heartbeat"
+	"github.com/jaiderssjgod/edge-operator/internal/heartbeatstore"
+)
+
+// startBroker arranca un broker MQTT embebido en un puerto libre y lo
+// devuelve junto con su URL.
+func startBroker(t *testing.T) (*mqttbroker.Server, string) {
+	t.Helper()
+	broker := mqttbroker.New(nil)
+	if err := broker.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	if err := broker.AddListener(tcp); err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { broker.Close() })
+	return broker, "tcp://" + tcp.Address()
+}
+
+func publish(t *testing.T, c paho.Client, topic string, v any) {
+	t.Helper()
+	body, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token := c.Publish(topic, 1, false, body); token.Wait() && token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+}
+
+func TestMQTTSubscriber_RecordsHeartbeatsFromTheTopicTree(t *testing.T) {
+	broker, url := startBroker(t)
+	store := heartbeatstore.New(time.Minute)
+	sub, err := NewMQTT(MQTTOptions{Broker: url, ClientID: "operator"}, store, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.Start()
+	defer sub.Stop()
+	waitFor(t, "la suscripción", func() bool {
+		return len(broker.Topics.Subscribers(heartbeat.MQTTReplayTopic(heartbeat.DefaultMQTTTopicPrefix, "n1")).Subscriptions) > 0
+	})
+
+	agent := paho.NewClient(paho.NewClientOptions().AddBroker(url).SetClientID("agent-n1"))
+	if token := agent.Connect(); token.Wait() && token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+	defer agent.Disconnect(250)
+
+	// Un heartbeat que dice ser de otro nodo se descarta; el nodo lo da el topic
+	publish(t, agent, heartbeat.MQTTTopic(heartbeat.DefaultMQTTTopicPrefix, "n1"),
+		heartbeat.Payload{Version: heartbeat.CurrentVersion, NodeName: "n2", Timestamp: time.Now().UTC()})
+	publish(t, agent, heartbeat.MQTTTopic(heartbeat.DefaultMQTTTopicPrefix, "n1"),
+		heartbeat.Payload{Version: heartbeat.CurrentVersion, Timestamp: time.Now().UTC(),
+			Resources: &heartbeat.Resources{CPUPercent: 12.5}})
+	waitFor(t, "el heartbeat de n1", func() bool { return !store.GetNodeState("n1").Offline })
+	if got := store.Snapshot()["n1"]; got.Resources == nil || got.Resources.CPUPercent != 12.5 {
+		t.Errorf("heartbeat registrado = %+v, se esperaba el de CPU 12.5", got)
+	}
+	if _, ok := store.Snapshot()["n2"]; ok {
+		t.Error("un heartbeat publicado en el topic de n1 no debe registrar n2")
+	}
+
+	before := time.Now()
+	sample := heartbeat.Payload{Version: heartbeat.CurrentVersion, Timestamp: before.Add(-time.Minute).UTC()}
+	publish(t, agent, heartbeat.MQTTReplayTopic(heartbeat.DefaultMQTTTopicPrefix, "n1"),
+		heartbeat.ReplayRequest{NodeName: "n1", Samples: []heartbeat.Payload{sample}})
+	waitFor(t, "el replay de n1", func() bool { return len(store.ReplaysSince("n1", before)) == 1 })
+}
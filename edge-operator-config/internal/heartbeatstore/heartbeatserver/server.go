@@ -1,17 +1,28 @@
 // internal/heartbeatserver/server.go
 // HeartbeatServer expone un endpoint HTTP POST /heartbeat que los agentes
-// llaman periódicamente. Registra cada payload en el HeartbeatStore.
+// llaman periódicamente. Registra cada payload en el HeartbeatStore y
+// responde con la configuración remota del agente. POST /heartbeat/batch
+// acepta los heartbeats de varios nodos en una sola petición.
 package heartbeatserver
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/jaiderssjgod/edge-operator/heartbeat"
+	"github.com/jaiderssjgod/edge-operator/internal/heartbeatauth"
 	"github.com/jaiderssjgod/edge-operator/internal/heartbeatstore"
+	"github.com/jaiderssjgod/edge-operator/internal/metrics"
 )
 
 // Server es el servidor HTTP que recibe heartbeats.
@@ -20,6 +31,14 @@ type Server struct {
 	log    logr.Logger
 	addr   string
 	server *http.Server
+
+	// Authenticator verifica quién envía cada petición y en qué nodo corre.
+	// Si es nil se acepta cualquier petición.
+	Authenticator heartbeatauth.Authenticator
+	// Relays asigna a cada nodo relay, p. ej. la pasarela de un sitio remoto,
+	// los nodos vecinos de los que puede enviar heartbeats. Un relay no puede
+	// reportar nodos fuera de su lista. Solo se aplica con Authenticator.
+	Relays map[string]map[string]bool
 }
 
 // New crea un Server que escucha en addr y almacena en store.
@@ -31,7 +50,9 @@ func New(addr string, store *heartbeatstore.Store, log logr.Logger) *Server {
 	}
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/heartbeat", s.handleHeartbeat)
+	mux.Handle("/heartbeat", instrument("/heartbeat", s.handleHeartbeat))
+	mux.Handle("/heartbeat/replay", instrument("/heartbeat/replay", s.handleReplay))
+	mux.Handle("/heartbeat/batch", instrument("/heartbeat/batch", s.handleBatch))
 
 	s.server = &http.Server{
 		Addr:    addr,
@@ -43,9 +64,18 @@ func New(addr string, store *heartbeatstore.Store, log logr.Logger) *Server {
 
 // Start arranca el servidor HTTP en una goroutine.
 // Llama a s.server.ListenAndServe, que bloquea; se debe invocar con `go`.
+// Tras EnableTLS sirve HTTPS.
 func (s *Server) Start() {
-	s.log.Info("Starting heartbeat HTTP server", "addr", s.addr)
-	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	var err error
+	if s.server.TLSConfig != nil {
+		s.log.Info("Starting heartbeat HTTPS server", "addr", s.addr)
+		// Los certificados los entrega TLSConfig.GetCertificate
+		err = s.server.ListenAndServeTLS("", "")
+	} else {
+		s.log.Info("Starting heartbeat HTTP server", "addr", s.addr)
+		err = s.server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		s.log.Error(err, "Heartbeat server stopped unexpectedly")
 	}
 }
@@ -57,21 +87,303 @@ func (s *Server) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	body, id, ok := s.authenticate(w, r, maxHeartbeatBytes)
+	if !ok {
+		return
+	}
+
 	var payload heartbeat.Payload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+	if err := json.Unmarshal(body, &payload); err != nil {
 		s.log.Error(err, "Failed to decode heartbeat payload")
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
+	if !s.authorizeNode(w, id, payload.NodeName) {
+		return
+	}
+
+	// Normalize rechaza valores ilegibles o fuera de rango y convierte los
+	// payloads v1 a la forma tipada
+	if err := payload.Normalize(); err != nil {
+		s.log.Info("Rejected invalid heartbeat payload", "node", payload.NodeName, "reason", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// La respuesta lleva la configuración de la policy que selecciona el
+	// nodo; los agentes antiguos solo miran el código de estado
+	resp := heartbeat.Response{Status: heartbeat.StatusOK, Config: s.record(payload)}
+	s.writeJSON(w, resp)
+}
 
-	if payload.NodeName == "" {
+// record registra un heartbeat ya validado y devuelve la configuración
+// remota de su nodo, o nil si ninguna policy lo selecciona.
+func (s *Server) record(payload heartbeat.Payload) *heartbeat.AgentConfig {
+	if s.store.Record(payload) {
+		reportDroppedTelemetry(s.log, s.store, payload)
+		metrics.HeartbeatsReceived.WithLabelValues(metrics.NodeLabel(s.store, payload.NodeName)).Inc()
+		s.log.V(1).Info("Heartbeat received",
+			"node", payload.NodeName, "ts", payload.Timestamp, "version", payload.Version)
+	} else {
+		s.log.V(1).Info("Ignored heartbeat not newer than the last one", "node", payload.NodeName, "ts", payload.Timestamp)
+	}
+
+	if cfg, ok := s.store.AgentConfig(payload.NodeName); ok {
+		return &cfg
+	}
+	return nil
+}
+
+// maxBatchItems limita los heartbeats de un lote.
+const maxBatchItems = 1000
+
+// maxBatchBytes limita el cuerpo de un lote, ya descomprimido.
+const maxBatchBytes = 8 << 20
+
+// handleBatch procesa POST /heartbeat/batch. Cada heartbeat se valida por
+// separado: uno inválido no impide registrar el resto, y su resultado
+// explica el motivo.
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, id, ok := s.authenticate(w, r, maxBatchBytes)
+	if !ok {
+		return
+	}
+
+	var req heartbeat.BatchRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.log.Error(err, "Failed to decode heartbeat batch")
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if len(req.Heartbeats) > maxBatchItems {
+		http.Error(w, fmt.Sprintf("batch has %d heartbeats, the maximum is %d", len(req.Heartbeats), maxBatchItems),
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	resp := heartbeat.BatchResponse{Results: make([]heartbeat.BatchResult, len(req.Heartbeats))}
+	accepted := 0
+	for i, payload := range req.Heartbeats {
+		result := &resp.Results[i]
+		result.NodeName = payload.NodeName
+		if err := payload.Normalize(); err != nil {
+			result.Status, result.Error = heartbeat.StatusRejected, err.Error()
+			continue
+		}
+		if !s.mayReport(id, payload.NodeName) {
+			result.Status = heartbeat.StatusForbidden
+			result.Error = fmt.Sprintf("caller runs on node %q, not %q", id.NodeName, payload.NodeName)
+			continue
+		}
+		result.Status, result.Config = heartbeat.StatusOK, s.record(payload)
+		accepted++
+	}
+	if accepted < len(req.Heartbeats) {
+		s.log.Info("Heartbeat batch partially rejected",
+			"subject", id.Subject, "heartbeats", len(req.Heartbeats), "accepted", accepted)
+	}
+	s.writeJSON(w, resp)
+}
+
+// writeJSON responde 200 con v codificado en JSON.
+func (s *Server) writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.log.Error(err, "Failed to write response")
+	}
+}
+
+// maxHeartbeatBytes limita el cuerpo de un heartbeat.
+const maxHeartbeatBytes = 1 << 20
+
+// maxReplayBytes limita el cuerpo de un replay: unas horas de muestras.
+const maxReplayBytes = 8 << 20
+
+// handleReplay procesa POST /heartbeat/replay: las muestras que el agente
+// acumuló mientras el operador no era alcanzable.
+func (s *Server) handleReplay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, id, ok := s.authenticate(w, r, maxReplayBytes)
+	if !ok {
+		return
+	}
+
+	var req heartbeat.ReplayRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		s.log.Error(err, "Failed to decode replay payload")
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.NodeName == "" {
 		http.Error(w, "nodeName is required", http.StatusBadRequest)
 		return
 	}
+	if !s.authorizeNode(w, id, req.NodeName) {
+		return
+	}
+	if len(req.Samples) == 0 {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+		return
+	}
+
+	for i := range req.Samples {
+		sample := &req.Samples[i]
+		if sample.NodeName == "" {
+			sample.NodeName = req.NodeName
+		}
+		if sample.NodeName != req.NodeName {
+			http.Error(w, fmt.Sprintf("sample %d belongs to node %q", i, sample.NodeName), http.StatusBadRequest)
+			return
+		}
+		if err := sample.Normalize(); err != nil {
+			http.Error(w, fmt.Sprintf("sample %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+		reportDroppedTelemetry(s.log, s.store, *sample)
+	}
 
-	s.store.Record(payload)
-	s.log.V(1).Info("Heartbeat received", "node", payload.NodeName, "ts", payload.Timestamp)
+	window := s.store.RecordReplay(req.NodeName, req.Samples)
+	s.log.Info("Replayed heartbeats received",
+		"node", req.NodeName, "samples", window.Samples, "from", window.From, "to", window.To)
 
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintln(w, "ok")
 }
+
+// authenticate lee el cuerpo de r, hasta limit bytes, y verifica sus
+// credenciales. Las firmas cubren el cuerpo tal como se envió; después se
+// descomprime si viene con Content-Encoding: gzip. Si falla ya ha
+// respondido y devuelve ok=false.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request, limit int64) (body []byte, id heartbeatauth.Identity, ok bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		s.log.Error(err, "Failed to read request body", "path", r.URL.Path)
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return nil, id, false
+	}
+	if s.Authenticator != nil {
+		if id, err = s.Authenticator.Authenticate(r, body); err != nil {
+			s.rejectCredentials(w, r, err)
+			return nil, id, false
+		}
+	}
+
+	switch encoding := r.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+	case "gzip":
+		if body, err = gunzip(body, limit); err != nil {
+			s.log.Info("Rejected compressed request", "path", r.URL.Path, "reason", err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, id, false
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unsupported Content-Encoding %q", encoding), http.StatusUnsupportedMediaType)
+		return nil, id, false
+	}
+	return body, id, true
+}
+
+// gunzip descomprime body, que no puede superar limit bytes descomprimido.
+func gunzip(body []byte, limit int64) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip body: %w", err)
+	}
+	defer zr.Close()
+	out, err := io.ReadAll(io.LimitReader(zr, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip body: %w", err)
+	}
+	if int64(len(out)) > limit {
+		return nil, fmt.Errorf("decompressed body exceeds %d bytes", limit)
+	}
+	return out, nil
+}
+
+// rejectCredentials responde al error err de Authenticator.
+func (s *Server) rejectCredentials(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, heartbeatauth.ErrUnauthenticated):
+		s.log.Info("Rejected unauthenticated request", "path", r.URL.Path, "remote", r.RemoteAddr, "reason", err.Error())
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	case errors.Is(err, heartbeatauth.ErrForbidden):
+		s.log.Info("Rejected unauthorized request", "path", r.URL.Path, "remote", r.RemoteAddr, "reason", err.Error())
+		http.Error(w, "forbidden", http.StatusForbidden)
+	default:
+		s.log.Error(err, "Failed to authenticate request", "path", r.URL.Path)
+		http.Error(w, "authentication unavailable", http.StatusServiceUnavailable)
+	}
+}
+
+// authorizeNode comprueba que la identidad autenticada puede reportar
+// nodeName y, si no, responde 403.
+func (s *Server) authorizeNode(w http.ResponseWriter, id heartbeatauth.Identity, nodeName string) bool {
+	if s.mayReport(id, nodeName) {
+		return true
+	}
+	s.log.Info("Rejected heartbeat for another node",
+		"subject", id.Subject, "callerNode", id.NodeName, "claimedNode", nodeName)
+	http.Error(w, fmt.Sprintf("caller runs on node %q, not %q", id.NodeName, nodeName), http.StatusForbidden)
+	return false
+}
+
+// mayReport indica si la identidad autenticada puede reportar nodeName:
+// corre en ese nodo o es un relay con nodeName entre sus vecinos. Sin
+// Authenticator no hay nada que comprobar.
+func (s *Server) mayReport(id heartbeatauth.Identity, nodeName string) bool {
+	return s.Authenticator == nil || id.NodeName == nodeName || s.Relays[id.NodeName][nodeName]
+}
+
+// ParseRelays interpreta la lista de relays de --heartbeat-relay-nodes:
+// "<relay>=<vecino>;<vecino>,<relay>=<vecino>", p. ej.
+// "gateway-1=sensor-a;sensor-b,gateway-2=sensor-c".
+func ParseRelays(spec string) (map[string]map[string]bool, error) {
+	relays := make(map[string]map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		relay, neighbors, ok := strings.Cut(entry, "=")
+		relay = strings.TrimSpace(relay)
+		if !ok || relay == "" {
+			return nil, fmt.Errorf("relay %q must list its neighbor nodes as <relay>=<node>;<node>", entry)
+		}
+		if relays[relay] == nil {
+			relays[relay] = make(map[string]bool)
+		}
+		for _, node := range strings.Split(neighbors, ";") {
+			if node = strings.TrimSpace(node); node == "" {
+				return nil, fmt.Errorf("relay %q has an empty neighbor node", relay)
+			}
+			relays[relay][node] = true
+		}
+	}
+	return relays, nil
+}
+
+// reportDroppedTelemetry registra que Normalize aceptó payload solo como
+// señal de vida porque su telemetría era ilegible.
+func reportDroppedTelemetry(log logr.Logger, store *heartbeatstore.Store, payload heartbeat.Payload) {
+	if payload.DroppedTelemetry == "" {
+		return
+	}
+	metrics.HeartbeatTelemetryDropped.WithLabelValues(metrics.NodeLabel(store, payload.NodeName)).Inc()
+	log.Info("Dropped unreadable heartbeat telemetry, keeping the heartbeat as liveness",
+		"node", payload.NodeName, "reason", payload.DroppedTelemetry)
+}
+
+// instrument mide la latencia de handler en edge_heartbeat_request_duration_seconds.
+func instrument(path string, handler http.HandlerFunc) http.Handler {
+	return promhttp.InstrumentHandlerDuration(
+		metrics.HeartbeatRequestDuration.MustCurryWith(prometheus.Labels{"path": path}),
+		handler,
+	)
+}
@@ -0,0 +1,304 @@
+package heartbeatserver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/jaiderssjgod/edge-operator/heartbeat"
+	"github.com/jaiderssjgod/edge-operator/internal/heartbeatauth"
+	"github.com/jaiderssjgod/edge-operator/internal/heartbeatstore"
+)
+
+func TestHandleHeartbeat_AcceptsV1AndV2RejectsGarbage(t *testing.T) {
+	store := heartbeatstore.New(time.Minute)
+	s := New(":0", store, logr.Discard())
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	cases := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"v1", `{"nodeName":"n1","timestamp":"` + now + `","cpu":"85.41%","memory":"40.00%"}`, http.StatusOK},
+		{"v2", `{"version":2,"nodeName":"n2","timestamp":"` + now + `","resources":{"cpuPercent":10,"memoryUsedBytes":1,"memoryTotalBytes":4}}`, http.StatusOK},
+		{"fuera de rango", `{"version":2,"nodeName":"n3","timestamp":"` + now + `","resources":{"cpuPercent":140}}`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/heartbeat", strings.NewReader(tc.body))
+		s.server.Handler.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: status %d, se esperaba %d (%s)", tc.name, rec.Code, tc.want, rec.Body.String())
+		}
+	}
+
+	if got := store.GetNodeState("n1").Resources.CPUPercent; got != 85.41 {
+		t.Errorf("cpu de n1 = %v, se esperaba 85.41", got)
+	}
+	if got := store.GetNodeState("n2").Resources.MemoryPercent; got != 25 {
+		t.Errorf("memoria de n2 = %v, se esperaba 25", got)
+	}
+	if _, ok := store.Snapshot()["n3"]; ok {
+		t.Error("un payload inválido no debe registrarse")
+	}
+}
+
+func TestHandleHeartbeat_RespondsWithAgentConfig(t *testing.T) {
+	store := heartbeatstore.New(time.Minute)
+	store.SetAgentConfig("n1", heartbeat.AgentConfig{Policy: "policy-b", HeartbeatIntervalSeconds: 20})
+	store.SetAgentConfig("n1", heartbeat.AgentConfig{Policy: "policy-a", HeartbeatIntervalSeconds: 30})
+	s := New(":0", store, logr.Discard())
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	post := func(node string) heartbeat.Response {
+		rec := httptest.NewRecorder()
+		body := `{"version":2,"nodeName":"` + node + `","timestamp":"` + now + `"}`
+		s.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/heartbeat", strings.NewReader(body)))
+		var resp heartbeat.Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("respuesta no JSON para %s: %v (%s)", node, err, rec.Body.String())
+		}
+		return resp
+	}
+
+	// Con varias policies gana la primera por nombre
+	if resp := post("n1"); resp.Status != heartbeat.StatusOK || resp.Config == nil ||
+		resp.Config.Policy != "policy-a" || resp.Config.HeartbeatIntervalSeconds != 30 {
+		t.Errorf("respuesta inesperada para n1: %+v", resp)
+	}
+	if resp := post("n2"); resp.Status != heartbeat.StatusOK || resp.Config != nil {
+		t.Errorf("un nodo sin policy no debe recibir configuración: %+v", resp)
+	}
+}
+
+func TestHandleHeartbeat_V1WithBrokenSensorKeepsNodeOnline(t *testing.T) {
+	store := heartbeatstore.New(time.Minute)
+	s := New(":0", store, logr.Discard())
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	// Lo que envía el agente v1 cuando falla el sensor de CPU, o cuando lee
+	// un valor imposible
+	for node, cpu := range map[string]string{"n1": "unavailable", "n2": "140%"} {
+		body := `{"nodeName":"` + node + `","timestamp":"` + now + `","cpu":"` + cpu + `","memory":"40.00%"}`
+		rec := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/heartbeat", strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("cpu %q: status %d, se esperaba 200 (%s)", cpu, rec.Code, rec.Body.String())
+		}
+		if store.GetNodeState(node).Offline {
+			t.Errorf("cpu %q: un nodo vivo con un sensor roto no debe quedar offline", cpu)
+		}
+		if got := store.Snapshot()[node]; got.Resources != nil {
+			t.Errorf("cpu %q: la telemetría inválida debía descartarse: %+v", cpu, got.Resources)
+		}
+	}
+}
+
+func TestHandleReplay_ValidatesEverySample(t *testing.T) {
+	store := heartbeatstore.New(time.Minute)
+	s := New(":0", store, logr.Discard())
+	ts := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+
+	cases := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"válido", `{"nodeName":"n1","samples":[{"version":2,"timestamp":"` + ts + `","resources":{"cpuPercent":90,"memoryPercent":10}}]}`, http.StatusOK},
+		{"otro nodo", `{"nodeName":"n2","samples":[{"version":2,"nodeName":"n1","timestamp":"` + ts + `"}]}`, http.StatusBadRequest},
+		{"fuera de rango", `{"nodeName":"n3","samples":[{"version":2,"nodeName":"n3","timestamp":"` + ts + `","resources":{"cpuPercent":140}}]}`, http.StatusBadRequest},
+		{"sin nodo", `{"samples":[]}`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/heartbeat/replay", strings.NewReader(tc.body))
+		s.server.Handler.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: status %d, se esperaba %d (%s)", tc.name, rec.Code, tc.want, rec.Body.String())
+		}
+	}
+
+	since := time.Now().Add(-time.Hour)
+	if got := store.ReplaysSince("n1", since); len(got) != 1 || got[0].PeakCPU != 90 {
+		t.Errorf("replay de n1 inesperado: %+v", got)
+	}
+	for _, node := range []string{"n2", "n3"} {
+		if got := store.ReplaysSince(node, since); len(got) != 0 {
+			t.Errorf("un replay inválido de %s no debe registrarse: %+v", node, got)
+		}
+	}
+	// Un replay no cuenta como heartbeat en vivo
+	if _, ok := store.Snapshot()["n1"]; ok {
+		t.Error("el replay no debe actualizar el último heartbeat")
+	}
+}
+
+// staticAuth autentica como node-1 a quien presente el token "node-1".
+type staticAuth struct{}
+
+func (staticAuth) Authenticate(r *http.Request, _ []byte) (heartbeatauth.Identity, error) {
+	if r.Header.Get("Authorization") != "Bearer node-1" {
+		return heartbeatauth.Identity{}, heartbeatauth.ErrUnauthenticated
+	}
+	return heartbeatauth.Identity{NodeName: "node-1", Subject: "test"}, nil
+}
+
+func TestHandleHeartbeat_ReplayedRequestDoesNotOverwriteNewerData(t *testing.T) {
+	store := heartbeatstore.New(time.Minute)
+	s := New(":0", store, logr.Discard())
+	now := time.Now().UTC().Truncate(time.Second)
+
+	post := func(ts time.Time, cpu string) {
+		body := `{"version":2,"nodeName":"n1","timestamp":"` + ts.Format(time.RFC3339) + `","resources":{"cpuPercent":` + cpu + `}}`
+		rec := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/heartbeat", strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d, se esperaba 200 (%s)", rec.Code, rec.Body.String())
+		}
+	}
+
+	// Una petición capturada se reenvía tras otra más nueva y tras romperse
+	// el stream del agente
+	post(now.Add(-30*time.Second), "90")
+	post(now, "10")
+	post(now.Add(-30*time.Second), "90")
+	store.Disconnect("n1")
+	post(now, "10")
+
+	state := store.GetNodeState("n1")
+	if !state.LastHeartbeat.Equal(now) || state.Resources.CPUPercent != 10 {
+		t.Errorf("se esperaba conservar el heartbeat más nuevo, got %+v", state)
+	}
+	if !state.Disconnected {
+		t.Error("un heartbeat reenviado no debe deshacer la desconexión")
+	}
+}
+
+func TestHandleHeartbeat_OnlyAcceptsCallerOwnNode(t *testing.T) {
+	store := heartbeatstore.New(time.Minute)
+	s := New(":0", store, logr.Discard())
+	s.Authenticator = staticAuth{}
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	cases := []struct {
+		name, path, token, body string
+		want                    int
+	}{
+		{"propio nodo", "/heartbeat", "node-1", `{"version":2,"nodeName":"node-1","timestamp":"` + now + `"}`, http.StatusOK},
+		{"otro nodo", "/heartbeat", "node-1", `{"version":2,"nodeName":"node-2","timestamp":"` + now + `"}`, http.StatusForbidden},
+		{"sin credenciales", "/heartbeat", "", `{"version":2,"nodeName":"node-1","timestamp":"` + now + `"}`, http.StatusUnauthorized},
+		{"replay de otro nodo", "/heartbeat/replay", "node-1", `{"nodeName":"node-2","samples":[{"version":2,"timestamp":"` + now + `"}]}`, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		s.server.Handler.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: status %d, se esperaba %d (%s)", tc.name, rec.Code, tc.want, rec.Body.String())
+		}
+	}
+	if _, ok := store.Snapshot()["node-2"]; ok {
+		t.Error("un heartbeat suplantado no debe registrarse")
+	}
+}
+
+func TestHandleBatch_GzipWithPerItemResults(t *testing.T) {
+	store := heartbeatstore.New(time.Minute)
+	store.SetAgentConfig("node-2", heartbeat.AgentConfig{Policy: "p", HeartbeatIntervalSeconds: 30})
+	s := New(":0", store, logr.Discard())
+	s.Authenticator = staticAuth{}
+	now := time.Now().UTC().Format(time.RFC3339)
+	body := `{"heartbeats":[` +
+		`{"version":2,"nodeName":"node-1","timestamp":"` + now + `"},` +
+		`{"version":2,"nodeName":"node-2","timestamp":"` + now + `"},` +
+		`{"version":2,"nodeName":"node-3","timestamp":"` + now + `","resources":{"cpuPercent":140}}]}`
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(body))
+	zw.Close()
+
+	post := func() (int, heartbeat.BatchResponse) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/heartbeat/batch", bytes.NewReader(gz.Bytes()))
+		req.Header.Set("Authorization", "Bearer node-1")
+		req.Header.Set("Content-Encoding", "gzip")
+		s.server.Handler.ServeHTTP(rec, req)
+		var resp heartbeat.BatchResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp
+	}
+	statuses := func(resp heartbeat.BatchResponse) []string {
+		var out []string
+		for _, r := range resp.Results {
+			out = append(out, r.NodeName+"="+r.Status)
+		}
+		return out
+	}
+
+	// Sin ser relay, node-1 solo puede reportarse a sí mismo
+	code, resp := post()
+	want := []string{"node-1=ok", "node-2=forbidden", "node-3=rejected"}
+	if code != http.StatusOK || !reflect.DeepEqual(statuses(resp), want) {
+		t.Fatalf("status %d, resultados %v; se esperaba 200 y %v", code, statuses(resp), want)
+	}
+	if _, ok := store.Snapshot()["node-2"]; ok {
+		t.Error("un heartbeat prohibido no debe registrarse")
+	}
+
+	// Un relay solo reporta los vecinos de su lista
+	s.Relays = map[string]map[string]bool{"node-1": {"node-4": true}}
+	code, resp = post()
+	if code != http.StatusOK || !reflect.DeepEqual(statuses(resp), want) {
+		t.Fatalf("como relay de otro vecino: status %d, resultados %v; se esperaba 200 y %v", code, statuses(resp), want)
+	}
+
+	s.Relays = map[string]map[string]bool{"node-1": {"node-2": true}}
+	code, resp = post()
+	want = []string{"node-1=ok", "node-2=ok", "node-3=rejected"}
+	if code != http.StatusOK || !reflect.DeepEqual(statuses(resp), want) {
+		t.Fatalf("como relay: status %d, resultados %v; se esperaba 200 y %v", code, statuses(resp), want)
+	}
+	if cfg := resp.Results[1].Config; cfg == nil || cfg.HeartbeatIntervalSeconds != 30 {
+		t.Errorf("configuración de node-2 = %+v, se esperaba la de la policy", cfg)
+	}
+	if resp.Results[2].Error == "" {
+		t.Error("un heartbeat rechazado debe explicar el motivo")
+	}
+	if _, ok := store.Snapshot()["node-2"]; !ok {
+		t.Error("el heartbeat de node-2 debía registrarse a través del relay")
+	}
+}
+
+func TestParseRelays(t *testing.T) {
+	got, err := ParseRelays("gateway-1=sensor-a;sensor-b, gateway-2=sensor-c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]bool{
+		"gateway-1": {"sensor-a": true, "sensor-b": true},
+		"gateway-2": {"sensor-c": true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRelays = %v, se esperaba %v", got, want)
+	}
+
+	// Un relay sin lista de vecinos no puede reportar cualquier nodo
+	for _, spec := range []string{"gateway-1", "gateway-1=", "=sensor-a", "gateway-1=sensor-a;;sensor-b"} {
+		if _, err := ParseRelays(spec); err == nil {
+			t.Errorf("ParseRelays(%q) debía fallar", spec)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package heartbeatserver
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/jaiderssjgod/edge-operator/heartbeat"
+	"github.com/jaiderssjgod/edge-operator/heartbeat/stream"
+	"github.com/jaiderssjgod/edge-operator/internal/heartbeatauth"
+	"github.com/jaiderssjgod/edge-operator/internal/heartbeatstore"
+	"github.com/jaiderssjgod/edge-operator/internal/metrics"
+)
+
+// Keepalive del servidor gRPC: un agente que deja de responder a los pings
+// se da por desconectado en keepaliveTime+keepaliveTimeout.
+const (
+	keepaliveTime    = 10 * time.Second
+	keepaliveTimeout = 5 * time.Second
+)
+
+// defaultConfigPollInterval es cada cuánto se comprueba si cambió la
+// configuración remota de un nodo con stream abierto.
+const defaultConfigPollInterval = 5 * time.Second
+
+// StreamServer es el servidor gRPC que recibe heartbeats por streams
+// bidireccionales (paquete heartbeat/stream). Convive con Server: los
+// agentes eligen transporte y el store es el mismo.
+type StreamServer struct {
+	store *heartbeatstore.Store
+	log   logr.Logger
+	addr  string
+
+	// Authenticator verifica quién abre cada stream, con las cabeceras de
+	// los metadatos y el certificado de cliente de la conexión. HMAC no
+	// sirve aquí: firma cuerpos de peticiones HTTP. Si es nil se acepta
+	// cualquier stream.
+	Authenticator heartbeatauth.Authenticator
+	// TLSConfig, si no es nil, sirve los streams sobre TLS, p. ej. el de
+	// Server.TLSConfig.
+	TLSConfig *tls.Config
+	// OnDisconnect, si no es nil, se llama cuando se rompe el stream de un
+	// nodo, tras marcarlo desconectado en el store.
+	OnDisconnect func(nodeName string)
+	// ConfigPollInterval es cada cuánto se envía CommandApplyConfig si la
+	// configuración remota del nodo cambió; 0 usa 5s.
+	ConfigPollInterval time.Duration
+
+	mu sync.Mutex
+	// active es el stream vigente de cada nodo, por número de stream. Si un
+	// agente reconecta antes de que el stream anterior muera, romper el
+	// anterior no debe marcar el nodo desconectado.
+	active  map[string]uint64
+	streams uint64
+}
+
+// NewStream crea un StreamServer que escucha en addr y almacena en store.
+func NewStream(addr string, store *heartbeatstore.Store, log logr.Logger) *StreamServer {
+	return &StreamServer{
+		store:  store,
+		log:    log,
+		addr:   addr,
+		active: make(map[string]uint64),
+	}
+}
+
+// Start arranca el servidor gRPC. Bloquea; se debe invocar con `go`.
+func (s *StreamServer) Start() {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		s.log.Error(err, "Unable to listen for heartbeat streams", "addr", s.addr)
+		return
+	}
+	s.log.Info("Starting heartbeat gRPC server", "addr", s.addr, "tls", s.TLSConfig != nil)
+	if err := s.newGRPCServer().Serve(lis); err != nil {
+		s.log.Error(err, "Heartbeat gRPC server stopped unexpectedly")
+	}
+}
+
+func (s *StreamServer) newGRPCServer() *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveParams(keepalive.ServerParameters{Time: keepaliveTime, Timeout: keepaliveTimeout}),
+		// Los agentes hacen ping con la misma cadencia
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: keepaliveTime / 2, PermitWithoutStream: true}),
+	}
+	if s.TLSConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(grpcTLSConfig(s.TLSConfig))))
+	}
+	srv := grpc.NewServer(opts...)
+	stream.RegisterServer(srv, s)
+	return srv
+}
+
+// grpcTLSConfig limita el ALPN de base a h2. La configuración por cliente
+// de GetConfigForClient ya anuncia h2 porque se clona de la de EnableTLS.
+func grpcTLSConfig(base *tls.Config) *tls.Config {
+	cfg := base.Clone()
+	cfg.NextProtos = []string{"h2"}
+	return cfg
+}
+
+// Stream implementa stream.Server. El primer heartbeat fija el nodo del
+// stream; los siguientes deben ser del mismo nodo.
+func (s *StreamServer) Stream(st stream.ServerStream) error {
+	id, err := s.authenticate(st)
+	if err != nil {
+		return err
+	}
+
+	msgs := make(chan *stream.AgentMessage)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := st.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-st.Context().Done():
+				return
+			}
+		}
+	}()
+
+	interval := s.ConfigPollInterval
+	if interval <= 0 {
+		interval = defaultConfigPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		node       string
+		handle     uint64
+		lastConfig *heartbeat.AgentConfig
+	)
+	for {
+		select {
+		case msg := <-msgs:
+			if msg.Heartbeat == nil {
+				continue
+			}
+			payload := *msg.Heartbeat
+			if node == "" {
+				if s.Authenticator != nil && id.NodeName != payload.NodeName {
+					s.log.Info("Rejected heartbeat stream for another node",
+						"subject", id.Subject, "callerNode", id.NodeName, "claimedNode", payload.NodeName)
+					return status.Errorf(codes.PermissionDenied, "caller runs on node %q, not %q", id.NodeName, payload.NodeName)
+				}
+				if payload.NodeName == "" {
+					return status.Error(codes.InvalidArgument, "nodeName is required")
+				}
+				node, handle = payload.NodeName, s.register(payload.NodeName)
+				defer s.unregister(node, handle)
+				s.log.Info("Heartbeat stream opened", "node", node)
+			} else if payload.NodeName != node {
+				return status.Errorf(codes.InvalidArgument, "stream belongs to node %q, not %q", node, payload.NodeName)
+			}
+
+			resp := &stream.OperatorMessage{}
+			if err := payload.Normalize(); err != nil {
+				resp.Response = &heartbeat.Response{Status: heartbeat.StatusRejected}
+				resp.Error = err.Error()
+			} else {
+				if s.store.Record(payload) {
+					reportDroppedTelemetry(s.log, s.store, payload)
+					metrics.HeartbeatsReceived.WithLabelValues(metrics.NodeLabel(s.store, node)).Inc()
+					s.log.V(1).Info("Heartbeat received", "node", node, "ts", payload.Timestamp, "transport", "grpc")
+				} else {
+					s.log.V(1).Info("Ignored heartbeat not newer than the last one", "node", node, "ts", payload.Timestamp, "transport", "grpc")
+				}
+				lastConfig = s.agentConfig(node)
+				resp.Response = &heartbeat.Response{Status: heartbeat.StatusOK, Config: lastConfig}
+			}
+			if err := st.Send(resp); err != nil {
+				return s.closed(node, handle, err)
+			}
+
+		case <-ticker.C:
+			if node == "" {
+				continue
+			}
+			cfg := s.agentConfig(node)
+			if reflect.DeepEqual(cfg, lastConfig) {
+				continue
+			}
+			cmd := &stream.Command{Type: stream.CommandApplyConfig, Config: cfg}
+			if err := st.Send(&stream.OperatorMessage{Command: cmd}); err != nil {
+				return s.closed(node, handle, err)
+			}
+			lastConfig = cfg
+			s.log.V(1).Info("Pushed agent configuration", "node", node)
+
+		case err := <-recvErr:
+			return s.closed(node, handle, err)
+		}
+	}
+}
+
+func (s *StreamServer) agentConfig(node string) *heartbeat.AgentConfig {
+	if cfg, ok := s.store.AgentConfig(node); ok {
+		return &cfg
+	}
+	return nil
+}
+
+// closed procesa el fin del stream de node por err. Un cierre ordenado
+// (io.EOF) es un agente que se detiene, p. ej. al actualizarse, y se deja
+// al timeout; cualquier otro corte marca el nodo desconectado en el acto.
+func (s *StreamServer) closed(node string, handle uint64, err error) error {
+	if node == "" {
+		return nil
+	}
+	if errors.Is(err, io.EOF) {
+		s.log.Info("Heartbeat stream closed by agent", "node", node)
+		return nil
+	}
+	if !s.isActive(node, handle) {
+		// El agente ya abrió otro stream
+		return nil
+	}
+	s.log.Info("Heartbeat stream broken, marking node disconnected", "node", node, "reason", err.Error())
+	s.store.Disconnect(node)
+	if s.OnDisconnect != nil {
+		s.OnDisconnect(node)
+	}
+	return nil
+}
+
+func (s *StreamServer) register(node string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams++
+	s.active[node] = s.streams
+	return s.streams
+}
+
+func (s *StreamServer) unregister(node string, h uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[node] == h {
+		delete(s.active, node)
+	}
+}
+
+func (s *StreamServer) isActive(node string, h uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active[node] == h
+}
+
+// authenticate verifica las credenciales del stream con Authenticator,
+// presentándole una petición HTTP con los metadatos como cabeceras y el
+// estado TLS de la conexión.
+func (s *StreamServer) authenticate(st stream.ServerStream) (heartbeatauth.Identity, error) {
+	if s.Authenticator == nil {
+		return heartbeatauth.Identity{}, nil
+	}
+	ctx := st.Context()
+	req := (&http.Request{Method: http.MethodPost, Header: http.Header{}}).WithContext(ctx)
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, values := range md {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			req.TLS = &info.State
+		}
+	}
+
+	id, err := s.Authenticator.Authenticate(req, nil)
+	switch {
+	case err == nil:
+		return id, nil
+	case errors.Is(err, heartbeatauth.ErrUnauthenticated):
+		s.log.Info("Rejected unauthenticated stream", "reason", err.Error())
+		return id, status.Error(codes.Unauthenticated, "unauthorized")
+	case errors.Is(err, heartbeatauth.ErrForbidden):
+		s.log.Info("Rejected unauthorized stream", "reason", err.Error())
+		return id, status.Error(codes.PermissionDenied, "forbidden")
+	default:
+		s.log.Error(err, "Failed to authenticate stream")
+		return id, status.Error(codes.Unavailable, "authentication unavailable")
+	}
+}
@@ -0,0 +1,137 @@
+package heartbeatserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/jaiderssjgod/edge-operator/heartbeat"
+	"github.com/jaiderssjgod/edge-operator/heartbeat/stream"
+	"github.com/jaiderssjgod/edge-operator/internal/heartbeatstore"
+)
+
+// startStream sirve s en memoria y devuelve una conexión de cliente.
+func startStream(t *testing.T, s *StreamServer) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := s.newGRPCServer()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func sendBeat(t *testing.T, st stream.ClientStream, node string) {
+	t.Helper()
+	p := heartbeat.Payload{Version: heartbeat.CurrentVersion, NodeName: node, Timestamp: time.Now().UTC()}
+	if err := st.Send(&stream.AgentMessage{Heartbeat: &p}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout esperando %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStream_RespondsPushesConfigAndDetectsBreakage(t *testing.T) {
+	store := heartbeatstore.New(time.Minute)
+	store.SetAgentConfig("n1", heartbeat.AgentConfig{Policy: "policy-b", HeartbeatIntervalSeconds: 30})
+	s := NewStream(":0", store, logr.Discard())
+	s.ConfigPollInterval = 10 * time.Millisecond
+	disconnected := make(chan string, 1)
+	s.OnDisconnect = func(node string) { disconnected <- node }
+	conn := startStream(t, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	st, err := stream.Open(ctx, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sendBeat(t, st, "n1")
+	msg, err := st.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Response == nil || msg.Response.Status != heartbeat.StatusOK || msg.Response.Config.HeartbeatIntervalSeconds != 30 {
+		t.Fatalf("respuesta = %+v, se esperaba ok con la configuración de policy-b", msg)
+	}
+	if store.GetNodeState("n1").Offline {
+		t.Fatal("el heartbeat del stream debía registrarse")
+	}
+
+	// policy-a pasa a mandar: el operador la envía sin esperar al heartbeat
+	store.SetAgentConfig("n1", heartbeat.AgentConfig{Policy: "policy-a", HeartbeatIntervalSeconds: 20})
+	msg, err = st.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Command == nil || msg.Command.Type != stream.CommandApplyConfig || msg.Command.Config.HeartbeatIntervalSeconds != 20 {
+		t.Fatalf("mensaje = %+v, se esperaba el comando con la configuración de policy-a", msg)
+	}
+
+	// Un corte sin cierre ordenado marca el nodo offline antes del timeout
+	cancel()
+	select {
+	case node := <-disconnected:
+		if node != "n1" {
+			t.Errorf("OnDisconnect(%q), se esperaba n1", node)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnDisconnect no se llamó al romperse el stream")
+	}
+	if state := store.GetNodeState("n1"); !state.Offline || !state.Disconnected {
+		t.Errorf("estado tras el corte = %+v, se esperaba offline por desconexión", state)
+	}
+}
+
+func TestStream_OrderlyCloseWaitsForTimeout(t *testing.T) {
+	store := heartbeatstore.New(time.Minute)
+	s := NewStream(":0", store, logr.Discard())
+	conn := startStream(t, s)
+
+	st, err := stream.Open(context.Background(), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendBeat(t, st, "n1")
+	if _, err := st.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	// El servidor termina el stream al ver el cierre
+	if _, err := st.Recv(); err == nil {
+		t.Fatal("se esperaba el fin del stream")
+	}
+	waitFor(t, "que el servidor olvide el stream", func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.active) == 0
+	})
+	if store.GetNodeState("n1").Offline {
+		t.Error("un agente que se detiene de forma ordenada no debe marcar el nodo offline antes del timeout")
+	}
+}
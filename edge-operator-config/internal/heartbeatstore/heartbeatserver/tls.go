@@ -0,0 +1,171 @@
+package heartbeatserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// TLSOptions configura TLS en el servidor de heartbeats.
+type TLSOptions struct {
+	// CertFile y KeyFile son el certificado y la clave del servidor.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile, si no está vacío, es el bundle de CAs con el que se
+	// verifican los certificados de cliente de los agentes.
+	ClientCAFile string
+	// RequireClientCert rechaza en el handshake a los clientes sin un
+	// certificado firmado por ClientCAFile. Si es false el certificado es
+	// opcional, pero se verifica cuando se presenta.
+	RequireClientCert bool
+}
+
+// EnableTLS hace que Start sirva HTTPS. Los ficheros se releen cuando
+// cambian, p. ej. al rotar el Secret montado, sin reiniciar el operador.
+// Devuelve error si los ficheros iniciales no son válidos.
+func (s *Server) EnableTLS(opts TLSOptions) error {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return errors.New("TLS requires a certificate and a key file")
+	}
+	if opts.RequireClientCert && opts.ClientCAFile == "" {
+		return errors.New("requiring client certificates needs a client CA file")
+	}
+
+	cert := &reloader[tls.Certificate]{
+		files: []string{opts.CertFile, opts.KeyFile},
+		load: func() (*tls.Certificate, error) {
+			c, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+			return &c, err
+		},
+		log: s.log.WithValues("cert", opts.CertFile),
+	}
+	if _, err := cert.get(); err != nil {
+		return err
+	}
+
+	// Los protocolos ALPN que añadiría net/http se fijan aquí para que
+	// también los herede la configuración por cliente de GetConfigForClient,
+	// que se clona de base; sin ellos no se negocia h2 ni para HTTP ni para
+	// gRPC
+	base := &tls.Config{MinVersion: tls.VersionTLS12, NextProtos: []string{"h2", "http/1.1"}}
+	base.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return cert.get()
+	}
+
+	if opts.ClientCAFile != "" {
+		clientCAs := &reloader[x509.CertPool]{
+			files: []string{opts.ClientCAFile},
+			load:  func() (*x509.CertPool, error) { return loadCertPool(opts.ClientCAFile) },
+			log:   s.log.WithValues("clientCA", opts.ClientCAFile),
+		}
+		pool, err := clientCAs.get()
+		if err != nil {
+			return err
+		}
+		base.ClientCAs = pool
+		base.ClientAuth = tls.VerifyClientCertIfGiven
+		if opts.RequireClientCert {
+			base.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		// Cada handshake usa una copia de base con el bundle de CAs vigente;
+		// el resto de ajustes, como NextProtos o GetCertificate, se conserva
+		base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			pool, err := clientCAs.get()
+			if err != nil {
+				return nil, err
+			}
+			cfg := base.Clone()
+			cfg.GetConfigForClient = nil
+			cfg.ClientCAs = pool
+			return cfg, nil
+		}
+	}
+
+	s.server.TLSConfig = base
+	return nil
+}
+
+// TLSConfig devuelve la configuración TLS de EnableTLS, o nil si el
+// servidor sirve HTTP. Sirve para compartir certificados con StreamServer.
+func (s *Server) TLSConfig() *tls.Config {
+	return s.server.TLSConfig
+}
+
+// loadCertPool lee un bundle PEM de certificados.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// reloader mantiene el valor cargado de unos ficheros y lo recarga cuando
+// cambia la fecha de modificación de alguno. Si la recarga falla, p. ej.
+// porque la rotación escribió el certificado pero aún no la clave, sigue
+// sirviendo el valor anterior.
+type reloader[T any] struct {
+	files []string
+	load  func() (*T, error)
+	log   logr.Logger
+
+	mu      sync.Mutex
+	value   *T
+	modTime []time.Time
+}
+
+func (r *reloader[T]) get() (*T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTime := make([]time.Time, len(r.files))
+	for i, f := range r.files {
+		info, err := os.Stat(f)
+		if err != nil {
+			if r.value != nil {
+				return r.value, nil
+			}
+			return nil, err
+		}
+		modTime[i] = info.ModTime()
+	}
+	if r.value != nil && equalTimes(modTime, r.modTime) {
+		return r.value, nil
+	}
+
+	value, err := r.load()
+	if err != nil {
+		if r.value != nil {
+			r.log.Error(err, "Failed to reload TLS material, keeping the previous one")
+			return r.value, nil
+		}
+		return nil, err
+	}
+	if r.value != nil {
+		r.log.Info("Reloaded TLS material")
+	}
+	r.value, r.modTime = value, modTime
+	return value, nil
+}
+
+func equalTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,259 @@
+package heartbeatserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"github.com/jaiderssjgod/edge-operator/internal/heartbeatauth"
+	"github.com/jaiderssjgod/edge-operator/internal/heartbeatstore"
+)
+
+// testCA firma certificados de prueba.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue devuelve en PEM un certificado y su clave para cn, de servidor en
+// 127.0.0.1 o de cliente.
+func (ca *testCA) issue(t *testing.T, serial int64, cn string, server bool) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if server {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		tmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Fija la fecha para que la rotación se detecte aunque ocurra en el
+	// mismo instante que la escritura anterior
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serveTLS arranca s en un puerto libre y devuelve su URL base.
+func serveTLS(t *testing.T, s *Server) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.server.ServeTLS(ln, "", "")
+	t.Cleanup(func() { s.server.Close() })
+	return "https://" + ln.Addr().String()
+}
+
+func tlsClient(ca *testCA, cert *tls.Certificate) *http.Client {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	cfg := &tls.Config{RootCAs: roots}
+	if cert != nil {
+		cfg.Certificates = []tls.Certificate{*cert}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}}
+}
+
+func TestEnableTLS_ClientCertificateNamesTheNode(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, 2, "edge-operator", true)
+	now := time.Now()
+	writeFile(t, filepath.Join(dir, "tls.crt"), serverCert, now)
+	writeFile(t, filepath.Join(dir, "tls.key"), serverKey, now)
+	writeFile(t, filepath.Join(dir, "ca.crt"), ca.pem, now)
+
+	s := New(":0", heartbeatstore.New(time.Minute), logr.Discard())
+	s.Authenticator = heartbeatauth.ClientCert{}
+	if err := s.EnableTLS(TLSOptions{
+		CertFile:          filepath.Join(dir, "tls.crt"),
+		KeyFile:           filepath.Join(dir, "tls.key"),
+		ClientCAFile:      filepath.Join(dir, "ca.crt"),
+		RequireClientCert: true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	url := serveTLS(t, s)
+
+	clientPEM, clientKey := ca.issue(t, 3, "system:node:n1", false)
+	clientCert, err := tls.X509KeyPair(clientPEM, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := tlsClient(ca, &clientCert)
+
+	post := func(c *http.Client, node string) (int, error) {
+		body := `{"nodeName":"` + node + `","timestamp":"` + time.Now().UTC().Format(time.RFC3339) + `","cpu":"10%","memory":"20%"}`
+		resp, err := c.Post(url+"/heartbeat", "application/json", strings.NewReader(body))
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	if code, err := post(client, "n1"); err != nil || code != http.StatusOK {
+		t.Errorf("heartbeat del propio nodo: status %d, err %v; se esperaba 200", code, err)
+	}
+	if code, err := post(client, "n2"); err != nil || code != http.StatusForbidden {
+		t.Errorf("heartbeat de otro nodo: status %d, err %v; se esperaba 403", code, err)
+	}
+	if _, err := post(tlsClient(ca, nil), "n1"); err == nil {
+		t.Error("un cliente sin certificado no debe completar el handshake")
+	}
+}
+
+func TestEnableTLS_ClientCAsKeepALPN(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, 20, "edge-operator", true)
+	now := time.Now()
+	writeFile(t, filepath.Join(dir, "tls.crt"), serverCert, now)
+	writeFile(t, filepath.Join(dir, "tls.key"), serverKey, now)
+	writeFile(t, filepath.Join(dir, "ca.crt"), ca.pem, now)
+
+	s := New(":0", heartbeatstore.New(time.Minute), logr.Discard())
+	if err := s.EnableTLS(TLSOptions{
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// La configuración por cliente, también la del servidor gRPC, conserva
+	// el ALPN y solo cambia el bundle de CAs
+	for name, cfg := range map[string]*tls.Config{"http": s.TLSConfig(), "grpc": grpcTLSConfig(s.TLSConfig())} {
+		perClient, err := cfg.GetConfigForClient(&tls.ClientHelloInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Contains(perClient.NextProtos, "h2") || perClient.ClientCAs == nil ||
+			perClient.ClientAuth != tls.VerifyClientCertIfGiven || perClient.MinVersion != tls.VersionTLS12 {
+			t.Errorf("%s: configuración por cliente inesperada: NextProtos %v, ClientAuth %v, MinVersion %x",
+				name, perClient.NextProtos, perClient.ClientAuth, perClient.MinVersion)
+		}
+	}
+
+	url := serveTLS(t, s)
+	client := tlsClient(ca, nil)
+	client.Transport.(*http.Transport).ForceAttemptHTTP2 = true
+	body := `{"version":2,"nodeName":"n1","timestamp":"` + time.Now().UTC().Format(time.RFC3339) + `"}`
+	resp, err := client.Post(url+"/heartbeat", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 2 {
+		t.Errorf("status %d sobre %s; se esperaba 200 sobre HTTP/2", resp.StatusCode, resp.Proto)
+	}
+}
+
+func TestEnableTLS_ReloadsRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	ca := newTestCA(t)
+	cert, key := ca.issue(t, 10, "edge-operator", true)
+	before := time.Now().Add(-time.Minute)
+	writeFile(t, certFile, cert, before)
+	writeFile(t, keyFile, key, before)
+
+	s := New(":0", heartbeatstore.New(time.Minute), logr.Discard())
+	if err := s.EnableTLS(TLSOptions{CertFile: certFile, KeyFile: keyFile}); err != nil {
+		t.Fatal(err)
+	}
+	url := serveTLS(t, s)
+	client := tlsClient(ca, nil)
+
+	servedSerial := func() int64 {
+		t.Helper()
+		resp, err := client.Get(url + "/heartbeat")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	if got := servedSerial(); got != 10 {
+		t.Fatalf("serial servido = %d, se esperaba 10", got)
+	}
+
+	// Una rotación a medias no rompe el certificado en uso
+	cert, key = ca.issue(t, 11, "edge-operator", true)
+	writeFile(t, certFile, cert, time.Now())
+	if got := servedSerial(); got != 10 {
+		t.Errorf("con la clave aún sin rotar, serial servido = %d, se esperaba 10", got)
+	}
+
+	writeFile(t, keyFile, key, time.Now())
+	if got := servedSerial(); got != 11 {
+		t.Errorf("tras rotar, serial servido = %d, se esperaba 11", got)
+	}
+}
@@ -0,0 +1,76 @@
+package heartbeatstore
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jaiderssjgod/edge-operator/heartbeat"
+)
+
+// maxReplayWindows es el número de ventanas reproducidas que se conservan
+// por nodo hasta que los reconcilers las consumen.
+const maxReplayWindows = 10
+
+// ReplayWindow resume un lote de muestras que el agente almacenó mientras
+// no podía alcanzar al operador.
+type ReplayWindow struct {
+	// ReceivedAt es el momento en que el operador recibió el lote.
+	ReceivedAt time.Time
+	// From y To son los timestamps de la primera y la última muestra.
+	From time.Time
+	To   time.Time
+	// Samples es el número de muestras del lote.
+	Samples int
+	// PeakCPU y PeakMemory son los máximos de las muestras con telemetría.
+	PeakCPU    float64
+	PeakMemory float64
+}
+
+// RecordReplay resume las muestras reproducidas por un agente y las guarda
+// como una ventana del nodo. Las muestras deben estar normalizadas.
+func (s *Store) RecordReplay(nodeName string, samples []heartbeat.Payload) ReplayWindow {
+	sorted := append([]heartbeat.Payload(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+
+	// Precisión de microsegundos: la que conserva el status de la policy
+	w := ReplayWindow{ReceivedAt: time.Now().Truncate(time.Microsecond), Samples: len(sorted)}
+	if len(sorted) > 0 {
+		w.From, w.To = sorted[0].Timestamp, sorted[len(sorted)-1].Timestamp
+	}
+	for _, p := range sorted {
+		if p.Resources == nil {
+			continue
+		}
+		if p.Resources.CPUPercent > w.PeakCPU {
+			w.PeakCPU = p.Resources.CPUPercent
+		}
+		if p.Resources.MemoryPercent > w.PeakMemory {
+			w.PeakMemory = p.Resources.MemoryPercent
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	windows := append(s.replays[nodeName], w)
+	if len(windows) > maxReplayWindows {
+		windows = windows[len(windows)-maxReplayWindows:]
+	}
+	s.replays[nodeName] = windows
+	return w
+}
+
+// ReplaysSince devuelve las ventanas del nodo recibidas después de since.
+// Cada policy guarda hasta dónde ha leído, así varias policies pueden
+// observar el mismo nodo sin consumirse las ventanas entre sí.
+func (s *Store) ReplaysSince(nodeName string, since time.Time) []ReplayWindow {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []ReplayWindow
+	for _, w := range s.replays[nodeName] {
+		if w.ReceivedAt.After(since) {
+			out = append(out, w)
+		}
+	}
+	return out
+}
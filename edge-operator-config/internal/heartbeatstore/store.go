@@ -15,15 +15,43 @@ type NodeState struct {
 	LastHeartbeat time.Time
 	CPU           string
 	Memory        string
-	// Offline es true cuando no se ha recibido heartbeat en los últimos timeoutDuration.
+	// Resources es la telemetría tipada del último heartbeat, o nil si el
+	// heartbeat no la incluía.
+	Resources *heartbeat.Resources
+	// Offline es true cuando no se ha recibido heartbeat dentro del timeout
+	// evaluado o cuando se rompió el stream del agente.
 	Offline bool
+	// Disconnected es true cuando el stream gRPC del agente se rompió y no
+	// ha llegado ningún heartbeat después.
+	Disconnected bool
+	// MissedHeartbeats es el número de heartbeats consecutivos perdidos según
+	// el intervalo evaluado. Es 0 si no se indicó intervalo.
+	MissedHeartbeats int
+}
+
+// Thresholds define cuándo se considera offline un nodo. Cada
+// ReducedNodePolicy aporta los suyos para evaluar los nodos que selecciona.
+type Thresholds struct {
+	// Timeout es el tiempo máximo sin heartbeats. 0 usa el timeout del Store.
+	Timeout time.Duration
+	// Interval es el intervalo esperado entre heartbeats del agente.
+	Interval time.Duration
+	// MissedHeartbeats, si es mayor que 0 y hay Interval, sustituye a Timeout:
+	// el nodo pasa a offline tras perder ese número de heartbeats consecutivos.
+	MissedHeartbeats int
 }
 
 // Store guarda el último heartbeat de cada nodo y expone métodos para
 // consultarlos y determinar si un nodo está offline.
 type Store struct {
-	mu              sync.RWMutex
-	records         map[string]heartbeat.Payload
+	mu      sync.RWMutex
+	records map[string]heartbeat.Payload
+	replays map[string][]ReplayWindow
+	// agentConfigs es la configuración remota de cada nodo por policy.
+	agentConfigs map[string]map[string]heartbeat.AgentConfig
+	// disconnected son los nodos cuyo stream se rompió tras su último
+	// heartbeat.
+	disconnected    map[string]bool
 	timeoutDuration time.Duration
 }
 
@@ -31,20 +59,50 @@ type Store struct {
 func New(timeout time.Duration) *Store {
 	return &Store{
 		records:         make(map[string]heartbeat.Payload),
+		replays:         make(map[string][]ReplayWindow),
+		agentConfigs:    make(map[string]map[string]heartbeat.AgentConfig),
+		disconnected:    make(map[string]bool),
 		timeoutDuration: timeout,
 	}
 }
 
-// Record almacena (o sobreescribe) el heartbeat más reciente de un nodo.
-func (s *Store) Record(p heartbeat.Payload) {
+// Record almacena (o sobreescribe) el heartbeat más reciente de un nodo y
+// devuelve true. Un heartbeat cuyo Timestamp no es posterior al guardado se
+// ignora y devuelve false: así una petición firmada capturada y reenviada
+// dentro de su ventana de validez no sustituye datos más nuevos ni deshace
+// un Disconnect.
+func (s *Store) Record(p heartbeat.Payload) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if prev, ok := s.records[p.NodeName]; ok && !p.Timestamp.After(prev.Timestamp) {
+		return false
+	}
 	s.records[p.NodeName] = p
+	delete(s.disconnected, p.NodeName)
+	return true
+}
+
+// Disconnect marca offline un nodo sin esperar al timeout, porque su
+// stream se rompió. El siguiente heartbeat del nodo lo deshace.
+func (s *Store) Disconnect(nodeName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[nodeName]; ok {
+		s.disconnected[nodeName] = true
+	}
 }
 
-// GetNodeState devuelve el estado actual de un nodo dado su nombre.
+// GetNodeState devuelve el estado actual de un nodo dado su nombre,
+// evaluado con el timeout global del Store.
 // Si el nodo no tiene ningún heartbeat registrado, Offline=true y LastHeartbeat es zero.
 func (s *Store) GetNodeState(nodeName string) NodeState {
+	return s.EvaluateNodeState(nodeName, Thresholds{})
+}
+
+// EvaluateNodeState devuelve el estado actual de un nodo evaluado con los
+// umbrales indicados por el llamador.
+// Si el nodo no tiene ningún heartbeat registrado, Offline=true y LastHeartbeat es zero.
+func (s *Store) EvaluateNodeState(nodeName string, t Thresholds) NodeState {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -53,13 +111,30 @@ func (s *Store) GetNodeState(nodeName string) NodeState {
 		return NodeState{Offline: true}
 	}
 
-	offline := time.Since(p.Timestamp) > s.timeoutDuration
-	return NodeState{
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = s.timeoutDuration
+	}
+	if t.MissedHeartbeats > 0 && t.Interval > 0 {
+		timeout = time.Duration(t.MissedHeartbeats) * t.Interval
+	}
+
+	elapsed := time.Since(p.Timestamp)
+	state := NodeState{
 		LastHeartbeat: p.Timestamp,
 		CPU:           p.CPU,
 		Memory:        p.Memory,
-		Offline:       offline,
+		Offline:       elapsed > timeout || s.disconnected[nodeName],
+		Disconnected:  s.disconnected[nodeName],
+	}
+	if p.Resources != nil {
+		res := *p.Resources
+		state.Resources = &res
+	}
+	if t.Interval > 0 && elapsed > 0 {
+		state.MissedHeartbeats = int(elapsed / t.Interval)
 	}
+	return state
 }
 
 // Snapshot devuelve una copia del mapa de payloads para que el reconciler
@@ -93,3 +93,123 @@ func TestSnapshot_ReturnsCopy(t *testing.T) {
 		t.Error("modifying snapshot should not affect the store")
 	}
 }
+
+func TestEvaluateNodeState_PolicyTimeoutOverridesStore(t *testing.T) {
+	store := heartbeatstore.New(30 * time.Second)
+	store.Record(heartbeat.Payload{
+		NodeName:  "node-flaky",
+		Timestamp: time.Now().Add(-60 * time.Second),
+	})
+
+	if !store.GetNodeState("node-flaky").Offline {
+		t.Error("con el timeout global de 30 s el nodo debería estar offline")
+	}
+	state := store.EvaluateNodeState("node-flaky", heartbeatstore.Thresholds{Timeout: 2 * time.Minute})
+	if state.Offline {
+		t.Error("con un timeout de 2 min el nodo debería seguir online")
+	}
+}
+
+func TestEvaluateNodeState_MissedHeartbeats(t *testing.T) {
+	store := heartbeatstore.New(30 * time.Second)
+	store.Record(heartbeat.Payload{
+		NodeName:  "node-edge-1",
+		Timestamp: time.Now().Add(-25 * time.Second),
+	})
+
+	lenient := heartbeatstore.Thresholds{Interval: 10 * time.Second, MissedHeartbeats: 3}
+	state := store.EvaluateNodeState("node-edge-1", lenient)
+	if state.Offline {
+		t.Error("con 2 heartbeats perdidos y un umbral de 3 el nodo debería estar online")
+	}
+	if state.MissedHeartbeats != 2 {
+		t.Errorf("se esperaban 2 heartbeats perdidos, got %d", state.MissedHeartbeats)
+	}
+
+	strict := heartbeatstore.Thresholds{Interval: 10 * time.Second, MissedHeartbeats: 2}
+	if !store.EvaluateNodeState("node-edge-1", strict).Offline {
+		t.Error("con un umbral de 2 heartbeats perdidos el nodo debería estar offline")
+	}
+}
+
+func TestRecordReplay_SummarizesWindowAndKeepsItForEveryReader(t *testing.T) {
+	store := heartbeatstore.New(30 * time.Second)
+	start := time.Now().Add(-10 * time.Minute)
+	before := time.Now()
+
+	window := store.RecordReplay("node-edge-1", []heartbeat.Payload{
+		{NodeName: "node-edge-1", Timestamp: start.Add(2 * time.Minute),
+			Resources: &heartbeat.Resources{CPUPercent: 97, MemoryPercent: 40}},
+		{NodeName: "node-edge-1", Timestamp: start,
+			Resources: &heartbeat.Resources{CPUPercent: 20, MemoryPercent: 75}},
+		{NodeName: "node-edge-1", Timestamp: start.Add(time.Minute)},
+	})
+
+	if window.Samples != 3 || !window.From.Equal(start) || !window.To.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("ventana inesperada: %+v", window)
+	}
+	if window.PeakCPU != 97 || window.PeakMemory != 75 {
+		t.Errorf("picos inesperados: cpu=%v memory=%v", window.PeakCPU, window.PeakMemory)
+	}
+
+	// Leer no consume: otra policy debe ver la misma ventana
+	for i := 0; i < 2; i++ {
+		if got := store.ReplaysSince("node-edge-1", before.Add(-time.Second)); len(got) != 1 {
+			t.Fatalf("lectura %d: se esperaba 1 ventana, got %d", i, len(got))
+		}
+	}
+	if got := store.ReplaysSince("node-edge-1", window.ReceivedAt); len(got) != 0 {
+		t.Errorf("no debería haber ventanas posteriores a la ya leída: %v", got)
+	}
+}
+
+func TestRecord_IgnoresOlderHeartbeats(t *testing.T) {
+	store := heartbeatstore.New(30 * time.Second)
+	now := time.Now()
+	fresh := heartbeat.Payload{NodeName: "node-edge-1", Timestamp: now, CPU: "20.00%"}
+	if !store.Record(fresh) {
+		t.Fatal("expected the first heartbeat to be recorded")
+	}
+
+	// Un heartbeat anterior, o el mismo reenviado, no sustituye al guardado
+	for name, p := range map[string]heartbeat.Payload{
+		"anterior":  {NodeName: "node-edge-1", Timestamp: now.Add(-time.Minute), CPU: "90.00%"},
+		"reenviado": fresh,
+	} {
+		if store.Record(p) {
+			t.Errorf("%s: expected the heartbeat to be ignored", name)
+		}
+	}
+	if state := store.GetNodeState("node-edge-1"); state.CPU != "20.00%" || !state.LastHeartbeat.Equal(now) {
+		t.Errorf("expected the newest heartbeat to be kept, got %+v", state)
+	}
+
+	// Reenviar el último heartbeat no deshace la desconexión
+	store.Disconnect("node-edge-1")
+	store.Record(fresh)
+	if state := store.GetNodeState("node-edge-1"); !state.Disconnected {
+		t.Errorf("expected a replayed heartbeat to keep the node disconnected, got %+v", state)
+	}
+}
+
+func TestDisconnect_IsOfflineUntilNextHeartbeat(t *testing.T) {
+	store := heartbeatstore.New(30 * time.Second)
+	store.Record(heartbeat.Payload{NodeName: "node-edge-1", Timestamp: time.Now()})
+
+	store.Disconnect("node-edge-1")
+	if state := store.GetNodeState("node-edge-1"); !state.Offline || !state.Disconnected {
+		t.Errorf("expected disconnected node to be offline before the timeout, got %+v", state)
+	}
+
+	store.Record(heartbeat.Payload{NodeName: "node-edge-1", Timestamp: time.Now()})
+	if state := store.GetNodeState("node-edge-1"); state.Offline || state.Disconnected {
+		t.Errorf("expected a new heartbeat to clear the disconnection, got %+v", state)
+	}
+
+	// Un nodo sin heartbeats no guarda la marca
+	store.Disconnect("node-edge-2")
+	store.Record(heartbeat.Payload{NodeName: "node-edge-2", Timestamp: time.Now()})
+	if store.GetNodeState("node-edge-2").Offline {
+		t.Error("expected node-edge-2 to be online")
+	}
+}
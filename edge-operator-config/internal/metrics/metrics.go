@@ -0,0 +1,57 @@
+// Package metrics define las métricas Prometheus del operador. Se registran
+// en el registry de controller-runtime, de modo que se sirven en
+// --metrics-bind-address junto con las métricas propias del manager.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// HeartbeatsReceived cuenta los heartbeats aceptados por nodo. Los nodos
+	// que ninguna policy selecciona comparten la etiqueta UnknownNode; ver
+	// NodeLabel.
+	HeartbeatsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "edge_heartbeat_received_total",
+		Help: "Heartbeats aceptados por el operador, por nodo.",
+	}, []string{"node"})
+
+	// HeartbeatTelemetryDropped cuenta los heartbeats aceptados solo como
+	// señal de vida porque su telemetría v1 era ilegible. Etiquetado como
+	// HeartbeatsReceived.
+	HeartbeatTelemetryDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "edge_heartbeat_telemetry_dropped_total",
+		Help: "Heartbeats aceptados sin telemetría porque la enviada era ilegible, por nodo.",
+	}, []string{"node"})
+
+	// NodeOffline vale 1 si la policy considera offline el nodo y 0 si no.
+	NodeOffline = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "edge_node_offline",
+		Help: "1 si el nodo está offline según la policy, 0 si está online.",
+	}, []string{"policy", "node"})
+
+	// DegradationActions cuenta las acciones de degradación por tipo de
+	// objeto y razón, con las mismas razones que los Events registrados.
+	DegradationActions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "edge_degradation_actions_total",
+		Help: "Acciones de degradación y restauración, por tipo de objeto y razón.",
+	}, []string{"kind", "reason"})
+
+	// HeartbeatRequestDuration mide la latencia del servidor de heartbeats.
+	HeartbeatRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "edge_heartbeat_request_duration_seconds",
+		Help:    "Latencia de las peticiones al servidor de heartbeats, por ruta y código HTTP.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 12),
+	}, []string{"path", "code"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		HeartbeatsReceived,
+		HeartbeatTelemetryDropped,
+		NodeOffline,
+		DegradationActions,
+		HeartbeatRequestDuration,
+	)
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/jaiderssjgod/edge-operator/internal/heartbeatstore"
+)
+
+var (
+	heartbeatAgeDesc = prometheus.NewDesc("edge_heartbeat_age_seconds",
+		"Segundos desde el último heartbeat recibido del nodo.", []string{"node"}, nil)
+	nodeCPUDesc = prometheus.NewDesc("edge_node_cpu_percent",
+		"Uso de CPU reportado en el último heartbeat del nodo.", []string{"node"}, nil)
+	nodeMemoryDesc = prometheus.NewDesc("edge_node_memory_percent",
+		"Uso de memoria reportado en el último heartbeat del nodo.", []string{"node"}, nil)
+)
+
+// UnknownNode es el valor de la etiqueta node para los nodos que ninguna
+// policy selecciona. El nombre del nodo llega en el cuerpo del heartbeat:
+// usarlo tal cual permitiría a cualquier cliente crear series sin límite.
+// No es un nombre de nodo válido, así que no colisiona con ninguno.
+const UnknownNode = "_unknown"
+
+// NodeLabel devuelve el valor de la etiqueta node para nodeName: su nombre
+// si alguna policy lo selecciona y UnknownNode si no.
+func NodeLabel(store *heartbeatstore.Store, nodeName string) string {
+	if store.Known(nodeName) {
+		return nodeName
+	}
+	return UnknownNode
+}
+
+// storeCollector calcula en cada scrape las métricas derivadas del
+// HeartbeatStore, así la antigüedad del heartbeat nunca queda congelada.
+type storeCollector struct {
+	store *heartbeatstore.Store
+	now   func() time.Time
+}
+
+// NewStoreCollector devuelve un prometheus.Collector sobre store.
+func NewStoreCollector(store *heartbeatstore.Store) prometheus.Collector {
+	return &storeCollector{store: store, now: time.Now}
+}
+
+// RegisterStore registra en el registry de controller-runtime las métricas
+// del HeartbeatStore.
+func RegisterStore(store *heartbeatstore.Store) error {
+	return ctrlmetrics.Registry.Register(NewStoreCollector(store))
+}
+
+func (c *storeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- heartbeatAgeDesc
+	ch <- nodeCPUDesc
+	ch <- nodeMemoryDesc
+}
+
+func (c *storeCollector) Collect(ch chan<- prometheus.Metric) {
+	now := c.now()
+	for node, p := range c.store.Snapshot() {
+		// Los gauges de nodos desconocidos no se pueden agrupar
+		if !c.store.Known(node) {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(heartbeatAgeDesc, prometheus.GaugeValue,
+			now.Sub(p.Timestamp).Seconds(), node)
+		if p.Resources == nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(nodeCPUDesc, prometheus.GaugeValue, p.Resources.CPUPercent, node)
+		ch <- prometheus.MustNewConstMetric(nodeMemoryDesc, prometheus.GaugeValue, p.Resources.MemoryPercent, node)
+	}
+}
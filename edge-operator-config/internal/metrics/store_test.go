@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/jaiderssjgod/edge-operator/heartbeat"
+	"github.com/jaiderssjgod/edge-operator/internal/heartbeatstore"
+)
+
+func TestStoreCollector(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := heartbeatstore.New(30 * time.Second)
+	store.Record(heartbeat.Payload{NodeName: "node-1", Timestamp: ts,
+		Resources: &heartbeat.Resources{CPUPercent: 42.5, MemoryPercent: 61}})
+	// Sin telemetría tipada no se exporta CPU ni memoria, solo la antigüedad
+	store.Record(heartbeat.Payload{NodeName: "node-2", Timestamp: ts})
+	// Un nodo que ninguna policy selecciona no se exporta
+	store.Record(heartbeat.Payload{NodeName: "made-up", Timestamp: ts,
+		Resources: &heartbeat.Resources{CPUPercent: 1}})
+	store.SetAgentConfig("node-1", heartbeat.AgentConfig{Policy: "p"})
+	store.SetAgentConfig("node-2", heartbeat.AgentConfig{Policy: "p"})
+
+	c := &storeCollector{store: store, now: func() time.Time { return ts.Add(15 * time.Second) }}
+
+	expected := `
+# HELP edge_heartbeat_age_seconds Segundos desde el último heartbeat recibido del nodo.
+# TYPE edge_heartbeat_age_seconds gauge
+edge_heartbeat_age_seconds{node="node-1"} 15
+edge_heartbeat_age_seconds{node="node-2"} 15
+# HELP edge_node_cpu_percent Uso de CPU reportado en el último heartbeat del nodo.
+# TYPE edge_node_cpu_percent gauge
+edge_node_cpu_percent{node="node-1"} 42.5
+# HELP edge_node_memory_percent Uso de memoria reportado en el último heartbeat del nodo.
+# TYPE edge_node_memory_percent gauge
+edge_node_memory_percent{node="node-1"} 61
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNodeLabel_CollapsesUnknownNodes(t *testing.T) {
+	store := heartbeatstore.New(30 * time.Second)
+	store.SetAgentConfig("node-1", heartbeat.AgentConfig{Policy: "p"})
+
+	if got := NodeLabel(store, "node-1"); got != "node-1" {
+		t.Errorf("NodeLabel(node-1) = %q, se esperaba el nombre del nodo", got)
+	}
+	if got := NodeLabel(store, "made-up"); got != UnknownNode {
+		t.Errorf("NodeLabel(made-up) = %q, se esperaba %q", got, UnknownNode)
+	}
+}